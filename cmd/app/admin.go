@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+
+	"github.com/TemirB/wb-tech-L0/internal/cache"
+)
+
+// cacheAdmin exposes the cache and its warmer to the admin endpoints.
+// Re-warms run on the application context, not on the request's.
+type cacheAdmin struct {
+	*cache.Cache
+	warmer *cache.Warmer
+	ctx    context.Context
+}
+
+func (a cacheAdmin) Rewarm() bool {
+	return a.warmer.Rewarm(a.ctx)
+}
@@ -12,17 +12,34 @@ import (
 
 	"github.com/TemirB/wb-tech-L0/internal/application/handler"
 	"github.com/TemirB/wb-tech-L0/internal/application/service"
-	"github.com/TemirB/wb-tech-L0/internal/cache"
+	"github.com/TemirB/wb-tech-L0/internal/auth"
+	cachepkg "github.com/TemirB/wb-tech-L0/internal/cache"
 	"github.com/TemirB/wb-tech-L0/internal/config"
 	"github.com/TemirB/wb-tech-L0/internal/database"
 	"github.com/TemirB/wb-tech-L0/internal/httpapi"
 	"github.com/TemirB/wb-tech-L0/internal/kafka"
 	"github.com/TemirB/wb-tech-L0/internal/observability"
 	"github.com/TemirB/wb-tech-L0/internal/pkg/breaker"
+	"github.com/TemirB/wb-tech-L0/internal/storage"
+	"github.com/TemirB/wb-tech-L0/internal/stream"
 	kafkago "github.com/segmentio/kafka-go"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			runExport(os.Args[2:])
+			return
+		case "import":
+			runImport(os.Args[2:])
+			return
+		}
+	}
+	serve()
+}
+
+func serve() {
 	cfg := config.Load()
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
@@ -32,14 +49,76 @@ func main() {
 		panic(err)
 	}
 
-	pool := database.Connect(ctx, cfg.DSN())
-	repo := database.New(pool, cfg.Tables)
+	metrics := observability.NewInmem(100)
+	repo, pool, closeStorage := openStorage(ctx, cfg, logger, metrics)
+	defer closeStorage()
+
+	// With write-behind, upserts return once the order is in the local log; the
+	// flusher writes it to storage and gets a final flush before storage closes.
+	var writeBehind *storage.WriteBehind
+	if cfg.WriteBehind.Enabled {
+		writeBehind, err = storage.OpenWriteBehind(repo, cfg.WriteBehind, logger, metrics)
+		if err != nil {
+			logger.Fatal("failed to open write-behind log", zap.String("dir", cfg.WriteBehind.Dir), zap.Error(err))
+		}
+		flushed := make(chan struct{})
+		go func() {
+			defer close(flushed)
+			writeBehind.Run(ctx)
+		}()
+		defer func() { <-flushed }()
+		repo = writeBehind
+	}
 
-	cache, err := cache.New(cfg.CacheCap)
+	cache, err := cachepkg.New(cfg.Cache)
 	if err != nil {
 		panic(err)
 	}
-	cache.Warm(ctx, repo)
+	cache.SetLoader(repo.GetByUID)
+	go cache.Run(ctx, metrics)
+	if cfg.Warmup.ReadsFlushInterval > 0 {
+		go cache.PersistReads(ctx, repo, cfg.Warmup.ReadsFlushInterval, logger)
+	}
+	negative := cachepkg.NewNegative(cfg.Cache.NegativeCap, cfg.Cache.NegativeTTL)
+	var stale *cachepkg.Stale
+	if cfg.Cache.StaleStoreCap > 0 {
+		stale = cachepkg.NewStale(cfg.Cache.StaleStoreCap, cfg.Cache.StaleStoreMaxAge)
+		cache.OnEvict(stale.Add)
+	}
+	syncer := cachepkg.NewSyncer(cache, negative, stale, repo, logger)
+
+	// Start from the last snapshot if there is a usable one, otherwise warm up in
+	// the background; until the cache is warm misses are served from storage.
+	warmer := cachepkg.NewWarmer(cache, repo, cfg.Warmup, logger)
+	if restoreSnapshot(ctx, cache, syncer, cfg.Cache, logger) {
+		warmer.Skip()
+	} else {
+		go warmer.Run(ctx)
+	}
+	traced := make(chan struct{}) // closed once the access trace is written
+	if cfg.Cache.TracePath != "" {
+		go func() {
+			defer close(traced)
+			cache.RecordTrace(ctx, cfg.Cache.TracePath, logger)
+		}()
+	} else {
+		close(traced)
+	}
+	snapshots := make(chan struct{}) // closed once periodic snapshots have stopped
+	if cfg.Cache.SnapshotPath != "" && cfg.Cache.SnapshotInterval > 0 {
+		go func() {
+			defer close(snapshots)
+			cache.RunSnapshots(ctx, cfg.Cache.SnapshotPath, cfg.Cache.SnapshotInterval, logger)
+		}()
+	} else {
+		close(snapshots)
+	}
+
+	// Other instances write to the same database; keep this cache in step with them.
+	if pool != nil && cfg.Pg.NotifyChannel != "" {
+		listener := database.NewListener(pool, cfg.Pg.NotifyChannel, syncer, logger)
+		go listener.Run(ctx)
+	}
 
 	if err := kafka.EnsureTopic(ctx, cfg.Kafka.Brokers, cfg.Kafka.Topic, 1, 1, logger); err != nil {
 		logger.Fatal("failed to ensure kafka topic", zap.Error(err))
@@ -64,15 +143,56 @@ func main() {
 		// ErrorLogger: log.New(os.Stderr, "kafka ERR ", log.LstdFlags),
 	})
 
-	metrics := observability.NewInmem(100)
+	// Reads trip their own breaker: while it is open, misses are served from the
+	// stale store and storage is probed again after Breaker.OpenTimeout.
+	readBreaker := breaker.New(cfg.Breaker)
 	breaker := breaker.New(cfg.Breaker)
-	service := service.NewService(cache, repo, logger, metrics)
+	service := service.NewService(cache, negative, repo, logger, metrics)
+	if stale != nil {
+		service.EnableStaleReads(stale, readBreaker)
+	}
+	var hub *stream.Hub
+	if cfg.Stream.Enabled {
+		hub = stream.New(cfg.Stream)
+		service.EnablePublishing(hub)
+	}
 	handler := handler.NewHandler(service, breaker, cfg.Retry, logger)
 
 	consumer := kafka.NewConsumer(handler, reader, logger)
 	go consumer.Start(ctx)
 
 	srv := httpapi.New(service, logger, metrics)
+	srv.AddReadinessCheck("cache_warmup", warmer.Readiness)
+	if writeBehind != nil {
+		srv.AddReadinessCheck("write_behind", writeBehind.Readiness)
+	}
+	if cfg.Cache.Encode {
+		srv.EnableEncodedReads(service)
+	}
+	srv.EnableLimits(cfg.HTTP)
+	srv.EnableCacheControl(cfg.HTTP.CacheControl)
+	if err := srv.EnableCompression(cfg.HTTP.Compression); err != nil {
+		logger.Fatal("failed to configure compression", zap.Error(err))
+	}
+	if hub != nil {
+		srv.EnableOrderStream(hub, cfg.Stream.Heartbeat)
+	}
+	authenticator, err := auth.New(cfg.Auth)
+	if err != nil {
+		logger.Fatal("failed to configure authentication", zap.Error(err))
+	}
+	if authenticator != nil {
+		srv.EnableAuth(authenticator)
+		if cfg.Admin.Token != "" {
+			logger.Warn("ADMIN_TOKEN is ignored while authentication is enabled, /admin needs the admin role")
+		}
+	} else {
+		logger.Warn("authentication disabled, set AUTH_API_KEYS or AUTH_JWT_SECRET to require it")
+	}
+	srv.EnableAdmin(cacheAdmin{Cache: cache, warmer: warmer, ctx: ctx}, cfg.Admin.Token)
+	if cfg.Admin.Token == "" && authenticator == nil {
+		logger.Info("admin endpoints disabled, ADMIN_TOKEN is not set")
+	}
 	go func() {
 		if err := srv.ListenAndServe(ctx, cfg.HTTPAddr); err != nil {
 			logger.Error("http stopped", zap.Error(err))
@@ -85,4 +205,7 @@ func main() {
 	if err := reader.Close(); err != nil {
 		logger.Error("failed to close kafka reader", zap.Error(err))
 	}
+	<-traced
+	<-snapshots
+	saveSnapshot(cache, cfg.Cache, logger)
 }
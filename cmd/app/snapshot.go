@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/cache"
+	"github.com/TemirB/wb-tech-L0/internal/config"
+)
+
+// restoreSnapshot loads the cache snapshot and revalidates it against storage.
+// It reports whether the cache was restored; if revalidation fails the restored
+// orders cannot be trusted and are dropped.
+func restoreSnapshot(ctx context.Context, c *cache.Cache, syncer *cache.Syncer, cfg config.Cache, logger *zap.Logger) bool {
+	if cfg.SnapshotPath == "" {
+		return false
+	}
+
+	start := time.Now()
+	n, err := c.LoadSnapshot(cfg.SnapshotPath, cfg.SnapshotMaxAge)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		logger.Info("no cache snapshot to restore", zap.String("path", cfg.SnapshotPath))
+		return false
+	case err != nil:
+		logger.Warn("cache snapshot not restored", zap.String("path", cfg.SnapshotPath), zap.Error(err))
+		return false
+	}
+
+	if err := syncer.Resync(ctx); err != nil {
+		c.Purge()
+		logger.Warn("cache snapshot dropped, could not revalidate it", zap.Error(err))
+		return false
+	}
+	logger.Info("cache restored from snapshot",
+		zap.String("path", cfg.SnapshotPath),
+		zap.Int("orders", n),
+		zap.Int("valid", c.Len()),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	return c.Len() > 0
+}
+
+func saveSnapshot(c *cache.Cache, cfg config.Cache, logger *zap.Logger) {
+	if cfg.SnapshotPath == "" {
+		return
+	}
+	n, err := c.SaveSnapshot(cfg.SnapshotPath)
+	if err != nil {
+		logger.Error("failed to save cache snapshot", zap.String("path", cfg.SnapshotPath), zap.Error(err))
+		return
+	}
+	logger.Info("cache snapshot saved", zap.String("path", cfg.SnapshotPath), zap.Int("orders", n))
+}
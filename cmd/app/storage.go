@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/database"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"github.com/TemirB/wb-tech-L0/internal/storage"
+)
+
+// openStorage opens the order store selected by STORAGE_DRIVER and returns it
+// with a function releasing it and, for Postgres, the underlying pool. With
+// metrics set (the long-running server), Postgres partition maintenance and
+// pool statistics reporting are started as well; one-shot commands pass nil.
+func openStorage(ctx context.Context, cfg config.Config, logger *zap.Logger, metrics observability.Metrics) (storage.Repo, *pgxpool.Pool, func()) {
+	switch cfg.Storage.Driver {
+	case config.StorageMemory:
+		logger.Warn("using in-memory storage, orders are lost on restart")
+		return storage.NewMemory(), nil, func() {}
+
+	case config.StorageFile:
+		fs, err := storage.OpenFile(cfg.Storage.Path, logger)
+		if err != nil {
+			logger.Fatal("failed to open file storage", zap.String("path", cfg.Storage.Path), zap.Error(err))
+		}
+		logger.Info("using file storage", zap.String("path", cfg.Storage.Path))
+		return fs, nil, func() {
+			if err := fs.Close(); err != nil {
+				logger.Error("failed to close file storage", zap.Error(err))
+			}
+		}
+
+	default:
+		pool, err := database.Connect(ctx, cfg.DSN(), cfg.Pg, logger)
+		if err != nil {
+			logger.Fatal("failed to connect to postgres", zap.Error(err))
+		}
+		if metrics != nil {
+			go database.ReportPoolStats(ctx, pool, metrics, cfg.Pg.StatsInterval)
+			if cfg.Partitions.Enabled {
+				partitioner := database.NewPartitioner(pool, cfg.Tables, cfg.Partitions, logger)
+				go partitioner.Run(ctx)
+			}
+		}
+		return database.New(pool, cfg.Tables, cfg.Pg), pool, pool.Close
+	}
+}
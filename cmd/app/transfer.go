@@ -0,0 +1,175 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/transfer"
+)
+
+// runExport implements `app export`: streams orders from Postgres to NDJSON or CSV.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "ndjson", "output format: ndjson or csv (one row per item)")
+	out := fs.String("out", "-", "output file, - for stdout")
+	gz := fs.Bool("gzip", false, "gzip the output (implied by a .gz output file)")
+	from := fs.String("from", "", "only orders created at or after this date (2006-01-02 or RFC3339)")
+	to := fs.String("to", "", "only orders created before this date (2006-01-02 or RFC3339)")
+	customer := fs.String("customer", "", "only orders of this customer_id")
+	_ = fs.Parse(args)
+
+	logger, ctx, stop := transferSetup()
+	defer stop()
+
+	f, err := transfer.ParseFormat(*format)
+	if err != nil {
+		logger.Fatal("bad -format", zap.Error(err))
+	}
+	filter := domain.OrderFilter{CustomerID: strings.TrimSpace(*customer)}
+	if filter.From, err = parseDate(*from); err != nil {
+		logger.Fatal("bad -from", zap.Error(err))
+	}
+	if filter.To, err = parseDate(*to); err != nil {
+		logger.Fatal("bad -to", zap.Error(err))
+	}
+
+	if err := exportOrders(ctx, logger, f, filter, *out, *gz || strings.HasSuffix(*out, ".gz")); err != nil {
+		logger.Fatal("export failed", zap.Error(err))
+	}
+}
+
+// exportOrders writes the orders passing filter to out. It returns errors
+// rather than exiting, so storage and the output are always closed.
+func exportOrders(ctx context.Context, logger *zap.Logger, f transfer.Format, filter domain.OrderFilter, out string, gz bool) (err error) {
+	repo, _, closeStorage := openStorage(ctx, config.Load(), logger, nil)
+	defer closeStorage()
+
+	// Finishing the output can fail too (the gzip trailer, the last writes to
+	// the file), so it is closed explicitly, innermost writer first.
+	var w io.Writer = os.Stdout
+	var closers []func() error
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			if cerr := closers[i](); cerr != nil && err == nil {
+				err = fmt.Errorf("finish output: %w", cerr)
+			}
+		}
+	}()
+	if out != "-" {
+		file, err := os.Create(out)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		closers = append(closers, func() error {
+			return errors.Join(file.Sync(), file.Close())
+		})
+		w = file
+	}
+	if gz {
+		zw := gzip.NewWriter(w)
+		closers = append(closers, zw.Close)
+		w = zw
+	}
+
+	_, err = transfer.NewExporter(repo, logger).Export(ctx, w, f, filter)
+	return err
+}
+
+// runImport implements `app import`: loads NDJSON orders (optionally gzipped) into Postgres.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	in := fs.String("in", "-", "NDJSON input file (gzip detected automatically), - for stdin")
+	rejects := fs.String("rejects", "", "file for rejected lines (default: <in>.rejects.ndjson, or rejects.ndjson for stdin)")
+	batch := fs.Int("batch", 500, "orders per transaction")
+	_ = fs.Parse(args)
+
+	logger, ctx, stop := transferSetup()
+	defer stop()
+
+	rejectsPath := *rejects
+	if rejectsPath == "" {
+		rejectsPath = "rejects.ndjson"
+		if *in != "-" {
+			rejectsPath = strings.TrimSuffix(*in, ".gz") + ".rejects.ndjson"
+		}
+	}
+	if err := importOrders(ctx, logger, *in, rejectsPath, *batch); err != nil {
+		logger.Fatal("import failed", zap.Error(err))
+	}
+}
+
+// importOrders loads the orders read from in, writing rejected lines to
+// rejectsPath. It returns errors rather than exiting, so storage, the input and
+// the rejects file are always closed; the rejects file is synced first, as an
+// import that lost some of it would not say which lines to fix.
+func importOrders(ctx context.Context, logger *zap.Logger, in, rejectsPath string, batch int) (err error) {
+	repo, _, closeStorage := openStorage(ctx, config.Load(), logger, nil)
+	defer closeStorage()
+
+	var r io.Reader = os.Stdin
+	if in != "-" {
+		file, err := os.Open(in)
+		if err != nil {
+			return fmt.Errorf("open input: %w", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	rf, err := os.Create(rejectsPath)
+	if err != nil {
+		return fmt.Errorf("create rejects file: %w", err)
+	}
+	defer func() {
+		if cerr := errors.Join(rf.Sync(), rf.Close()); cerr != nil {
+			err = errors.Join(err, fmt.Errorf("finish rejects file: %w", cerr))
+		}
+	}()
+
+	st, err := transfer.NewImporter(repo, batch, logger).Import(ctx, r, rf)
+	if err != nil {
+		return err
+	}
+	if st.Rejected > 0 {
+		logger.Warn("some lines were rejected", zap.Int("rejected", st.Rejected), zap.String("rejects", rejectsPath))
+	}
+	return nil
+}
+
+func transferSetup() (*zap.Logger, context.Context, context.CancelFunc) {
+	// zap's development logger writes to stderr, so exports to stdout stay clean.
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		panic(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	return logger, ctx, stop
+}
+
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("want 2006-01-02 or RFC3339, got %q", s)
+	}
+	return t, nil
+}
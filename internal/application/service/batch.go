@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"go.uber.org/zap"
+)
+
+// BatchItem is an order found by GetByUIDsWithStats and where it came from.
+type BatchItem struct {
+	Order  *domain.Order
+	Source LookupSource
+}
+
+// GetByUIDsWithStats looks orders up by UID: in the cache first, then the
+// misses in storage with one query. Found orders and missing UIDs keep the
+// order of uids, duplicates counted once. UIDs the negative cache knows to be
+// missing are not queried.
+//
+// With stale reads enabled, a storage query the circuit breaker refuses is
+// answered from the stale copies; if one of the misses has none, the lookup
+// fails with ErrStorageUnavailable, as it cannot tell which of them exist. A
+// query that is let through and fails returns ErrStorageUnavailable.
+func (s *Service) GetByUIDsWithStats(ctx context.Context, uids []string) ([]BatchItem, []string, BatchStats, error) {
+	var st BatchStats
+
+	var epoch uint64
+	if s.negative != nil {
+		epoch = s.negative.Epoch()
+	}
+
+	tCacheStart := time.Now()
+	found := make(map[string]BatchItem, len(uids))
+	seen := make(map[string]bool, len(uids))
+	unique := make([]string, 0, len(uids))
+	var misses []string
+	for _, uid := range uids {
+		if seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		unique = append(unique, uid)
+		if order, ok := s.cache.Get(uid); ok {
+			found[uid] = BatchItem{Order: order, Source: SourceCache}
+			st.CacheHits++
+			s.metrics.IncCacheHit()
+			continue
+		}
+		s.metrics.IncCacheMiss()
+		if s.negative != nil {
+			if s.negative.Has(uid) {
+				s.metrics.IncNegativeCacheHit()
+				continue
+			}
+			s.metrics.IncNegativeCacheMiss()
+		}
+		misses = append(misses, uid)
+	}
+	st.CacheMs = convertToMs(tCacheStart)
+
+	if len(misses) > 0 {
+		tDbStart := time.Now()
+		loaded, err := s.getByUIDs(ctx, misses)
+		if err != nil {
+			if s.stale == nil || !isRefused(err) {
+				s.logger.Error("Can't look up orders", zap.Int("uids", len(misses)), zap.Error(err))
+				return nil, nil, st, err
+			}
+			if err := s.batchStale(misses, found, err); err != nil {
+				return nil, nil, st, err
+			}
+			st.Stale = len(misses)
+		} else {
+			st.DBMs = convertToMs(tDbStart)
+			for uid, order := range loaded {
+				s.cache.Set(order)
+				found[uid] = BatchItem{Order: order, Source: SourceDB}
+			}
+			st.DBLoads = len(loaded)
+			if s.negative != nil {
+				for _, uid := range misses {
+					if loaded[uid] == nil {
+						s.negative.Add(uid, epoch)
+					}
+				}
+			}
+		}
+	}
+
+	items := make([]BatchItem, 0, len(found))
+	var missing []string
+	for _, uid := range unique {
+		if item, ok := found[uid]; ok {
+			items = append(items, item)
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+
+	s.logger.Info("Orders looked up in a batch",
+		zap.Int("uids", len(unique)),
+		zap.Int("missing", len(missing)),
+		zap.Int("cache_hits", st.CacheHits),
+		zap.Int("db_loads", st.DBLoads),
+		zap.Int("stale", st.Stale),
+		zap.Float64("cache_ms", st.CacheMs),
+		zap.Float64("db_ms", st.DBMs),
+	)
+	return items, missing, st, nil
+}
+
+// getByUIDs queries storage unless the circuit breaker refuses, and reports the
+// outcome to it; refusals and failures come back wrapped in
+// ErrStorageUnavailable.
+func (s *Service) getByUIDs(ctx context.Context, uids []string) (map[string]*domain.Order, error) {
+	if s.db == nil {
+		return s.storage.GetByUIDs(ctx, uids)
+	}
+	if err := s.db.Allow(); err != nil {
+		return nil, refused(err)
+	}
+	loaded, err := s.storage.GetByUIDs(ctx, uids)
+	switch {
+	case err == nil:
+		s.db.Success()
+	case ctx.Err() == nil:
+		s.db.Failure()
+		return nil, fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	return loaded, err
+}
+
+// batchStale fills found with stale copies of uids after the circuit breaker
+// refused the query with cause, or returns cause if one of them has none.
+func (s *Service) batchStale(uids []string, found map[string]BatchItem, cause error) error {
+	items := make(map[string]BatchItem, len(uids))
+	for _, uid := range uids {
+		order, ok := s.stale.Get(uid)
+		if !ok {
+			s.logger.Error("Can't look up orders, storage unavailable",
+				zap.Int("uids", len(uids)),
+				zap.String("order_uid", uid),
+				zap.Error(cause),
+			)
+			return cause
+		}
+		items[uid] = BatchItem{Order: order, Source: SourceStale}
+	}
+	for uid, item := range items {
+		found[uid] = item
+	}
+	s.logger.Warn("Orders served stale, storage unavailable",
+		zap.Int("uids", len(uids)),
+		zap.Error(cause),
+	)
+	return nil
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"github.com/TemirB/wb-tech-L0/internal/pkg/breaker"
+)
+
+func TestGetByUIDs(t *testing.T) {
+	a, b, c := &domain.Order{OrderUID: "a"}, &domain.Order{OrderUID: "b"}, &domain.Order{OrderUID: "c"}
+	dbErr := errors.New("db down")
+
+	type mocks struct {
+		cache    *MockCache
+		negative *MockNegativeCache
+		storage  *MockStorage
+		stale    *MockStaleCache
+		db       *MockDBHealth
+	}
+
+	testCases := []struct {
+		name        string
+		uids        []string
+		stale       bool
+		setup       func(m mocks)
+		wantItems   []BatchItem
+		wantMissing []string
+		wantStats   BatchStats
+		wantErr     error
+	}{
+		{
+			name: "cache first, misses in one query",
+			uids: []string{"a", "b", "gone", "c", "a"},
+			setup: func(m mocks) {
+				m.cache.EXPECT().Get("a").Return(nil, false)
+				m.cache.EXPECT().Get("b").Return(b, true)
+				m.cache.EXPECT().Get("gone").Return(nil, false)
+				m.cache.EXPECT().Get("c").Return(nil, false)
+				m.negative.EXPECT().Has("a").Return(false)
+				m.negative.EXPECT().Has("gone").Return(false)
+				m.negative.EXPECT().Has("c").Return(false)
+				m.storage.EXPECT().GetByUIDs(gomock.Any(), []string{"a", "gone", "c"}).
+					Return(map[string]*domain.Order{"a": a, "c": c}, nil)
+				m.cache.EXPECT().Set(a)
+				m.cache.EXPECT().Set(c)
+				m.negative.EXPECT().Add("gone", uint64(7))
+			},
+			wantItems:   []BatchItem{{a, SourceDB}, {b, SourceCache}, {c, SourceDB}},
+			wantMissing: []string{"gone"},
+			wantStats:   BatchStats{CacheHits: 1, DBLoads: 2},
+		},
+		{
+			name: "known missing are not queried",
+			uids: []string{"b", "gone"},
+			setup: func(m mocks) {
+				m.cache.EXPECT().Get("b").Return(b, true)
+				m.cache.EXPECT().Get("gone").Return(nil, false)
+				m.negative.EXPECT().Has("gone").Return(true)
+			},
+			wantItems:   []BatchItem{{b, SourceCache}},
+			wantMissing: []string{"gone"},
+			wantStats:   BatchStats{CacheHits: 1},
+		},
+		{
+			name: "storage error",
+			uids: []string{"a"},
+			setup: func(m mocks) {
+				m.cache.EXPECT().Get("a").Return(nil, false)
+				m.negative.EXPECT().Has("a").Return(false)
+				m.storage.EXPECT().GetByUIDs(gomock.Any(), []string{"a"}).Return(nil, dbErr)
+			},
+			wantErr: dbErr,
+		},
+		{
+			name:  "breaker open serves stale copies",
+			uids:  []string{"a", "b"},
+			stale: true,
+			setup: func(m mocks) {
+				m.cache.EXPECT().Get("a").Return(nil, false)
+				m.cache.EXPECT().Get("b").Return(b, true)
+				m.negative.EXPECT().Has("a").Return(false)
+				m.db.EXPECT().Allow().Return(breaker.ErrOpenState)
+				m.stale.EXPECT().Get("a").Return(a, true)
+			},
+			wantItems: []BatchItem{{a, SourceStale}, {b, SourceCache}},
+			wantStats: BatchStats{CacheHits: 1, Stale: 1},
+		},
+		{
+			name:  "breaker open without a stale copy",
+			uids:  []string{"a", "c"},
+			stale: true,
+			setup: func(m mocks) {
+				m.cache.EXPECT().Get("a").Return(nil, false)
+				m.cache.EXPECT().Get("c").Return(nil, false)
+				m.negative.EXPECT().Has("a").Return(false)
+				m.negative.EXPECT().Has("c").Return(false)
+				m.db.EXPECT().Allow().Return(breaker.ErrOpenState)
+				m.stale.EXPECT().Get("a").Return(a, true)
+				m.stale.EXPECT().Get("c").Return(nil, false)
+			},
+			wantErr: ErrStorageUnavailable,
+		},
+		{
+			name:  "storage failure is not served stale",
+			uids:  []string{"a"},
+			stale: true,
+			setup: func(m mocks) {
+				m.cache.EXPECT().Get("a").Return(nil, false)
+				m.negative.EXPECT().Has("a").Return(false)
+				m.db.EXPECT().Allow().Return(nil)
+				m.storage.EXPECT().GetByUIDs(gomock.Any(), []string{"a"}).Return(nil, dbErr)
+				m.db.EXPECT().Failure()
+			},
+			wantErr: ErrStorageUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			m := mocks{
+				cache:    NewMockCache(ctrl),
+				negative: NewMockNegativeCache(ctrl),
+				storage:  NewMockStorage(ctrl),
+				stale:    NewMockStaleCache(ctrl),
+				db:       NewMockDBHealth(ctrl),
+			}
+			m.negative.EXPECT().Epoch().Return(uint64(7))
+			tc.setup(m)
+			s := NewService(m.cache, m.negative, m.storage, zap.NewNop(), observability.NewNoop())
+			if tc.stale {
+				s.EnableStaleReads(m.stale, m.db)
+			}
+
+			items, missing, st, err := s.GetByUIDsWithStats(context.Background(), tc.uids)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantItems, items)
+			require.Equal(t, tc.wantMissing, missing)
+			st.CacheMs, st.DBMs = 0, 0
+			require.Equal(t, tc.wantStats, st)
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+var errLoadAborted = errors.New("order load aborted")
+
+// flight coalesces concurrent loads of the same order: the first caller starts
+// the load and later callers wait for its result instead of querying again.
+//
+// The load runs on a context detached from the callers' cancellation, so one
+// caller giving up does not fail the others. Each caller still returns as soon
+// as its own context ends; the load is cancelled once nobody waits for it.
+type flight struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	order *domain.Order
+	err   error
+}
+
+func newFlight() *flight {
+	return &flight{calls: make(map[string]*call)}
+}
+
+// do returns the result of load for uid, and whether it was shared with a load
+// started by another caller. The order is shared between callers as well and
+// must not be modified.
+func (f *flight) do(ctx context.Context, uid string, load func(context.Context) (*domain.Order, error)) (*domain.Order, bool, error) {
+	f.mu.Lock()
+	c, shared := f.calls[uid]
+	if !shared {
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call{done: make(chan struct{}), cancel: cancel}
+		f.calls[uid] = c
+		go f.run(loadCtx, uid, c, load)
+	}
+	c.waiters++
+	f.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return c.order, shared, c.err
+	case <-ctx.Done():
+		f.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			f.forget(uid, c)
+		}
+		f.mu.Unlock()
+		return nil, shared, ctx.Err()
+	}
+}
+
+func (f *flight) run(ctx context.Context, uid string, c *call, load func(context.Context) (*domain.Order, error)) {
+	// Waiters must be released even if load never returns normally.
+	c.err = errLoadAborted
+	defer func() {
+		c.cancel()
+		f.mu.Lock()
+		f.forget(uid, c)
+		f.mu.Unlock()
+		close(c.done)
+	}()
+	c.order, c.err = load(ctx)
+}
+
+// forget drops c unless a newer load for uid has replaced it. The caller holds f.mu.
+func (f *flight) forget(uid string, c *call) {
+	if f.calls[uid] == c {
+		delete(f.calls, uid)
+	}
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+// waiting reports how many callers wait for uid.
+func (f *flight) waiting(uid string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.calls[uid]; ok {
+		return c.waiters
+	}
+	return 0
+}
+
+func TestGetByUIDCoalescesMisses(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const callers = 5
+	order := &domain.Order{OrderUID: "hot"}
+	release := make(chan struct{})
+
+	cache := NewMockCache(ctrl)
+	storage := NewMockStorage(ctrl)
+	cache.EXPECT().Get("hot").Return(nil, false).Times(callers)
+	storage.EXPECT().GetByUID(gomock.Any(), "hot").DoAndReturn(func(context.Context, string) (*domain.Order, error) {
+		<-release
+		return order, nil
+	}).Times(1)
+	cache.EXPECT().Set(order).Times(1)
+
+	s := NewService(cache, nil, storage, zap.NewNop(), observability.NewNoop())
+
+	var wg sync.WaitGroup
+	sources := make(chan LookupSource, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, st, err := s.GetByUIDWithStats(context.Background(), "hot")
+			if err == nil && got == order {
+				sources <- st.Source
+			}
+		}()
+	}
+	require.Eventually(t, func() bool { return s.flight.waiting("hot") == callers }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(sources)
+
+	counts := map[LookupSource]int{}
+	for src := range sources {
+		counts[src]++
+	}
+	require.Equal(t, map[LookupSource]int{SourceDB: 1, SourceCoalesced: callers - 1}, counts)
+}
+
+func TestGetByUIDCoalescedFailureCountsOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	const callers = 5
+	release := make(chan struct{})
+
+	cache := NewMockCache(ctrl)
+	storage := NewMockStorage(ctrl)
+	stale := NewMockStaleCache(ctrl)
+	db := NewMockDBHealth(ctrl)
+	cache.EXPECT().Get("hot").Return(nil, false).Times(callers)
+	db.EXPECT().Allow().Return(nil).Times(1)
+	storage.EXPECT().GetByUID(gomock.Any(), "hot").DoAndReturn(func(context.Context, string) (*domain.Order, error) {
+		<-release
+		return nil, errors.New("connection refused")
+	}).Times(1)
+	db.EXPECT().Failure().Times(1)
+
+	s := NewService(cache, nil, storage, zap.NewNop(), observability.NewNoop())
+	s.EnableStaleReads(stale, db)
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := s.GetByUIDWithStats(context.Background(), "hot")
+			assert.ErrorIs(t, err, ErrStorageUnavailable)
+		}()
+	}
+	require.Eventually(t, func() bool { return s.flight.waiting("hot") == callers }, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+}
+
+func TestFlightCallerCancellation(t *testing.T) {
+	f := newFlight()
+	release := make(chan struct{})
+	var loadCtx context.Context
+	load := func(ctx context.Context) (*domain.Order, error) {
+		loadCtx = ctx
+		<-release
+		return &domain.Order{OrderUID: "a"}, nil
+	}
+
+	// The caller that started the load gives up; the other one still gets the result.
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, _, err := f.do(leaderCtx, "a", load)
+		leaderErr <- err
+	}()
+	require.Eventually(t, func() bool { return f.waiting("a") == 1 }, time.Second, time.Millisecond)
+
+	result := make(chan *domain.Order, 1)
+	go func() {
+		o, shared, err := f.do(context.Background(), "a", load)
+		if err == nil && shared {
+			result <- o
+		}
+		close(result)
+	}()
+	require.Eventually(t, func() bool { return f.waiting("a") == 2 }, time.Second, time.Millisecond)
+
+	cancelLeader()
+	require.ErrorIs(t, <-leaderErr, context.Canceled)
+
+	close(release)
+	o := <-result
+	require.NotNil(t, o)
+	require.Equal(t, "a", o.OrderUID)
+	require.Error(t, loadCtx.Err(), "load context is released when the load finishes")
+}
+
+func TestFlightCancelsAbandonedLoad(t *testing.T) {
+	f := newFlight()
+	cancelled := make(chan struct{})
+	load := func(ctx context.Context) (*domain.Order, error) {
+		<-ctx.Done()
+		close(cancelled)
+		return nil, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _, err := f.do(ctx, "a", load)
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+	require.Eventually(t, func() bool { return f.waiting("a") == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("load was not cancelled after its only caller left")
+	}
+	require.Zero(t, f.waiting("a"))
+}
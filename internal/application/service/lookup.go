@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"go.uber.org/zap"
+)
+
+func (s *Service) GetByTrackNumber(ctx context.Context, track string) (*domain.Order, error) {
+	o, _, err := s.GetByTrackNumberWithStats(ctx, track)
+	return o, err
+}
+
+// GetByTrackNumberWithStats looks the order up in the cache's track number index
+// first and falls back to an indexed storage query, caching its result.
+// Concurrent misses for the same track number share one query.
+func (s *Service) GetByTrackNumberWithStats(ctx context.Context, track string) (*domain.Order, LookupStats, error) {
+	var st LookupStats
+
+	tCacheStart := time.Now()
+	if order, ok := s.cache.GetByTrackNumber(track); ok {
+		st.Source = SourceCache
+		st.CacheMs = convertToMs(tCacheStart)
+		s.metrics.IncCacheHit()
+		s.metrics.ObserveLookup(string(st.Source), st.CacheMs, 0)
+
+		s.logger.Info("Order fetched from cache by track number",
+			zap.String("track_number", track),
+			zap.Float64("cache_ms", st.CacheMs),
+		)
+		return order, st, nil
+	}
+	s.metrics.IncCacheMiss()
+	st.CacheMs = convertToMs(tCacheStart)
+
+	tDbStart := time.Now()
+	order, shared, err := s.tracks.do(ctx, track, func(ctx context.Context) (*domain.Order, error) {
+		order, err := s.storage.GetByTrackNumber(ctx, track)
+		if err == nil {
+			s.cache.Set(order)
+		}
+		return order, err
+	})
+	if err != nil {
+		s.logger.Error(
+			"Can't find order by track number",
+			zap.String("track_number", track),
+			zap.Error(err),
+		)
+		return nil, st, err
+	}
+
+	st.Source = SourceDB
+	if shared {
+		st.Source = SourceCoalesced
+	}
+	st.DBMs = convertToMs(tDbStart)
+
+	s.metrics.ObserveLookup(string(st.Source), st.CacheMs, st.DBMs)
+	s.logger.Info("Order fetched from DB by track number",
+		zap.String("track_number", track),
+		zap.String("order_uid", order.OrderUID),
+		zap.Float64("db_ms", st.DBMs),
+	)
+	return order, st, nil
+}
+
+func (s *Service) ListByCustomer(ctx context.Context, customerID string, limit int) ([]*domain.Order, error) {
+	orders, _, err := s.ListByCustomerWithStats(ctx, customerID, limit)
+	return orders, err
+}
+
+// ListByCustomerWithStats lists up to limit of the customer's orders, newest
+// first. If the cache holds all of the customer's orders it answers alone.
+// Otherwise which orders belong to the customer is an index-only storage query;
+// the orders themselves come from the cache, and the missing ones from storage
+// with one query. Once storage has listed all of them and they are cached, the
+// next listing is served from the cache.
+//
+// If storage fails, the cached orders are returned with Partial set rather
+// than an error; with none cached the error is returned.
+func (s *Service) ListByCustomerWithStats(ctx context.Context, customerID string, limit int) ([]*domain.Order, ListStats, error) {
+	var st ListStats
+
+	tCacheStart := time.Now()
+	if orders, complete := s.cache.ListByCustomer(customerID); complete {
+		if limit >= 0 && len(orders) > limit {
+			orders = orders[:limit]
+		}
+		st.CacheHits = len(orders)
+		st.CacheMs = convertToMs(tCacheStart)
+		s.logger.Info("Orders listed by customer from cache",
+			zap.String("customer_id", customerID),
+			zap.Int("orders", len(orders)),
+			zap.Float64("cache_ms", st.CacheMs),
+		)
+		return orders, st, nil
+	}
+	st.CacheMs = convertToMs(tCacheStart)
+
+	listed := -1 // all of the customer's orders, once storage has listed them
+	watch := s.cache.WatchCustomer(customerID)
+	defer func() { s.cache.CustomerListed(watch, listed) }()
+
+	tDbStart := time.Now()
+	uids, err := s.storage.CustomerOrderIDs(ctx, customerID, limit)
+	if err != nil {
+		return s.listCachedByCustomer(customerID, limit, err)
+	}
+	st.DBMs = convertToMs(tDbStart)
+
+	tCacheStart = time.Now()
+	orders := make([]*domain.Order, len(uids))
+	var missing []string
+	for i, uid := range uids {
+		if order, ok := s.cache.Get(uid); ok {
+			orders[i] = order
+			st.CacheHits++
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+	st.CacheMs += convertToMs(tCacheStart)
+
+	if len(missing) > 0 {
+		tDbStart = time.Now()
+		loaded, err := s.storage.GetByUIDs(ctx, missing)
+		if err != nil {
+			return s.listCachedByCustomer(customerID, limit, err)
+		}
+		st.DBMs += convertToMs(tDbStart)
+		for _, order := range loaded {
+			s.cache.Set(order)
+		}
+		st.DBLoads = len(loaded)
+
+		// Orders deleted between the two queries are left out.
+		n := 0
+		for i, uid := range uids {
+			if orders[i] == nil {
+				orders[i] = loaded[uid]
+			}
+			if orders[i] != nil {
+				orders[n] = orders[i]
+				n++
+			}
+		}
+		orders = orders[:n]
+	}
+	if limit < 0 || len(uids) < limit {
+		listed = len(orders)
+	}
+
+	s.logger.Info("Orders listed by customer",
+		zap.String("customer_id", customerID),
+		zap.Int("orders", len(orders)),
+		zap.Int("cache_hits", st.CacheHits),
+		zap.Int("db_loads", st.DBLoads),
+		zap.Float64("cache_ms", st.CacheMs),
+		zap.Float64("db_ms", st.DBMs),
+	)
+	return orders, st, nil
+}
+
+// listCachedByCustomer answers from the cache index alone after storage failed.
+func (s *Service) listCachedByCustomer(customerID string, limit int, cause error) ([]*domain.Order, ListStats, error) {
+	orders, _ := s.cache.ListByCustomer(customerID)
+	if len(orders) == 0 {
+		s.logger.Error("Can't list orders by customer",
+			zap.String("customer_id", customerID),
+			zap.Error(cause),
+		)
+		return nil, ListStats{}, cause
+	}
+	if limit >= 0 && len(orders) > limit {
+		orders = orders[:limit]
+	}
+	s.logger.Warn("Orders listed by customer from cache only",
+		zap.String("customer_id", customerID),
+		zap.Int("orders", len(orders)),
+		zap.Error(cause),
+	)
+	return orders, ListStats{CacheHits: len(orders), Partial: true}, nil
+}
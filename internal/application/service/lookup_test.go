@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	cachepkg "github.com/TemirB/wb-tech-L0/internal/cache"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+func TestGetByTrackNumber(t *testing.T) {
+	order := &domain.Order{OrderUID: "a", TrackNumber: "WB1"}
+
+	testCases := []struct {
+		name       string
+		setup      func(cache *MockCache, storage *MockStorage)
+		wantSource LookupSource
+		wantErr    error
+	}{
+		{
+			name: "from cache",
+			setup: func(cache *MockCache, _ *MockStorage) {
+				cache.EXPECT().GetByTrackNumber("WB1").Return(order, true)
+			},
+			wantSource: SourceCache,
+		},
+		{
+			name: "from DB",
+			setup: func(cache *MockCache, storage *MockStorage) {
+				cache.EXPECT().GetByTrackNumber("WB1").Return(nil, false)
+				storage.EXPECT().GetByTrackNumber(gomock.Any(), "WB1").Return(order, nil)
+				cache.EXPECT().Set(order)
+			},
+			wantSource: SourceDB,
+		},
+		{
+			name: "not found",
+			setup: func(cache *MockCache, storage *MockStorage) {
+				cache.EXPECT().GetByTrackNumber("WB1").Return(nil, false)
+				storage.EXPECT().GetByTrackNumber(gomock.Any(), "WB1").Return(nil, domain.ErrNotFound)
+			},
+			wantErr: domain.ErrNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			cache, storage := NewMockCache(ctrl), NewMockStorage(ctrl)
+			tc.setup(cache, storage)
+			s := NewService(cache, nil, storage, zap.NewNop(), observability.NewNoop())
+
+			got, st, err := s.GetByTrackNumberWithStats(context.Background(), "WB1")
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, order, got)
+			require.Equal(t, tc.wantSource, st.Source)
+		})
+	}
+}
+
+func TestListByCustomer(t *testing.T) {
+	a, b, c := &domain.Order{OrderUID: "a"}, &domain.Order{OrderUID: "b"}, &domain.Order{OrderUID: "c"}
+	dbErr := errors.New("db down")
+	watch := &cachepkg.CustomerWatch{}
+
+	// incomplete makes the cache hold only some of alice's orders, and expects
+	// the watch to end with listed.
+	incomplete := func(cache *MockCache, cached []*domain.Order, listed int) {
+		cache.EXPECT().ListByCustomer("alice").Return(cached, false)
+		cache.EXPECT().WatchCustomer("alice").Return(watch)
+		cache.EXPECT().CustomerListed(watch, listed)
+	}
+
+	testCases := []struct {
+		name      string
+		limit     int
+		setup     func(cache *MockCache, storage *MockStorage)
+		expected  []*domain.Order
+		wantStats ListStats
+		wantErr   error
+	}{
+		{
+			name:  "all cached orders known to be complete",
+			limit: 2,
+			setup: func(cache *MockCache, storage *MockStorage) {
+				cache.EXPECT().ListByCustomer("alice").Return([]*domain.Order{a, b, c}, true)
+			},
+			expected:  []*domain.Order{a, b},
+			wantStats: ListStats{CacheHits: 2},
+		},
+		{
+			name:  "cached and loaded in storage order",
+			limit: 10,
+			setup: func(cache *MockCache, storage *MockStorage) {
+				incomplete(cache, []*domain.Order{b}, 3)
+				storage.EXPECT().CustomerOrderIDs(gomock.Any(), "alice", 10).Return([]string{"a", "b", "gone", "c"}, nil)
+				cache.EXPECT().Get("a").Return(nil, false)
+				cache.EXPECT().Get("b").Return(b, true)
+				cache.EXPECT().Get("gone").Return(nil, false)
+				cache.EXPECT().Get("c").Return(nil, false)
+				storage.EXPECT().GetByUIDs(gomock.Any(), []string{"a", "gone", "c"}).
+					Return(map[string]*domain.Order{"a": a, "c": c}, nil)
+				cache.EXPECT().Set(a)
+				cache.EXPECT().Set(c)
+			},
+			expected:  []*domain.Order{a, b, c},
+			wantStats: ListStats{CacheHits: 1, DBLoads: 2},
+		},
+		{
+			name:  "more orders than the limit",
+			limit: 1,
+			setup: func(cache *MockCache, storage *MockStorage) {
+				incomplete(cache, nil, -1)
+				storage.EXPECT().CustomerOrderIDs(gomock.Any(), "alice", 1).Return([]string{"b"}, nil)
+				cache.EXPECT().Get("b").Return(b, true)
+			},
+			expected:  []*domain.Order{b},
+			wantStats: ListStats{CacheHits: 1},
+		},
+		{
+			name:  "storage down, cached orders only",
+			limit: 10,
+			setup: func(cache *MockCache, storage *MockStorage) {
+				incomplete(cache, []*domain.Order{b}, -1)
+				storage.EXPECT().CustomerOrderIDs(gomock.Any(), "alice", 10).Return(nil, dbErr)
+				cache.EXPECT().ListByCustomer("alice").Return([]*domain.Order{b}, false)
+			},
+			expected:  []*domain.Order{b},
+			wantStats: ListStats{CacheHits: 1, Partial: true},
+		},
+		{
+			name:  "storage down, nothing cached",
+			limit: 10,
+			setup: func(cache *MockCache, storage *MockStorage) {
+				incomplete(cache, nil, -1)
+				storage.EXPECT().CustomerOrderIDs(gomock.Any(), "alice", 10).Return(nil, dbErr)
+				cache.EXPECT().ListByCustomer("alice").Return(nil, false)
+			},
+			wantErr: dbErr,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			cache, storage := NewMockCache(ctrl), NewMockStorage(ctrl)
+			tc.setup(cache, storage)
+			s := NewService(cache, nil, storage, zap.NewNop(), observability.NewNoop())
+
+			got, st, err := s.ListByCustomerWithStats(context.Background(), "alice", tc.limit)
+			if tc.wantErr != nil {
+				require.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+			st.CacheMs, st.DBMs = 0, 0
+			require.Equal(t, tc.wantStats, st)
+		})
+	}
+}
@@ -2,8 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"time"
 
+	"github.com/TemirB/wb-tech-L0/internal/cache"
 	"github.com/TemirB/wb-tech-L0/internal/domain"
 	"github.com/TemirB/wb-tech-L0/internal/observability"
 	"go.uber.org/zap"
@@ -14,34 +17,135 @@ import (
 type Cache interface {
 	Set(*domain.Order)
 	Get(string) (*domain.Order, bool)
+	GetByTrackNumber(string) (*domain.Order, bool)
+	ListByCustomer(string) ([]*domain.Order, bool)
+	WatchCustomer(string) *cache.CustomerWatch
+	CustomerListed(*cache.CustomerWatch, int)
+	GetEncoded(uid string, acceptGzip bool) (cache.Encoded, bool)
+}
+
+// NegativeCache remembers UIDs storage reported as missing (see cache.Negative).
+type NegativeCache interface {
+	Has(uid string) bool
+	Epoch() uint64
+	Add(uid string, epoch uint64)
+	Remove(uid string)
+}
+
+// StaleCache keeps orders evicted from the cache to serve while storage is
+// unavailable (see cache.Stale).
+type StaleCache interface {
+	Get(uid string) (*domain.Order, bool)
+	Remove(uid string)
+}
+
+// DBHealth tracks storage failures and tells whether storage may be queried; a
+// circuit breaker (see breaker.Breaker).
+type DBHealth interface {
+	Allow() error
+	Success()
+	Failure()
+}
+
+// Publisher learns of every order upserted through the service (see
+// stream.Hub).
+type Publisher interface {
+	Publish(*domain.Order)
+}
+
+// ErrStorageUnavailable is returned for a lookup storage could not answer while
+// no stale copy of the order was kept.
+var ErrStorageUnavailable = errors.New("storage unavailable")
+
+// refusal is the error of a read the circuit breaker did not let through. Only
+// those are served from the stale copies: a read that failed is reported as is.
+type refusal struct{ err error }
+
+func (r refusal) Error() string { return r.err.Error() }
+func (r refusal) Unwrap() error { return r.err }
+
+// refused wraps the circuit breaker's err in ErrStorageUnavailable.
+func refused(err error) error {
+	return fmt.Errorf("%w: %w", ErrStorageUnavailable, refusal{err})
+}
+
+func isRefused(err error) bool {
+	return errors.As(err, new(refusal))
 }
 
 type Storage interface {
 	Upsert(context.Context, *domain.Order) error
+	UpsertIf(context.Context, *domain.Order, domain.Precondition) error
 	GetByUID(context.Context, string) (*domain.Order, error)
+	GetByUIDs(context.Context, []string) (map[string]*domain.Order, error)
+	GetByTrackNumber(context.Context, string) (*domain.Order, error)
+	CustomerOrderIDs(ctx context.Context, customerID string, limit int) ([]string, error)
 }
 
 type Service struct {
-	cache   Cache
-	storage Storage
-	logger  *zap.Logger
-	metrics observability.Metrics
+	cache    Cache
+	negative NegativeCache
+	storage  Storage
+	logger   *zap.Logger
+	metrics  observability.Metrics
+	flight   *flight
+	tracks   *flight // loads by track number
+	stale    StaleCache
+	db       DBHealth
+	events   Publisher
 }
 
-func NewService(cache Cache, storage Storage, logger *zap.Logger, metrics observability.Metrics) *Service {
+// NewService wires the service. negative may be nil to disable negative caching.
+func NewService(cache Cache, negative NegativeCache, storage Storage, logger *zap.Logger, metrics observability.Metrics) *Service {
 	return &Service{
-		cache:   cache,
-		storage: storage,
-		logger:  logger,
-		metrics: metrics,
+		cache:    cache,
+		negative: negative,
+		storage:  storage,
+		logger:   logger,
+		metrics:  metrics,
+		flight:   newFlight(),
+		tracks:   newFlight(),
 	}
 }
 
+// EnableStaleReads serves GetByUIDWithStats misses from stale, the orders
+// recently evicted from the cache, while db refuses to let reads through to
+// storage; a read that is let through and fails returns ErrStorageUnavailable.
+// db learns of every storage read's outcome, so once it lets reads through
+// again and they succeed, lookups go back to storage.
+func (s *Service) EnableStaleReads(stale StaleCache, db DBHealth) {
+	s.stale = stale
+	s.db = db
+}
+
+// EnablePublishing hands every order UpsertWithStats stores to p. Publish must
+// not block, as upserts wait for it.
+func (s *Service) EnablePublishing(p Publisher) {
+	s.events = p
+}
+
 func (s *Service) UpsertWithStats(ctx context.Context, order *domain.Order) (UpsertStats, error) {
+	return s.upsert(ctx, order, s.storage.Upsert)
+}
+
+// UpsertIfWithStats is UpsertWithStats for an order that may only replace a
+// stored version passing p; otherwise it returns domain.ErrVersionMismatch.
+// Storage checks the version in the write itself, so of two writers expecting
+// the same version only one succeeds.
+func (s *Service) UpsertIfWithStats(ctx context.Context, order *domain.Order, p domain.Precondition) (UpsertStats, error) {
+	return s.upsert(ctx, order, func(ctx context.Context, o *domain.Order) error {
+		return s.storage.UpsertIf(ctx, o, p)
+	})
+}
+
+func (s *Service) upsert(ctx context.Context, order *domain.Order, write func(context.Context, *domain.Order) error) (UpsertStats, error) {
 	var st UpsertStats
 
 	t0 := time.Now()
-	if err := s.storage.Upsert(ctx, order); err != nil {
+	if err := write(ctx, order); err != nil {
+		if errors.Is(err, domain.ErrVersionMismatch) {
+			return st, err
+		}
 		s.logger.Error(
 			"Error while upserting order in db",
 			zap.Error(err),
@@ -50,7 +154,16 @@ func (s *Service) UpsertWithStats(ctx context.Context, order *domain.Order) (Ups
 	}
 	st.DBWriteMs = convertToMs(t0)
 
+	if s.negative != nil {
+		s.negative.Remove(order.OrderUID)
+	}
+	if s.stale != nil {
+		s.stale.Remove(order.OrderUID)
+	}
 	s.cache.Set(order)
+	if s.events != nil {
+		s.events.Publish(order)
+	}
 
 	s.metrics.ObserveUpsert(st.DBWriteMs)
 	s.logger.Info("Order upserted",
@@ -71,6 +184,40 @@ func (s *Service) GetByUID(ctx context.Context, uid string) (*domain.Order, erro
 	return o, err
 }
 
+// EncodedOrder is a cached order already encoded as a JSON response body.
+type EncodedOrder struct {
+	Body      []byte
+	Gzip      bool // Body is gzip-compressed
+	Version   int64
+	UpdatedAt *time.Time
+}
+
+// GetEncodedWithStats serves a cache hit as its stored response body, gzipped if
+// acceptGzip and the cache keeps gzipped bodies. It reports false when the body
+// cannot be served this way (a miss, a stale entry or encoding off); the caller
+// falls back to GetByUIDWithStats.
+func (s *Service) GetEncodedWithStats(uid string, acceptGzip bool) (EncodedOrder, LookupStats, bool) {
+	var st LookupStats
+
+	tCacheStart := time.Now()
+	enc, ok := s.cache.GetEncoded(uid, acceptGzip)
+	if !ok {
+		return EncodedOrder{}, st, false
+	}
+	st.Source = SourceCache
+	st.CacheMs = convertToMs(tCacheStart)
+	s.metrics.IncCacheHit()
+	s.metrics.ObserveLookup(string(st.Source), st.CacheMs, 0)
+
+	s.logger.Info("Order fetched from cache",
+		zap.String("order_uid", uid),
+		zap.Bool("encoded", true),
+		zap.Float64("cache_ms", st.CacheMs),
+	)
+
+	return EncodedOrder{Body: enc.Body, Gzip: enc.Gzip, Version: enc.Version, UpdatedAt: enc.UpdatedAt}, st, true
+}
+
 func (s *Service) GetByUIDWithStats(ctx context.Context, uid string) (*domain.Order, LookupStats, error) {
 	var st LookupStats
 
@@ -90,12 +237,44 @@ func (s *Service) GetByUIDWithStats(ctx context.Context, uid string) (*domain.Or
 		return order, st, nil
 	}
 
-	// Try DB
 	s.metrics.IncCacheMiss()
+
+	// Known to be missing
+	var epoch uint64
+	if s.negative != nil {
+		if s.negative.Has(uid) {
+			st.Source = SourceNegative
+			st.CacheMs = convertToMs(tCacheStart)
+			s.metrics.IncNegativeCacheHit()
+			s.metrics.ObserveLookup(string(st.Source), st.CacheMs, 0)
+
+			s.logger.Info("Order is known to be missing",
+				zap.String("order_uid", uid),
+				zap.Float64("cache_ms", st.CacheMs),
+			)
+			return nil, st, domain.ErrNotFound
+		}
+		s.metrics.IncNegativeCacheMiss()
+		epoch = s.negative.Epoch()
+	}
 	st.CacheMs = convertToMs(tCacheStart)
 
+	// Try DB, one read per UID at a time. Only the read itself consults and
+	// informs the circuit breaker; callers sharing it just take its result.
 	tDbStart := time.Now()
-	order, err := s.storage.GetByUID(ctx, uid)
+	order, shared, err := s.flight.do(ctx, uid, func(ctx context.Context) (*domain.Order, error) {
+		order, err := s.getByUID(ctx, uid)
+		switch {
+		case err == nil:
+			s.cache.Set(order)
+		case s.negative != nil && errors.Is(err, domain.ErrNotFound):
+			s.negative.Add(uid, epoch)
+		}
+		return order, err
+	})
+	if s.stale != nil && isRefused(err) {
+		return s.getStale(uid, st, err)
+	}
 	if err != nil {
 		s.logger.Error(
 			"Can't find order",
@@ -107,17 +286,60 @@ func (s *Service) GetByUIDWithStats(ctx context.Context, uid string) (*domain.Or
 	}
 
 	st.Source = SourceDB
+	if shared {
+		st.Source = SourceCoalesced
+	}
 	st.DBMs = convertToMs(tDbStart)
 
-	s.cache.Set(order)
-
 	// metrics
 	s.metrics.ObserveLookup(string(st.Source), st.CacheMs, st.DBMs)
 	s.logger.Info("Order fetched from DB",
 		zap.String("order_uid", uid),
+		zap.Bool("coalesced", shared),
 		zap.Float64("cache_ms", st.CacheMs),
 		zap.Float64("db_ms", st.DBMs),
 	)
 
 	return order, st, nil
 }
+
+// getByUID is getByUIDs for one order; storage reporting it missing counts as
+// a success.
+func (s *Service) getByUID(ctx context.Context, uid string) (*domain.Order, error) {
+	if s.db == nil {
+		return s.storage.GetByUID(ctx, uid)
+	}
+	if err := s.db.Allow(); err != nil {
+		return nil, refused(err)
+	}
+	order, err := s.storage.GetByUID(ctx, uid)
+	switch {
+	case err == nil || errors.Is(err, domain.ErrNotFound):
+		s.db.Success()
+	case ctx.Err() == nil:
+		s.db.Failure()
+		return nil, fmt.Errorf("%w: %v", ErrStorageUnavailable, err)
+	}
+	return order, err
+}
+
+// getStale serves uid from the stale copies after the circuit breaker refused
+// the read with cause, or returns cause if there is none.
+func (s *Service) getStale(uid string, st LookupStats, cause error) (*domain.Order, LookupStats, error) {
+	order, ok := s.stale.Get(uid)
+	if !ok {
+		s.logger.Error("Can't find order, storage unavailable",
+			zap.String("order_uid", uid),
+			zap.Error(cause),
+		)
+		return nil, st, cause
+	}
+
+	st.Source = SourceStale
+	s.metrics.ObserveLookup(string(st.Source), st.CacheMs, 0)
+	s.logger.Warn("Order served stale, storage unavailable",
+		zap.String("order_uid", uid),
+		zap.Error(cause),
+	)
+	return order, st, nil
+}
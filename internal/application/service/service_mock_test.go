@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	cache "github.com/TemirB/wb-tech-L0/internal/cache"
 	domain "github.com/TemirB/wb-tech-L0/internal/domain"
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,6 +36,18 @@ func (m *MockCache) EXPECT() *MockCacheMockRecorder {
 	return m.recorder
 }
 
+// CustomerListed mocks base method.
+func (m *MockCache) CustomerListed(arg0 *cache.CustomerWatch, arg1 int) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "CustomerListed", arg0, arg1)
+}
+
+// CustomerListed indicates an expected call of CustomerListed.
+func (mr *MockCacheMockRecorder) CustomerListed(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CustomerListed", reflect.TypeOf((*MockCache)(nil).CustomerListed), arg0, arg1)
+}
+
 // Get mocks base method.
 func (m *MockCache) Get(arg0 string) (*domain.Order, bool) {
 	m.ctrl.T.Helper()
@@ -50,6 +63,51 @@ func (mr *MockCacheMockRecorder) Get(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockCache)(nil).Get), arg0)
 }
 
+// GetByTrackNumber mocks base method.
+func (m *MockCache) GetByTrackNumber(arg0 string) (*domain.Order, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByTrackNumber", arg0)
+	ret0, _ := ret[0].(*domain.Order)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// GetByTrackNumber indicates an expected call of GetByTrackNumber.
+func (mr *MockCacheMockRecorder) GetByTrackNumber(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByTrackNumber", reflect.TypeOf((*MockCache)(nil).GetByTrackNumber), arg0)
+}
+
+// GetEncoded mocks base method.
+func (m *MockCache) GetEncoded(uid string, acceptGzip bool) (cache.Encoded, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEncoded", uid, acceptGzip)
+	ret0, _ := ret[0].(cache.Encoded)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// GetEncoded indicates an expected call of GetEncoded.
+func (mr *MockCacheMockRecorder) GetEncoded(uid, acceptGzip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEncoded", reflect.TypeOf((*MockCache)(nil).GetEncoded), uid, acceptGzip)
+}
+
+// ListByCustomer mocks base method.
+func (m *MockCache) ListByCustomer(arg0 string) ([]*domain.Order, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByCustomer", arg0)
+	ret0, _ := ret[0].([]*domain.Order)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// ListByCustomer indicates an expected call of ListByCustomer.
+func (mr *MockCacheMockRecorder) ListByCustomer(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByCustomer", reflect.TypeOf((*MockCache)(nil).ListByCustomer), arg0)
+}
+
 // Set mocks base method.
 func (m *MockCache) Set(arg0 *domain.Order) {
 	m.ctrl.T.Helper()
@@ -62,6 +120,241 @@ func (mr *MockCacheMockRecorder) Set(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockCache)(nil).Set), arg0)
 }
 
+// WatchCustomer mocks base method.
+func (m *MockCache) WatchCustomer(arg0 string) *cache.CustomerWatch {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchCustomer", arg0)
+	ret0, _ := ret[0].(*cache.CustomerWatch)
+	return ret0
+}
+
+// WatchCustomer indicates an expected call of WatchCustomer.
+func (mr *MockCacheMockRecorder) WatchCustomer(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchCustomer", reflect.TypeOf((*MockCache)(nil).WatchCustomer), arg0)
+}
+
+// MockNegativeCache is a mock of NegativeCache interface.
+type MockNegativeCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockNegativeCacheMockRecorder
+}
+
+// MockNegativeCacheMockRecorder is the mock recorder for MockNegativeCache.
+type MockNegativeCacheMockRecorder struct {
+	mock *MockNegativeCache
+}
+
+// NewMockNegativeCache creates a new mock instance.
+func NewMockNegativeCache(ctrl *gomock.Controller) *MockNegativeCache {
+	mock := &MockNegativeCache{ctrl: ctrl}
+	mock.recorder = &MockNegativeCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNegativeCache) EXPECT() *MockNegativeCacheMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method.
+func (m *MockNegativeCache) Add(uid string, epoch uint64) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Add", uid, epoch)
+}
+
+// Add indicates an expected call of Add.
+func (mr *MockNegativeCacheMockRecorder) Add(uid, epoch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockNegativeCache)(nil).Add), uid, epoch)
+}
+
+// Epoch mocks base method.
+func (m *MockNegativeCache) Epoch() uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Epoch")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// Epoch indicates an expected call of Epoch.
+func (mr *MockNegativeCacheMockRecorder) Epoch() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Epoch", reflect.TypeOf((*MockNegativeCache)(nil).Epoch))
+}
+
+// Has mocks base method.
+func (m *MockNegativeCache) Has(uid string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Has", uid)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Has indicates an expected call of Has.
+func (mr *MockNegativeCacheMockRecorder) Has(uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Has", reflect.TypeOf((*MockNegativeCache)(nil).Has), uid)
+}
+
+// Remove mocks base method.
+func (m *MockNegativeCache) Remove(uid string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Remove", uid)
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockNegativeCacheMockRecorder) Remove(uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockNegativeCache)(nil).Remove), uid)
+}
+
+// MockStaleCache is a mock of StaleCache interface.
+type MockStaleCache struct {
+	ctrl     *gomock.Controller
+	recorder *MockStaleCacheMockRecorder
+}
+
+// MockStaleCacheMockRecorder is the mock recorder for MockStaleCache.
+type MockStaleCacheMockRecorder struct {
+	mock *MockStaleCache
+}
+
+// NewMockStaleCache creates a new mock instance.
+func NewMockStaleCache(ctrl *gomock.Controller) *MockStaleCache {
+	mock := &MockStaleCache{ctrl: ctrl}
+	mock.recorder = &MockStaleCacheMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStaleCache) EXPECT() *MockStaleCacheMockRecorder {
+	return m.recorder
+}
+
+// Get mocks base method.
+func (m *MockStaleCache) Get(uid string) (*domain.Order, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", uid)
+	ret0, _ := ret[0].(*domain.Order)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockStaleCacheMockRecorder) Get(uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockStaleCache)(nil).Get), uid)
+}
+
+// Remove mocks base method.
+func (m *MockStaleCache) Remove(uid string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Remove", uid)
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockStaleCacheMockRecorder) Remove(uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockStaleCache)(nil).Remove), uid)
+}
+
+// MockDBHealth is a mock of DBHealth interface.
+type MockDBHealth struct {
+	ctrl     *gomock.Controller
+	recorder *MockDBHealthMockRecorder
+}
+
+// MockDBHealthMockRecorder is the mock recorder for MockDBHealth.
+type MockDBHealthMockRecorder struct {
+	mock *MockDBHealth
+}
+
+// NewMockDBHealth creates a new mock instance.
+func NewMockDBHealth(ctrl *gomock.Controller) *MockDBHealth {
+	mock := &MockDBHealth{ctrl: ctrl}
+	mock.recorder = &MockDBHealthMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDBHealth) EXPECT() *MockDBHealthMockRecorder {
+	return m.recorder
+}
+
+// Allow mocks base method.
+func (m *MockDBHealth) Allow() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Allow")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Allow indicates an expected call of Allow.
+func (mr *MockDBHealthMockRecorder) Allow() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Allow", reflect.TypeOf((*MockDBHealth)(nil).Allow))
+}
+
+// Failure mocks base method.
+func (m *MockDBHealth) Failure() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Failure")
+}
+
+// Failure indicates an expected call of Failure.
+func (mr *MockDBHealthMockRecorder) Failure() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Failure", reflect.TypeOf((*MockDBHealth)(nil).Failure))
+}
+
+// Success mocks base method.
+func (m *MockDBHealth) Success() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Success")
+}
+
+// Success indicates an expected call of Success.
+func (mr *MockDBHealthMockRecorder) Success() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Success", reflect.TypeOf((*MockDBHealth)(nil).Success))
+}
+
+// MockPublisher is a mock of Publisher interface.
+type MockPublisher struct {
+	ctrl     *gomock.Controller
+	recorder *MockPublisherMockRecorder
+}
+
+// MockPublisherMockRecorder is the mock recorder for MockPublisher.
+type MockPublisherMockRecorder struct {
+	mock *MockPublisher
+}
+
+// NewMockPublisher creates a new mock instance.
+func NewMockPublisher(ctrl *gomock.Controller) *MockPublisher {
+	mock := &MockPublisher{ctrl: ctrl}
+	mock.recorder = &MockPublisherMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPublisher) EXPECT() *MockPublisherMockRecorder {
+	return m.recorder
+}
+
+// Publish mocks base method.
+func (m *MockPublisher) Publish(arg0 *domain.Order) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Publish", arg0)
+}
+
+// Publish indicates an expected call of Publish.
+func (mr *MockPublisherMockRecorder) Publish(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Publish", reflect.TypeOf((*MockPublisher)(nil).Publish), arg0)
+}
+
 // MockStorage is a mock of Storage interface.
 type MockStorage struct {
 	ctrl     *gomock.Controller
@@ -85,6 +378,36 @@ func (m *MockStorage) EXPECT() *MockStorageMockRecorder {
 	return m.recorder
 }
 
+// CustomerOrderIDs mocks base method.
+func (m *MockStorage) CustomerOrderIDs(ctx context.Context, customerID string, limit int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CustomerOrderIDs", ctx, customerID, limit)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CustomerOrderIDs indicates an expected call of CustomerOrderIDs.
+func (mr *MockStorageMockRecorder) CustomerOrderIDs(ctx, customerID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CustomerOrderIDs", reflect.TypeOf((*MockStorage)(nil).CustomerOrderIDs), ctx, customerID, limit)
+}
+
+// GetByTrackNumber mocks base method.
+func (m *MockStorage) GetByTrackNumber(arg0 context.Context, arg1 string) (*domain.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByTrackNumber", arg0, arg1)
+	ret0, _ := ret[0].(*domain.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByTrackNumber indicates an expected call of GetByTrackNumber.
+func (mr *MockStorageMockRecorder) GetByTrackNumber(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByTrackNumber", reflect.TypeOf((*MockStorage)(nil).GetByTrackNumber), arg0, arg1)
+}
+
 // GetByUID mocks base method.
 func (m *MockStorage) GetByUID(arg0 context.Context, arg1 string) (*domain.Order, error) {
 	m.ctrl.T.Helper()
@@ -100,6 +423,21 @@ func (mr *MockStorageMockRecorder) GetByUID(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUID", reflect.TypeOf((*MockStorage)(nil).GetByUID), arg0, arg1)
 }
 
+// GetByUIDs mocks base method.
+func (m *MockStorage) GetByUIDs(arg0 context.Context, arg1 []string) (map[string]*domain.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByUIDs", arg0, arg1)
+	ret0, _ := ret[0].(map[string]*domain.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByUIDs indicates an expected call of GetByUIDs.
+func (mr *MockStorageMockRecorder) GetByUIDs(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUIDs", reflect.TypeOf((*MockStorage)(nil).GetByUIDs), arg0, arg1)
+}
+
 // Upsert mocks base method.
 func (m *MockStorage) Upsert(arg0 context.Context, arg1 *domain.Order) error {
 	m.ctrl.T.Helper()
@@ -113,3 +451,17 @@ func (mr *MockStorageMockRecorder) Upsert(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockStorage)(nil).Upsert), arg0, arg1)
 }
+
+// UpsertIf mocks base method.
+func (m *MockStorage) UpsertIf(arg0 context.Context, arg1 *domain.Order, arg2 domain.Precondition) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertIf", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertIf indicates an expected call of UpsertIf.
+func (mr *MockStorageMockRecorder) UpsertIf(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertIf", reflect.TypeOf((*MockStorage)(nil).UpsertIf), arg0, arg1, arg2)
+}
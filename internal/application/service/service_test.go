@@ -2,13 +2,16 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
+	cachepkg "github.com/TemirB/wb-tech-L0/internal/cache"
 	"github.com/TemirB/wb-tech-L0/internal/domain"
 	"github.com/TemirB/wb-tech-L0/internal/observability"
 )
@@ -39,7 +42,7 @@ func TestUpsert(t *testing.T) {
 
 				storage.EXPECT().Upsert(ctx, order).Return(nil)
 				cache.EXPECT().Set(order)
-				return NewService(cache, storage, l, m)
+				return NewService(cache, nil, storage, l, m)
 			},
 		},
 		{
@@ -48,7 +51,7 @@ func TestUpsert(t *testing.T) {
 			setupMocks: func() *Service {
 				storage := NewMockStorage(ctrl)
 				storage.EXPECT().Upsert(ctx, order).Return(pgx.ErrNoRows)
-				return NewService(nil, storage, l, m)
+				return NewService(nil, nil, storage, l, m)
 			},
 
 			wantErr: pgx.ErrNoRows,
@@ -99,7 +102,7 @@ func TestGetByUID(t *testing.T) {
 
 				cache.EXPECT().Get(testUID).Return(order, true)
 
-				return NewService(cache, nil, l, m)
+				return NewService(cache, nil, nil, l, m)
 			},
 
 			expected: order,
@@ -112,10 +115,10 @@ func TestGetByUID(t *testing.T) {
 				storage := NewMockStorage(ctrl)
 
 				cache.EXPECT().Get(testUID).Return(nil, false)
-				storage.EXPECT().GetByUID(ctx, testUID).Return(order, nil)
+				storage.EXPECT().GetByUID(gomock.Any(), testUID).Return(order, nil)
 				cache.EXPECT().Set(order)
 
-				return NewService(cache, storage, l, m)
+				return NewService(cache, nil, storage, l, m)
 			},
 
 			expected: order,
@@ -128,9 +131,9 @@ func TestGetByUID(t *testing.T) {
 				storage := NewMockStorage(ctrl)
 
 				cache.EXPECT().Get(testUID).Return(nil, false)
-				storage.EXPECT().GetByUID(ctx, testUID).Return(nil, pgx.ErrNoRows)
+				storage.EXPECT().GetByUID(gomock.Any(), testUID).Return(nil, pgx.ErrNoRows)
 
-				return NewService(cache, storage, l, m)
+				return NewService(cache, nil, storage, l, m)
 			},
 
 			wantErr: pgx.ErrNoRows,
@@ -223,3 +226,177 @@ func TestGetByUID(t *testing.T) {
 // 		t.Fatalf("expected error, got nil")
 // 	}
 // }
+
+func TestGetByUIDNegativeCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	l := zap.NewNop()
+	m := observability.NewNoop()
+
+	testCases := []struct {
+		name       string
+		setupMocks func() *Service
+		source     LookupSource
+	}{
+		{
+			name: "Known missing order skips DB",
+
+			setupMocks: func() *Service {
+				cache := NewMockCache(ctrl)
+				negative := NewMockNegativeCache(ctrl)
+
+				cache.EXPECT().Get("missing").Return(nil, false)
+				negative.EXPECT().Has("missing").Return(true)
+
+				return NewService(cache, negative, nil, l, m)
+			},
+			source: SourceNegative,
+		},
+		{
+			name: "Not found in DB is remembered",
+
+			setupMocks: func() *Service {
+				cache := NewMockCache(ctrl)
+				negative := NewMockNegativeCache(ctrl)
+				storage := NewMockStorage(ctrl)
+
+				cache.EXPECT().Get("missing").Return(nil, false)
+				negative.EXPECT().Has("missing").Return(false)
+				negative.EXPECT().Epoch().Return(uint64(7))
+				storage.EXPECT().GetByUID(gomock.Any(), "missing").Return(nil, domain.ErrNotFound)
+				negative.EXPECT().Add("missing", uint64(7))
+
+				return NewService(cache, negative, storage, l, m)
+			},
+		},
+		{
+			name: "Other DB errors are not remembered",
+
+			setupMocks: func() *Service {
+				cache := NewMockCache(ctrl)
+				negative := NewMockNegativeCache(ctrl)
+				storage := NewMockStorage(ctrl)
+
+				cache.EXPECT().Get("missing").Return(nil, false)
+				negative.EXPECT().Has("missing").Return(false)
+				negative.EXPECT().Epoch().Return(uint64(7))
+				storage.EXPECT().GetByUID(gomock.Any(), "missing").Return(nil, pgx.ErrNoRows)
+
+				return NewService(cache, negative, storage, l, m)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tc.setupMocks()
+			order, st, err := s.GetByUIDWithStats(ctx, "missing")
+
+			require.Error(t, err)
+			require.Nil(t, order)
+			require.Equal(t, tc.source, st.Source)
+		})
+	}
+}
+
+func TestUpsertClearsNegativeCache(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	order := &domain.Order{OrderUID: "123"}
+
+	storage := NewMockStorage(ctrl)
+	cache := NewMockCache(ctrl)
+	negative := NewMockNegativeCache(ctrl)
+
+	gomock.InOrder(
+		storage.EXPECT().Upsert(ctx, order).Return(nil),
+		negative.EXPECT().Remove("123"),
+		cache.EXPECT().Set(order),
+	)
+
+	s := NewService(cache, negative, storage, zap.NewNop(), observability.NewNoop())
+	require.NoError(t, s.Upsert(ctx, order))
+}
+
+func TestUpsertPublishes(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	order := &domain.Order{OrderUID: "123"}
+
+	storage := NewMockStorage(ctrl)
+	cache := NewMockCache(ctrl)
+	events := NewMockPublisher(ctrl)
+
+	gomock.InOrder(
+		storage.EXPECT().Upsert(ctx, order).Return(nil),
+		cache.EXPECT().Set(order),
+		events.EXPECT().Publish(order),
+		storage.EXPECT().Upsert(ctx, order).Return(pgx.ErrNoRows),
+	)
+
+	s := NewService(cache, nil, storage, zap.NewNop(), observability.NewNoop())
+	s.EnablePublishing(events)
+	require.NoError(t, s.Upsert(ctx, order))
+	// A failed upsert publishes nothing.
+	require.ErrorIs(t, s.Upsert(ctx, order), pgx.ErrNoRows)
+}
+
+func TestGetEncodedWithStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		acceptGzip bool
+		body       []byte
+		gzipped    bool
+		ok         bool
+	}{
+		{name: "json hit", body: []byte(`{"order_uid":"123"}`), ok: true},
+		{name: "gzip hit", acceptGzip: true, body: []byte{0x1f, 0x8b}, gzipped: true, ok: true},
+		{name: "gzip wanted, json kept", acceptGzip: true, body: []byte(`{}`), ok: true},
+		{name: "not served encoded", acceptGzip: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			cache := NewMockCache(ctrl)
+			updated := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+			cache.EXPECT().GetEncoded("123", tt.acceptGzip).
+				Return(cachepkg.Encoded{Body: tt.body, Gzip: tt.gzipped, Version: 3, UpdatedAt: &updated}, tt.ok)
+
+			s := NewService(cache, nil, nil, zap.NewNop(), observability.NewNoop())
+			enc, st, ok := s.GetEncodedWithStats("123", tt.acceptGzip)
+			require.Equal(t, tt.ok, ok)
+			if !ok {
+				require.Empty(t, st.Source)
+				return
+			}
+			require.Equal(t, SourceCache, st.Source)
+			require.Equal(t, EncodedOrder{Body: tt.body, Gzip: tt.gzipped, Version: 3, UpdatedAt: &updated}, enc)
+		})
+	}
+}
+
+func TestUpsertIf(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	ctx := context.Background()
+	order := &domain.Order{OrderUID: "123"}
+	p := domain.Precondition{Versions: []int64{3}}
+
+	storage := NewMockStorage(ctrl)
+	cache := NewMockCache(ctrl)
+
+	gomock.InOrder(
+		storage.EXPECT().UpsertIf(ctx, order, p).Return(nil),
+		cache.EXPECT().Set(order),
+		storage.EXPECT().UpsertIf(ctx, order, p).Return(fmt.Errorf("order 123: %w", domain.ErrVersionMismatch)),
+	)
+
+	s := NewService(cache, nil, storage, zap.NewNop(), observability.NewNoop())
+	_, err := s.UpsertIfWithStats(ctx, order, p)
+	require.NoError(t, err)
+	_, err = s.UpsertIfWithStats(ctx, order, p)
+	require.ErrorIs(t, err, domain.ErrVersionMismatch)
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"github.com/TemirB/wb-tech-L0/internal/pkg/breaker"
+)
+
+func TestGetByUIDStale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := context.Background()
+
+	l := zap.NewNop()
+	m := observability.NewNoop()
+	order := &domain.Order{OrderUID: "123"}
+	errDB := errors.New("connection refused")
+
+	testCases := []struct {
+		name       string
+		setupMocks func() *Service
+		want       *domain.Order
+		source     LookupSource
+		wantErr    error
+	}{
+		{
+			name: "Breaker open serves stale copy without DB",
+
+			setupMocks: func() *Service {
+				cache := NewMockCache(ctrl)
+				stale := NewMockStaleCache(ctrl)
+				db := NewMockDBHealth(ctrl)
+
+				cache.EXPECT().Get("123").Return(nil, false)
+				db.EXPECT().Allow().Return(breaker.ErrOpenState)
+				stale.EXPECT().Get("123").Return(order, true)
+
+				s := NewService(cache, nil, nil, l, m)
+				s.EnableStaleReads(stale, db)
+				return s
+			},
+			want:   order,
+			source: SourceStale,
+		},
+		{
+			name: "Breaker open without stale copy",
+
+			setupMocks: func() *Service {
+				cache := NewMockCache(ctrl)
+				stale := NewMockStaleCache(ctrl)
+				db := NewMockDBHealth(ctrl)
+
+				cache.EXPECT().Get("123").Return(nil, false)
+				db.EXPECT().Allow().Return(breaker.ErrOpenState)
+				stale.EXPECT().Get("123").Return(nil, false)
+
+				s := NewService(cache, nil, nil, l, m)
+				s.EnableStaleReads(stale, db)
+				return s
+			},
+			wantErr: ErrStorageUnavailable,
+		},
+		{
+			name: "DB error is a failure and is not served stale",
+
+			setupMocks: func() *Service {
+				cache := NewMockCache(ctrl)
+				storage := NewMockStorage(ctrl)
+				stale := NewMockStaleCache(ctrl)
+				db := NewMockDBHealth(ctrl)
+
+				cache.EXPECT().Get("123").Return(nil, false)
+				db.EXPECT().Allow().Return(nil)
+				storage.EXPECT().GetByUID(gomock.Any(), "123").Return(nil, errDB)
+				db.EXPECT().Failure()
+
+				s := NewService(cache, nil, storage, l, m)
+				s.EnableStaleReads(stale, db)
+				return s
+			},
+			wantErr: ErrStorageUnavailable,
+		},
+		{
+			name: "Not found is a success",
+
+			setupMocks: func() *Service {
+				cache := NewMockCache(ctrl)
+				storage := NewMockStorage(ctrl)
+				stale := NewMockStaleCache(ctrl)
+				db := NewMockDBHealth(ctrl)
+
+				cache.EXPECT().Get("123").Return(nil, false)
+				db.EXPECT().Allow().Return(nil)
+				storage.EXPECT().GetByUID(gomock.Any(), "123").Return(nil, domain.ErrNotFound)
+				db.EXPECT().Success()
+
+				s := NewService(cache, nil, storage, l, m)
+				s.EnableStaleReads(stale, db)
+				return s
+			},
+			wantErr: domain.ErrNotFound,
+		},
+		{
+			name: "Healthy DB is preferred",
+
+			setupMocks: func() *Service {
+				cache := NewMockCache(ctrl)
+				storage := NewMockStorage(ctrl)
+				stale := NewMockStaleCache(ctrl)
+				db := NewMockDBHealth(ctrl)
+
+				cache.EXPECT().Get("123").Return(nil, false)
+				db.EXPECT().Allow().Return(nil)
+				storage.EXPECT().GetByUID(gomock.Any(), "123").Return(order, nil)
+				cache.EXPECT().Set(order)
+				db.EXPECT().Success()
+
+				s := NewService(cache, nil, storage, l, m)
+				s.EnableStaleReads(stale, db)
+				return s
+			},
+			want:   order,
+			source: SourceDB,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := tc.setupMocks()
+			got, st, err := s.GetByUIDWithStats(ctx, "123")
+
+			require.ErrorIs(t, err, tc.wantErr)
+			require.Equal(t, tc.want, got)
+			require.Equal(t, tc.source, st.Source)
+		})
+	}
+}
+
+func TestUpsertClearsStaleCopy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	ctx := context.Background()
+	order := &domain.Order{OrderUID: "123"}
+
+	storage := NewMockStorage(ctrl)
+	cache := NewMockCache(ctrl)
+	stale := NewMockStaleCache(ctrl)
+
+	gomock.InOrder(
+		storage.EXPECT().Upsert(ctx, order).Return(nil),
+		stale.EXPECT().Remove("123"),
+		cache.EXPECT().Set(order),
+	)
+
+	s := NewService(cache, nil, storage, zap.NewNop(), observability.NewNoop())
+	s.EnableStaleReads(stale, NewMockDBHealth(ctrl))
+	require.NoError(t, s.Upsert(ctx, order))
+}
@@ -5,8 +5,11 @@ import "time"
 type LookupSource string
 
 const (
-	SourceCache LookupSource = "cache"
-	SourceDB    LookupSource = "db"
+	SourceCache     LookupSource = "cache"
+	SourceDB        LookupSource = "db"
+	SourceNegative  LookupSource = "negative"  // known to be missing, no DB read
+	SourceCoalesced LookupSource = "coalesced" // shared another request's DB read
+	SourceStale     LookupSource = "stale"     // evicted copy, storage unavailable
 )
 
 type LookupStats struct {
@@ -15,6 +18,26 @@ type LookupStats struct {
 	DBMs    float64
 }
 
+// ListStats describes a listing: how many orders came from the cache and how
+// many were loaded from storage.
+type ListStats struct {
+	CacheHits int
+	DBLoads   int
+	Partial   bool // storage failed; only cached orders are listed
+	CacheMs   float64
+	DBMs      float64
+}
+
+// BatchStats describes a batch lookup: how many orders came from the cache,
+// were loaded from storage or served stale while storage was unavailable.
+type BatchStats struct {
+	CacheHits int
+	DBLoads   int
+	Stale     int
+	CacheMs   float64
+	DBMs      float64
+}
+
 type UpsertStats struct {
 	DBWriteMs float64
 }
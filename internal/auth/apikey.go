@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+)
+
+// APIKeyHeader carries a static API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys authenticates requests by the X-API-Key header. Only SHA-256 hashes
+// of the keys are kept, so the configuration does not leak them.
+type APIKeys struct {
+	byHash map[[sha256.Size]byte]Identity
+}
+
+func NewAPIKeys(keys []config.APIKey) (*APIKeys, error) {
+	a := &APIKeys{byHash: make(map[[sha256.Size]byte]Identity, len(keys))}
+	for _, k := range keys {
+		role, err := ParseRole(k.Role)
+		if err != nil {
+			return nil, fmt.Errorf("api key %q: %w", k.Name, err)
+		}
+		var sum [sha256.Size]byte
+		if n, err := hex.Decode(sum[:], []byte(k.Hash)); err != nil || n != sha256.Size {
+			return nil, fmt.Errorf("api key %q: hash is not a hex SHA-256", k.Name)
+		}
+		a.byHash[sum] = Identity{Subject: k.Name, Role: role, Method: "api_key"}
+	}
+	return a, nil
+}
+
+// HashAPIKey returns the hex SHA-256 of key, as AUTH_API_KEYS expects it.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (a *APIKeys) Authenticate(r *http.Request) (Identity, error) {
+	key := strings.TrimSpace(r.Header.Get(APIKeyHeader))
+	if key == "" {
+		return Identity{}, ErrNoCredentials
+	}
+	// The lookup compares digests, which reveals nothing useful about the key.
+	id, ok := a.byHash[sha256.Sum256([]byte(key))]
+	if !ok {
+		return Identity{}, ErrInvalidCredentials
+	}
+	return id, nil
+}
@@ -0,0 +1,120 @@
+// Package auth identifies API callers by static API keys or HS256-signed JWTs,
+// both verified without calling out to another service.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+)
+
+var (
+	// ErrNoCredentials means the request carries no credentials an
+	// Authenticator understands.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means the credentials were presented but rejected.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Role is what a caller may do. Each role includes the ones below it.
+type Role uint8
+
+const (
+	RoleNone Role = iota
+	RoleReader
+	RoleWriter
+	RoleAdmin
+)
+
+// ParseRole converts a config.Role* name.
+func ParseRole(s string) (Role, error) {
+	switch s {
+	case config.RoleReader:
+		return RoleReader, nil
+	case config.RoleWriter:
+		return RoleWriter, nil
+	case config.RoleAdmin:
+		return RoleAdmin, nil
+	}
+	return RoleNone, fmt.Errorf("unknown role %q", s)
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleReader:
+		return config.RoleReader
+	case RoleWriter:
+		return config.RoleWriter
+	case RoleAdmin:
+		return config.RoleAdmin
+	}
+	return "none"
+}
+
+// Allows reports whether r includes need.
+func (r Role) Allows(need Role) bool {
+	return r >= need
+}
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Subject string // API key name or JWT sub claim
+	Role    Role
+	Method  string // "api_key" or "jwt"
+}
+
+// Authenticator identifies the caller of a request. It returns
+// ErrNoCredentials when the request carries none of its kind, so that another
+// Authenticator may try.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+// Chain tries each Authenticator in turn; the first to find credentials decides.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (Identity, error) {
+	for _, a := range c {
+		id, err := a.Authenticate(r)
+		if !errors.Is(err, ErrNoCredentials) {
+			return id, err
+		}
+	}
+	return Identity{}, ErrNoCredentials
+}
+
+// New builds the authenticators enabled in cfg, or returns nil when
+// cfg.Enabled is false.
+func New(cfg config.Auth) (Authenticator, error) {
+	var chain Chain
+	if len(cfg.APIKeys) > 0 {
+		keys, err := NewAPIKeys(cfg.APIKeys)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, keys)
+	}
+	if cfg.JWTSecret != "" {
+		chain = append(chain, NewJWT(cfg))
+	}
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	return chain, nil
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity stored by WithIdentity.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestRoleAllows(t *testing.T) {
+	require.True(t, RoleAdmin.Allows(RoleWriter))
+	require.True(t, RoleWriter.Allows(RoleReader))
+	require.True(t, RoleReader.Allows(RoleReader))
+	require.False(t, RoleReader.Allows(RoleWriter))
+	require.False(t, RoleNone.Allows(RoleReader))
+
+	for _, name := range []string{config.RoleReader, config.RoleWriter, config.RoleAdmin} {
+		r, err := ParseRole(name)
+		require.NoError(t, err)
+		require.Equal(t, name, r.String())
+	}
+	_, err := ParseRole("root")
+	require.Error(t, err)
+}
+
+func TestAPIKeys(t *testing.T) {
+	keys, err := NewAPIKeys([]config.APIKey{
+		{Name: "ingest", Role: config.RoleWriter, Hash: HashAPIKey("s3cret")},
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err = keys.Authenticate(req)
+	require.ErrorIs(t, err, ErrNoCredentials)
+
+	req.Header.Set(APIKeyHeader, "s3cret")
+	id, err := keys.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, Identity{Subject: "ingest", Role: RoleWriter, Method: "api_key"}, id)
+
+	req.Header.Set(APIKeyHeader, "guess")
+	_, err = keys.Authenticate(req)
+	require.ErrorIs(t, err, ErrInvalidCredentials)
+
+	_, err = NewAPIKeys([]config.APIKey{{Name: "x", Role: config.RoleReader, Hash: "abc"}})
+	require.Error(t, err)
+}
+
+func TestJWT(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	j := NewJWT(config.Auth{
+		JWTSecret:   testSecret,
+		JWTIssuer:   "orders-auth",
+		JWTAudience: "orders",
+		JWTLeeway:   time.Minute,
+	})
+	j.now = func() time.Time { return now }
+
+	valid := Claims{
+		Subject:   "svc-a",
+		Role:      config.RoleReader,
+		Issuer:    "orders-auth",
+		Audience:  audience{"orders"},
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+
+	tests := []struct {
+		name    string
+		secret  string
+		claims  func(c *Claims)
+		token   string // used instead of signing claims
+		wantErr string
+	}{
+		{name: "valid"},
+		{name: "within leeway", claims: func(c *Claims) { c.ExpiresAt = now.Add(-30 * time.Second).Unix() }},
+		{name: "expired", claims: func(c *Claims) { c.ExpiresAt = now.Add(-2 * time.Minute).Unix() }, wantErr: "expired"},
+		{name: "no exp", claims: func(c *Claims) { c.ExpiresAt = 0 }, wantErr: "no exp"},
+		{name: "not yet valid", claims: func(c *Claims) { c.NotBefore = now.Add(time.Hour).Unix() }, wantErr: "not valid yet"},
+		{name: "wrong issuer", claims: func(c *Claims) { c.Issuer = "other" }, wantErr: "issuer"},
+		{name: "wrong audience", claims: func(c *Claims) { c.Audience = audience{"billing", "crm"} }, wantErr: "not meant for"},
+		{name: "unknown role", claims: func(c *Claims) { c.Role = "root" }, wantErr: "unknown role"},
+		{name: "wrong secret", secret: "another secret", wantErr: "bad signature"},
+		{
+			name:    "alg none",
+			token:   "eyJhbGciOiJub25lIn0.eyJzdWIiOiJ4Iiwicm9sZSI6ImFkbWluIiwiZXhwIjo5OTk5OTk5OTk5fQ.",
+			wantErr: `unsupported alg "none"`,
+		},
+		{name: "malformed", token: "abc", wantErr: "malformed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := tt.token
+			if token == "" {
+				c := valid
+				if tt.claims != nil {
+					tt.claims(&c)
+				}
+				secret := testSecret
+				if tt.secret != "" {
+					secret = tt.secret
+				}
+				var err error
+				token, err = SignJWT([]byte(secret), c)
+				require.NoError(t, err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			id, err := j.Authenticate(req)
+			if tt.wantErr != "" {
+				require.ErrorIs(t, err, ErrInvalidCredentials)
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, Identity{Subject: "svc-a", Role: RoleReader, Method: "jwt"}, id)
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	a, err := New(config.Auth{
+		APIKeys:   []config.APIKey{{Name: "ops", Role: config.RoleAdmin, Hash: HashAPIKey("k")}},
+		JWTSecret: testSecret,
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	_, err = a.Authenticate(req)
+	require.ErrorIs(t, err, ErrNoCredentials)
+
+	token, err := SignJWT([]byte(testSecret), Claims{Subject: "svc", Role: config.RoleWriter, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	id, err := a.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, "jwt", id.Method)
+
+	req.Header.Set(APIKeyHeader, "k")
+	id, err = a.Authenticate(req)
+	require.NoError(t, err)
+	require.Equal(t, Identity{Subject: "ops", Role: RoleAdmin, Method: "api_key"}, id)
+
+	none, err := New(config.Auth{})
+	require.NoError(t, err)
+	require.Nil(t, none)
+}
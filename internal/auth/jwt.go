@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+)
+
+// Claims are the JWT claims the API reads. Exp is required; Role must name a
+// config.Role*.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Role      string   `json:"role"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+// audience is the aud claim, a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	if bytes.HasPrefix(b, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*a = audience{s}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(a))
+}
+
+func (a audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// JWT authenticates "Authorization: Bearer <token>" with tokens signed with
+// HS256 under a shared secret.
+type JWT struct {
+	secret   []byte
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+func NewJWT(cfg config.Auth) *JWT {
+	return &JWT{
+		secret:   []byte(cfg.JWTSecret),
+		issuer:   cfg.JWTIssuer,
+		audience: cfg.JWTAudience,
+		leeway:   cfg.JWTLeeway,
+		now:      time.Now,
+	}
+}
+
+func (j *JWT) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+	claims, err := j.Verify(strings.TrimSpace(token))
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	role, err := ParseRole(claims.Role)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+	return Identity{Subject: claims.Subject, Role: role, Method: "jwt"}, nil
+}
+
+// Verify checks the signature and the time, issuer and audience claims of
+// token and returns its claims.
+func (j *JWT) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, fmt.Errorf("malformed token")
+	}
+
+	var h jwtHeader
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return Claims{}, fmt.Errorf("header: %w", err)
+	}
+	// Only HS256 is accepted, which rules out "none" and algorithm confusion.
+	if h.Alg != "HS256" {
+		return Claims{}, fmt.Errorf("unsupported alg %q", h.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Claims{}, fmt.Errorf("signature: %w", err)
+	}
+	if !hmac.Equal(sig, sign(j.secret, parts[0]+"."+parts[1])) {
+		return Claims{}, fmt.Errorf("bad signature")
+	}
+
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return Claims{}, fmt.Errorf("claims: %w", err)
+	}
+	now := j.now()
+	switch {
+	case c.ExpiresAt == 0:
+		return Claims{}, fmt.Errorf("no exp claim")
+	case now.After(time.Unix(c.ExpiresAt, 0).Add(j.leeway)):
+		return Claims{}, fmt.Errorf("token expired")
+	case c.NotBefore != 0 && now.Before(time.Unix(c.NotBefore, 0).Add(-j.leeway)):
+		return Claims{}, fmt.Errorf("token not valid yet")
+	case j.issuer != "" && c.Issuer != j.issuer:
+		return Claims{}, fmt.Errorf("unexpected issuer %q", c.Issuer)
+	case j.audience != "" && !slices.Contains(c.Audience, j.audience):
+		return Claims{}, fmt.Errorf("token is not meant for %q", j.audience)
+	}
+	return c, nil
+}
+
+// SignJWT issues an HS256 token for claims.
+func SignJWT(secret []byte, claims Claims) (string, error) {
+	h, err := json.Marshal(jwtHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sign(secret, signed)), nil
+}
+
+func sign(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
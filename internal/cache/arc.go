@@ -0,0 +1,75 @@
+package cache
+
+// arc is the Adaptive Replacement Cache (Megiddo and Modha). t1 holds keys seen
+// once recently, t2 keys seen at least twice; the ghost lists b1 and b2 remember
+// keys evicted from each. A miss that hits a ghost list shifts the target size
+// p of t1 towards the list that would have kept the key, so the split between
+// recency and frequency adapts to the workload.
+type arc struct {
+	capacity       int
+	p              int // target size of t1
+	t1, t2, b1, b2 *keyList
+}
+
+func newARC(capacity int) *arc {
+	return &arc{
+		capacity: capacity,
+		t1:       newKeyList(),
+		t2:       newKeyList(),
+		b1:       newKeyList(),
+		b2:       newKeyList(),
+	}
+}
+
+func (p *arc) Resize(capacity int) {
+	p.capacity = capacity
+	p.p = min(p.p, capacity)
+	p.b1.trim(capacity)
+	p.b2.trim(capacity)
+}
+
+func (p *arc) Insert(key string) {
+	switch {
+	case p.b1.remove(key):
+		p.p = min(p.capacity, p.p+max(1, p.b2.len()/max(1, p.b1.len())))
+		p.t2.pushFront(key)
+	case p.b2.remove(key):
+		p.p = max(0, p.p-max(1, p.b1.len()/max(1, p.b2.len())))
+		p.t2.pushFront(key)
+	default:
+		p.t1.pushFront(key)
+	}
+}
+
+func (p *arc) Access(key string) {
+	if p.t1.remove(key) {
+		p.t2.pushFront(key)
+		return
+	}
+	p.t2.moveToFront(key)
+}
+
+func (p *arc) Remove(key string) {
+	if !p.t1.remove(key) {
+		p.t2.remove(key)
+	}
+}
+
+func (p *arc) Evict() (string, bool) {
+	if p.t1.len() > 0 && (p.t1.len() > p.p || p.t2.len() == 0) {
+		key, _ := p.t1.popBack()
+		p.b1.pushFront(key)
+		p.b1.trim(p.capacity)
+		return key, true
+	}
+	key, ok := p.t2.popBack()
+	if ok {
+		p.b2.pushFront(key)
+		p.b2.trim(p.capacity)
+	}
+	return key, ok
+}
+
+func (p *arc) Keys() []string {
+	return p.t2.appendKeys(p.t1.appendKeys(make([]string, 0, p.t1.len()+p.t2.len())))
+}
@@ -2,50 +2,473 @@ package cache
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/TemirB/wb-tech-L0/internal/config"
 	"github.com/TemirB/wb-tech-L0/internal/domain"
-
-	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"go.uber.org/zap"
 )
 
 //go:generate mockgen -source internal/cache/cache.go -destination=internal/cache/cache_mock_test.go -package=cache
 
-type repo interface {
-	GetByUID(ctx context.Context, uid string) (*domain.Order, error)
+// refreshTimeout bounds a background reload of a stale entry.
+const refreshTimeout = 5 * time.Second
+
+type warmRepo interface {
 	RecentOrderIDs(ctx context.Context, limit int) ([]string, error)
+	FrequentOrderIDs(ctx context.Context, limit int) ([]string, error)
+	GetByUIDs(ctx context.Context, uids []string) (map[string]*domain.Order, error)
+}
+
+type readStore interface {
+	AddReads(ctx context.Context, reads map[string]int64) error
+}
+
+type syncRepo interface {
+	GetByUID(ctx context.Context, uid string) (*domain.Order, error)
+	Versions(ctx context.Context, uids []string) (map[string]int64, error)
+}
+
+// EvictReason tells an EvictFunc why an order left the cache.
+type EvictReason string
+
+const (
+	EvictCapacity EvictReason = "capacity" // the entry limit was reached
+	EvictBytes    EvictReason = "bytes"    // the memory budget was exceeded
+	EvictExpired  EvictReason = "expired"  // TTL and stale window are over
+	EvictRemoved  EvictReason = "removed"  // invalidated explicitly
+)
+
+// EvictFunc is called after an order leaves the cache, outside the cache lock.
+// Replacing an order with a newer copy is not an eviction.
+type EvictFunc func(order domain.Order, reason EvictReason)
+
+// Loader reloads an order whose TTL has passed (stale-while-revalidate).
+type Loader func(ctx context.Context, uid string) (*domain.Order, error)
+
+type entry struct {
+	order      domain.Order
+	size       int64 // including json and gzip
+	stored     time.Time
+	expires    time.Time // zero: never
+	refreshing bool
+	gen        uint64 // changes whenever the order is replaced
+
+	// The order encoded for HTTP responses, made on demand by GetEncoded.
+	json, gzip []byte
 }
 
+type eviction struct {
+	order  domain.Order
+	reason EvictReason
+}
+
+// Cache holds orders bounded by count and, optionally, by the estimated memory
+// of its entries; its Policy (LRU by default) picks what to evict. Entries may
+// expire after a TTL; with a Loader set, an expired entry is still served during
+// the stale window while a single background reload replaces it.
+//
+// The orders are split by UID hash into cfg.Shards shards, each behind its own
+// lock with its own policy and an equal share of both limits, so concurrent
+// reads and writes of different orders rarely wait for each other. Eviction is
+// decided per shard: with several shards the cache as a whole approximates its
+// policy.
 type Cache struct {
-	size int
-	lru  *lru.Cache[string, domain.Order]
+	cfg    config.Cache
+	now    func() time.Time
+	seed   maphash.Seed
+	shards []*shard
+
+	customers *customers
+
+	mu         sync.Mutex // serialises Resize and OnEvict; guards cfg.Cap
+	loader     atomic.Pointer[Loader]
+	onEvict    atomic.Pointer[[]EvictFunc]
+	countReads atomic.Bool // set by PersistReads
+
+	trace        atomic.Pointer[chan string] // set by RecordTrace
+	traceDropped atomic.Int64
 }
 
-func New(size int) (*Cache, error) {
-	c, err := lru.New[string, domain.Order](size)
-	if err != nil {
-		return nil, err
+// New creates a cache with cfg.Shards shards, at least one and at most one per
+// order of capacity.
+func New(cfg config.Cache) (*Cache, error) {
+	if cfg.Cap <= 0 {
+		return nil, fmt.Errorf("cache capacity must be positive, got %d", cfg.Cap)
 	}
-	return &Cache{
-		size: size,
-		lru:  c,
-	}, nil
-}
+	n := min(max(cfg.Shards, 1), cfg.Cap)
+	c := &Cache{
+		cfg:    cfg,
+		now:    time.Now,
+		seed:   maphash.MakeSeed(),
+		shards: make([]*shard, n),
 
-func (c *Cache) Warm(ctx context.Context, repo repo) {
-	if ids, err := repo.RecentOrderIDs(ctx, c.size); err == nil {
-		for _, id := range ids {
-			if o, err := repo.GetByUID(ctx, id); err == nil {
-				c.Set(o)
-			}
+		customers: newCustomers(),
+	}
+	for i := range c.shards {
+		var maxBytes int64
+		if cfg.MaxBytes > 0 {
+			maxBytes = max(share(cfg.MaxBytes, n, i), 1)
+		}
+		s, err := newShard(c, int(share(int64(cfg.Cap), n, i)), maxBytes)
+		if err != nil {
+			return nil, err
 		}
+		c.shards[i] = s
 	}
+	return c, nil
+}
+
+// share is shard i's part of total split between n shards.
+func share(total int64, n, i int) int64 {
+	part := total / int64(n)
+	if int64(i) < total%int64(n) {
+		part++
+	}
+	return part
+}
+
+func (c *Cache) shard(uid string) *shard {
+	if len(c.shards) == 1 {
+		return c.shards[0]
+	}
+	return c.shards[maphash.String(c.seed, uid)%uint64(len(c.shards))]
+}
+
+// SetLoader enables stale-while-revalidate. Without a loader expired entries are
+// dropped on access.
+func (c *Cache) SetLoader(fn Loader) {
+	c.loader.Store(&fn)
+}
+
+// OnEvict registers fn to be called for every eviction.
+func (c *Cache) OnEvict(fn EvictFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var callbacks []EvictFunc
+	if cur := c.onEvict.Load(); cur != nil {
+		callbacks = slices.Clone(*cur)
+	}
+	callbacks = append(callbacks, fn)
+	c.onEvict.Store(&callbacks)
 }
 
 func (c *Cache) Get(uid string) (*domain.Order, bool) {
-	order, ok := c.lru.Get(uid)
-	return &order, ok
+	c.traced(uid)
+	order, ok, reload, evicted := c.shard(uid).get(uid)
+	c.notify(evicted...)
+	if !ok {
+		return &domain.Order{}, false
+	}
+	if reload {
+		go c.refresh(uid, *c.loader.Load())
+	}
+	return &order, true
 }
 
+// Set caches the order unless a newer version of it is already cached, so a slow
+// reader cannot overwrite a fresher write. Unversioned orders always replace.
+// An order larger than its shard's share of the memory budget is not cached.
 func (c *Cache) Set(order *domain.Order) {
-	c.lru.Add(order.OrderUID, *order)
+	c.notify(c.shard(order.OrderUID).set(order, Size(order))...)
+}
+
+// GetByTrackNumber is Get for the cached order with the track number. If several
+// cached orders share it, the newest by date_created wins.
+func (c *Cache) GetByTrackNumber(track string) (*domain.Order, bool) {
+	var (
+		uid    string
+		newest time.Time
+	)
+	for _, s := range c.shards {
+		if u, created, ok := s.track(track); ok && (uid == "" || created.After(newest)) {
+			uid, newest = u, created
+		}
+	}
+	if uid == "" {
+		return &domain.Order{}, false
+	}
+	return c.Get(uid)
+}
+
+// ListByCustomer returns copies of the customer's cached orders, newest first,
+// without touching their recency, and whether they are all of the customer's
+// orders (see WatchCustomer); otherwise the cache may hold only some of them.
+func (c *Cache) ListByCustomer(customerID string) ([]*domain.Order, bool) {
+	var orders []*domain.Order
+	for _, s := range c.shards {
+		orders = s.customer(customerID, orders)
+	}
+	// Checked after the orders were collected: one that left the cache
+	// meanwhile has made the customer incomplete.
+	complete := c.customers.complete(customerID)
+	slices.SortFunc(orders, func(a, b *domain.Order) int {
+		if c := b.DateCreated.Compare(a.DateCreated); c != 0 {
+			return c
+		}
+		return strings.Compare(a.OrderUID, b.OrderUID)
+	})
+	if orders == nil {
+		orders = []*domain.Order{}
+	}
+	return orders, complete
+}
+
+// WatchCustomer is called before listing the customer's orders from storage.
+// Once storage has listed all n of them and they have been cached, CustomerListed
+// makes the customer complete, unless one of their orders left the cache since
+// the watch was taken; ListByCustomer then reports the cached orders as all of
+// them until one leaves.
+func (c *Cache) WatchCustomer(customerID string) *CustomerWatch {
+	return c.customers.watch(customerID)
+}
+
+// CustomerListed ends w. n is the number of the customer's orders storage
+// listed, or negative if it did not list them all.
+func (c *Cache) CustomerListed(w *CustomerWatch, n int) {
+	cached := 0
+	if n > 0 {
+		for _, s := range c.shards {
+			cached += s.customerLen(w.customerID)
+		}
+	}
+	c.customers.listed(w, n > 0 && cached == n)
+}
+
+// ForgetCustomers makes every customer incomplete, for when orders may have
+// been written without passing through the cache.
+func (c *Cache) ForgetCustomers() {
+	c.customers.forgetAll()
+}
+
+// Version returns the version of the cached order without touching its recency.
+func (c *Cache) Version(uid string) (int64, bool) {
+	return c.shard(uid).version(uid)
+}
+
+// EntryInfo describes a cached order for administration.
+type EntryInfo struct {
+	UID       string
+	Version   int64
+	Size      int64         // estimated bytes
+	Age       time.Duration // since the order was cached or last replaced
+	ExpiresIn time.Duration // negative once expired; 0 without a TTL
+	Stale     bool          // expired but still served while it is reloaded
+}
+
+// Inspect describes the cached order uid without touching its recency or the
+// hit counters.
+func (c *Cache) Inspect(uid string) (EntryInfo, bool) {
+	return c.shard(uid).inspect(uid)
+}
+
+// Remove evicts uid and reports whether it was cached.
+func (c *Cache) Remove(uid string) bool {
+	evicted, ok := c.shard(uid).delete(uid)
+	if ok {
+		c.notify(evicted)
+	}
+	return ok
+}
+
+// Purge evicts every order and returns how many there were.
+func (c *Cache) Purge() int {
+	n := 0
+	for _, s := range c.shards {
+		evicted := s.purge()
+		c.notify(evicted...)
+		n += len(evicted)
+	}
+	return n
+}
+
+// Resize changes the entry limit at runtime, splitting it between the shards
+// and evicting the orders that no longer fit, and returns how many were evicted.
+// The number of shards stays; below it some shards hold nothing.
+func (c *Cache) Resize(capacity int) (int, error) {
+	if capacity <= 0 {
+		return 0, fmt.Errorf("cache capacity must be positive, got %d", capacity)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cfg.Cap = capacity
+	n := 0
+	for i, s := range c.shards {
+		evicted := s.resize(int(share(int64(capacity), len(c.shards), i)))
+		c.notify(evicted...)
+		n += len(evicted)
+	}
+	return n, nil
+}
+
+// Cap returns the current entry limit.
+func (c *Cache) Cap() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg.Cap
+}
+
+// Shards returns the number of shards.
+func (c *Cache) Shards() int {
+	return len(c.shards)
+}
+
+// Keys returns the cached order UIDs shard by shard, within a shard those
+// closest to eviction first; for LRU that is the least recently used.
+func (c *Cache) Keys() []string {
+	var keys []string
+	for _, s := range c.shards {
+		keys = append(keys, s.keys()...)
+	}
+	return keys
+}
+
+func (c *Cache) Len() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.len()
+	}
+	return n
+}
+
+// Stats returns the cache size and counters summed over the shards. Shards are
+// read one after another, so the sums are not a single point in time.
+func (c *Cache) Stats() observability.CacheStats {
+	st := observability.CacheStats{
+		Capacity:  c.Cap(),
+		MaxBytes:  c.cfg.MaxBytes,
+		Evictions: make(map[string]int64),
+	}
+	for _, s := range c.shards {
+		s.addStats(&st)
+	}
+	return st
+}
+
+// TakeReads returns the hits per UID since the previous call and resets them.
+// Hits are only counted while PersistReads runs.
+func (c *Cache) TakeReads() map[string]int64 {
+	reads := make(map[string]int64)
+	for _, s := range c.shards {
+		s.takeReads(reads)
+	}
+	return reads
+}
+
+// PersistReads saves read counters to store every interval, and once more when
+// ctx is done, so the "frequent" warm-up strategy knows what is popular. Counters
+// that fail to save are dropped.
+func (c *Cache) PersistReads(ctx context.Context, store readStore, interval time.Duration, logger *zap.Logger) {
+	c.countReads.Store(true)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	flush := func(ctx context.Context) {
+		reads := c.TakeReads()
+		if len(reads) == 0 {
+			return
+		}
+		if err := store.AddReads(ctx, reads); err != nil {
+			logger.Warn("failed to save cache read counters", zap.Int("orders", len(reads)), zap.Error(err))
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			flush(flushCtx)
+			cancel()
+			return
+		case <-t.C:
+			flush(ctx)
+		}
+	}
+}
+
+// Run drops entries past their stale window and reports statistics to m every
+// cfg.StatsInterval until ctx is done.
+func (c *Cache) Run(ctx context.Context, m observability.Metrics) {
+	interval := c.cfg.StatsInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		c.purgeExpired()
+		m.ObserveCache(c.Stats())
+	}
+}
+
+func (c *Cache) purgeExpired() {
+	for _, s := range c.shards {
+		c.notify(s.purgeExpired()...)
+	}
+}
+
+// refresh reloads uid for a stale hit. A failed reload leaves the stale copy in
+// place for the next hit to retry, unless the order no longer exists.
+func (c *Cache) refresh(uid string, load Loader) {
+	ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	order, err := load(ctx, uid)
+	if err == nil {
+		c.Set(order)
+		return
+	}
+	if errors.Is(err, domain.ErrNotFound) {
+		c.Remove(uid)
+		return
+	}
+	c.shard(uid).unmark(uid)
+}
+
+// staleWindow is how long an expired entry may still be served.
+func (c *Cache) staleWindow() time.Duration {
+	if c.loader.Load() == nil {
+		return 0
+	}
+	return c.cfg.StaleTTL
+}
+
+func (c *Cache) expiry() time.Time {
+	if c.cfg.TTL <= 0 {
+		return time.Time{}
+	}
+	return c.now().Add(c.cfg.TTL)
+}
+
+func (c *Cache) expired(e *entry, now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+func (c *Cache) notify(evicted ...eviction) {
+	if len(evicted) == 0 {
+		return
+	}
+	callbacks := c.onEvict.Load()
+	if callbacks == nil {
+		return
+	}
+	for _, ev := range evicted {
+		for _, fn := range *callbacks {
+			fn(ev.order, ev.reason)
+		}
+	}
 }
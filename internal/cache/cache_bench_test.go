@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+// BenchmarkCacheParallel measures Get and Set from many goroutines at once, as
+// HTTP reads and Kafka writes overlap in the service, for several shard counts:
+//
+//	go test ./internal/cache -run '^$' -bench CacheParallel -cpu 1,4,8
+//
+// With one shard every call waits for the same lock; more shards let calls for
+// different orders proceed in parallel.
+func BenchmarkCacheParallel(b *testing.B) {
+	const size = 10000
+	orders := make([]domain.Order, size)
+	for i := range orders {
+		orders[i] = domain.Order{
+			OrderUID:    fmt.Sprintf("order-%05d", i),
+			TrackNumber: fmt.Sprintf("WBILM%05d", i),
+			CustomerID:  fmt.Sprintf("customer-%d", i%1000),
+			Items:       make([]domain.Item, 2),
+		}
+	}
+
+	for _, shards := range []int{1, 4, 16, 64} {
+		for _, writes := range []int{10, 50} { // percent of calls that are Sets
+			b.Run(fmt.Sprintf("shards=%d/writes=%d%%", shards, writes), func(b *testing.B) {
+				c, err := New(config.Cache{Cap: size, Shards: shards})
+				if err != nil {
+					b.Fatal(err)
+				}
+				for i := range orders {
+					c.Set(&orders[i])
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				b.RunParallel(func(pb *testing.PB) {
+					rnd := rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+					for pb.Next() {
+						o := &orders[rnd.IntN(size)]
+						if rnd.IntN(100) < writes {
+							c.Set(o)
+						} else {
+							c.Get(o.OrderUID)
+						}
+					}
+				})
+			})
+		}
+	}
+}
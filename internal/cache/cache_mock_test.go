@@ -12,46 +12,61 @@ import (
 	gomock "github.com/golang/mock/gomock"
 )
 
-// Mockrepo is a mock of repo interface.
-type Mockrepo struct {
+// MockwarmRepo is a mock of warmRepo interface.
+type MockwarmRepo struct {
 	ctrl     *gomock.Controller
-	recorder *MockrepoMockRecorder
+	recorder *MockwarmRepoMockRecorder
 }
 
-// MockrepoMockRecorder is the mock recorder for Mockrepo.
-type MockrepoMockRecorder struct {
-	mock *Mockrepo
+// MockwarmRepoMockRecorder is the mock recorder for MockwarmRepo.
+type MockwarmRepoMockRecorder struct {
+	mock *MockwarmRepo
 }
 
-// NewMockrepo creates a new mock instance.
-func NewMockrepo(ctrl *gomock.Controller) *Mockrepo {
-	mock := &Mockrepo{ctrl: ctrl}
-	mock.recorder = &MockrepoMockRecorder{mock}
+// NewMockwarmRepo creates a new mock instance.
+func NewMockwarmRepo(ctrl *gomock.Controller) *MockwarmRepo {
+	mock := &MockwarmRepo{ctrl: ctrl}
+	mock.recorder = &MockwarmRepoMockRecorder{mock}
 	return mock
 }
 
 // EXPECT returns an object that allows the caller to indicate expected use.
-func (m *Mockrepo) EXPECT() *MockrepoMockRecorder {
+func (m *MockwarmRepo) EXPECT() *MockwarmRepoMockRecorder {
 	return m.recorder
 }
 
-// GetByUID mocks base method.
-func (m *Mockrepo) GetByUID(ctx context.Context, uid string) (*domain.Order, error) {
+// FrequentOrderIDs mocks base method.
+func (m *MockwarmRepo) FrequentOrderIDs(ctx context.Context, limit int) ([]string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetByUID", ctx, uid)
-	ret0, _ := ret[0].(*domain.Order)
+	ret := m.ctrl.Call(m, "FrequentOrderIDs", ctx, limit)
+	ret0, _ := ret[0].([]string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetByUID indicates an expected call of GetByUID.
-func (mr *MockrepoMockRecorder) GetByUID(ctx, uid interface{}) *gomock.Call {
+// FrequentOrderIDs indicates an expected call of FrequentOrderIDs.
+func (mr *MockwarmRepoMockRecorder) FrequentOrderIDs(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FrequentOrderIDs", reflect.TypeOf((*MockwarmRepo)(nil).FrequentOrderIDs), ctx, limit)
+}
+
+// GetByUIDs mocks base method.
+func (m *MockwarmRepo) GetByUIDs(ctx context.Context, uids []string) (map[string]*domain.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByUIDs", ctx, uids)
+	ret0, _ := ret[0].(map[string]*domain.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByUIDs indicates an expected call of GetByUIDs.
+func (mr *MockwarmRepoMockRecorder) GetByUIDs(ctx, uids interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUID", reflect.TypeOf((*Mockrepo)(nil).GetByUID), ctx, uid)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUIDs", reflect.TypeOf((*MockwarmRepo)(nil).GetByUIDs), ctx, uids)
 }
 
 // RecentOrderIDs mocks base method.
-func (m *Mockrepo) RecentOrderIDs(ctx context.Context, limit int) ([]string, error) {
+func (m *MockwarmRepo) RecentOrderIDs(ctx context.Context, limit int) ([]string, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "RecentOrderIDs", ctx, limit)
 	ret0, _ := ret[0].([]string)
@@ -60,7 +75,97 @@ func (m *Mockrepo) RecentOrderIDs(ctx context.Context, limit int) ([]string, err
 }
 
 // RecentOrderIDs indicates an expected call of RecentOrderIDs.
-func (mr *MockrepoMockRecorder) RecentOrderIDs(ctx, limit interface{}) *gomock.Call {
+func (mr *MockwarmRepoMockRecorder) RecentOrderIDs(ctx, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecentOrderIDs", reflect.TypeOf((*MockwarmRepo)(nil).RecentOrderIDs), ctx, limit)
+}
+
+// MockreadStore is a mock of readStore interface.
+type MockreadStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockreadStoreMockRecorder
+}
+
+// MockreadStoreMockRecorder is the mock recorder for MockreadStore.
+type MockreadStoreMockRecorder struct {
+	mock *MockreadStore
+}
+
+// NewMockreadStore creates a new mock instance.
+func NewMockreadStore(ctrl *gomock.Controller) *MockreadStore {
+	mock := &MockreadStore{ctrl: ctrl}
+	mock.recorder = &MockreadStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockreadStore) EXPECT() *MockreadStoreMockRecorder {
+	return m.recorder
+}
+
+// AddReads mocks base method.
+func (m *MockreadStore) AddReads(ctx context.Context, reads map[string]int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddReads", ctx, reads)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddReads indicates an expected call of AddReads.
+func (mr *MockreadStoreMockRecorder) AddReads(ctx, reads interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddReads", reflect.TypeOf((*MockreadStore)(nil).AddReads), ctx, reads)
+}
+
+// MocksyncRepo is a mock of syncRepo interface.
+type MocksyncRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MocksyncRepoMockRecorder
+}
+
+// MocksyncRepoMockRecorder is the mock recorder for MocksyncRepo.
+type MocksyncRepoMockRecorder struct {
+	mock *MocksyncRepo
+}
+
+// NewMocksyncRepo creates a new mock instance.
+func NewMocksyncRepo(ctrl *gomock.Controller) *MocksyncRepo {
+	mock := &MocksyncRepo{ctrl: ctrl}
+	mock.recorder = &MocksyncRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MocksyncRepo) EXPECT() *MocksyncRepoMockRecorder {
+	return m.recorder
+}
+
+// GetByUID mocks base method.
+func (m *MocksyncRepo) GetByUID(ctx context.Context, uid string) (*domain.Order, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByUID", ctx, uid)
+	ret0, _ := ret[0].(*domain.Order)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetByUID indicates an expected call of GetByUID.
+func (mr *MocksyncRepoMockRecorder) GetByUID(ctx, uid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUID", reflect.TypeOf((*MocksyncRepo)(nil).GetByUID), ctx, uid)
+}
+
+// Versions mocks base method.
+func (m *MocksyncRepo) Versions(ctx context.Context, uids []string) (map[string]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Versions", ctx, uids)
+	ret0, _ := ret[0].(map[string]int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Versions indicates an expected call of Versions.
+func (mr *MocksyncRepoMockRecorder) Versions(ctx, uids interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecentOrderIDs", reflect.TypeOf((*Mockrepo)(nil).RecentOrderIDs), ctx, limit)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Versions", reflect.TypeOf((*MocksyncRepo)(nil).Versions), ctx, uids)
 }
@@ -2,84 +2,397 @@ package cache
 
 import (
 	"context"
-	"errors"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 
+	"github.com/TemirB/wb-tech-L0/internal/config"
 	"github.com/TemirB/wb-tech-L0/internal/domain"
 )
 
-func TestWarm(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+// fakeClock is a manually advanced time source for TTL tests.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
 
-	repo := NewMockrepo(ctrl)
-	cap := 3
-	ids := []string{"1", "2", "3"}
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
 
-	repo.EXPECT().RecentOrderIDs(gomock.Any(), cap).Return(ids, nil)
-	for _, id := range ids {
-		repo.EXPECT().GetByUID(gomock.Any(), id).Return(&domain.Order{OrderUID: id}, nil)
-	}
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	f.mu.Unlock()
+}
 
-	c, err := New(cap)
-	if err != nil {
-		t.Fatalf("unexpected error constructing cache: %v", err)
-	}
-	c.Warm(context.Background(), repo)
+func newTestCache(t *testing.T, cfg config.Cache) (*Cache, *fakeClock) {
+	c, err := New(cfg)
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)}
+	c.now = clock.Now
+	return c, clock
+}
+
+func TestCapacityEviction(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 2})
+	var evicted []string
+	c.OnEvict(func(o domain.Order, reason EvictReason) {
+		require.Equal(t, EvictCapacity, reason)
+		evicted = append(evicted, o.OrderUID)
+	})
+
+	c.Set(&domain.Order{OrderUID: "a"})
+	c.Set(&domain.Order{OrderUID: "b"})
+	c.Get("a") // "b" becomes the least recently used
+	c.Set(&domain.Order{OrderUID: "c"})
+
+	require.Equal(t, []string{"b"}, evicted)
+	require.Equal(t, []string{"a", "c"}, c.Keys())
+	require.Equal(t, int64(1), c.Stats().Evictions["capacity"])
+}
+
+func TestByteBudget(t *testing.T) {
+	small := &domain.Order{OrderUID: "s0"}
+	big := &domain.Order{OrderUID: "big", Items: make([]domain.Item, 100)}
+	c, _ := newTestCache(t, config.Cache{Cap: 10, MaxBytes: 3 * Size(small)})
+
+	var reasons []EvictReason
+	c.OnEvict(func(_ domain.Order, reason EvictReason) { reasons = append(reasons, reason) })
 
-	for _, id := range ids {
-		if _, ok := c.Get(id); !ok {
-			t.Errorf("expected id %s to be cached after Warm", id)
-		}
+	for _, uid := range []string{"s1", "s2", "s3", "s4"} {
+		c.Set(&domain.Order{OrderUID: uid})
 	}
+	require.Equal(t, []string{"s2", "s3", "s4"}, c.Keys())
+	require.Equal(t, []EvictReason{EvictBytes}, reasons)
+
+	// An order above the whole budget is not cached and does not flush the rest.
+	c.Set(big)
+	_, ok := c.Get("big")
+	require.False(t, ok)
+	require.Equal(t, 3, c.Len())
+
+	st := c.Stats()
+	require.Equal(t, 3*Size(small), st.Bytes)
+	require.LessOrEqual(t, st.Bytes, st.MaxBytes)
 }
 
-func TestWarmIgnoresRepoError(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+func TestSizeGrowsWithContent(t *testing.T) {
+	o := &domain.Order{OrderUID: "a"}
+	base := Size(o)
 
-	repo := NewMockrepo(ctrl)
-	cap := 5
+	o.Items = []domain.Item{{Name: "Mascaras"}}
+	require.Greater(t, Size(o), base+int64(len("Mascaras")))
+}
 
-	repo.EXPECT().RecentOrderIDs(gomock.Any(), cap).Return(nil, errors.New("repo error"))
-	repo.EXPECT().GetByUID(gomock.Any(), gomock.Any()).Times(0)
+func TestTTLExpiry(t *testing.T) {
+	c, clock := newTestCache(t, config.Cache{Cap: 10, TTL: time.Minute})
+	var reasons []EvictReason
+	c.OnEvict(func(_ domain.Order, reason EvictReason) { reasons = append(reasons, reason) })
 
-	c, err := New(cap)
-	if err != nil {
-		t.Fatalf("unexpected error constructing cache: %v", err)
-	}
+	c.Set(&domain.Order{OrderUID: "a"})
+	clock.Advance(59 * time.Second)
+	_, ok := c.Get("a")
+	require.True(t, ok)
 
-	c.Warm(context.Background(), repo) // должно отработать без паники
+	clock.Advance(2 * time.Second)
+	_, ok = c.Get("a")
+	require.False(t, ok)
+	require.Equal(t, []EvictReason{EvictExpired}, reasons)
+
+	st := c.Stats()
+	require.Equal(t, int64(1), st.Hits)
+	require.Equal(t, int64(1), st.Misses)
+	require.Equal(t, int64(1), st.Expirations)
+	require.Zero(t, st.Entries)
+	require.Zero(t, st.Bytes)
 }
 
-func TestWarmPartialErrors(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
+func TestStaleWhileRevalidate(t *testing.T) {
+	c, clock := newTestCache(t, config.Cache{Cap: 10, TTL: time.Minute, StaleTTL: time.Minute})
 
-	repo := NewMockrepo(ctrl)
-	cap := 4
-	ids := []string{"ok1", "bad", "ok2"}
+	loads := make(chan string, 10)
+	release := make(chan struct{})
+	c.SetLoader(func(_ context.Context, uid string) (*domain.Order, error) {
+		loads <- uid
+		<-release
+		return &domain.Order{OrderUID: uid, Version: 2}, nil
+	})
 
-	repo.EXPECT().RecentOrderIDs(gomock.Any(), cap).Return(ids, nil)
-	repo.EXPECT().GetByUID(gomock.Any(), "ok1").Return(&domain.Order{OrderUID: "ok1"}, nil)
-	repo.EXPECT().GetByUID(gomock.Any(), "bad").Return(nil, errors.New("db read err"))
-	repo.EXPECT().GetByUID(gomock.Any(), "ok2").Return(&domain.Order{OrderUID: "ok2"}, nil)
+	c.Set(&domain.Order{OrderUID: "a", Version: 1})
+	clock.Advance(90 * time.Second)
 
-	c, err := New(cap)
-	if err != nil {
-		t.Fatalf("unexpected error constructing cache: %v", err)
+	// Stale hits serve the old copy and start exactly one reload.
+	for i := 0; i < 3; i++ {
+		got, ok := c.Get("a")
+		require.True(t, ok)
+		require.Equal(t, int64(1), got.Version)
 	}
-	c.Warm(context.Background(), repo)
+	require.Equal(t, "a", <-loads)
+	close(release)
+
+	require.Eventually(t, func() bool {
+		v, _ := c.Version("a")
+		return v == 2
+	}, time.Second, time.Millisecond)
+	require.Empty(t, loads)
+	require.Equal(t, int64(3), c.Stats().StaleHits)
+
+	// The reload starts a new TTL.
+	got, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, int64(2), got.Version)
+}
+
+func TestStaleRefreshNotFoundRemoves(t *testing.T) {
+	c, clock := newTestCache(t, config.Cache{Cap: 10, TTL: time.Minute, StaleTTL: time.Minute})
+	c.SetLoader(func(context.Context, string) (*domain.Order, error) {
+		return nil, domain.ErrNotFound
+	})
+
+	c.Set(&domain.Order{OrderUID: "a"})
+	clock.Advance(90 * time.Second)
+	_, ok := c.Get("a")
+	require.True(t, ok)
+
+	require.Eventually(t, func() bool { return c.Len() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestStaleWindowOver(t *testing.T) {
+	c, clock := newTestCache(t, config.Cache{Cap: 10, TTL: time.Minute, StaleTTL: time.Minute})
+	c.SetLoader(func(context.Context, string) (*domain.Order, error) {
+		t.Fatal("loader must not be called past the stale window")
+		return nil, nil
+	})
+
+	c.Set(&domain.Order{OrderUID: "a"})
+	c.Set(&domain.Order{OrderUID: "b"})
+	clock.Advance(3 * time.Minute)
+
+	_, ok := c.Get("a")
+	require.False(t, ok)
+
+	c.purgeExpired()
+	require.Zero(t, c.Len())
+	require.Equal(t, int64(2), c.Stats().Expirations)
+}
+
+func TestRemoveCallback(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 10})
+	var got []EvictReason
+	c.OnEvict(func(_ domain.Order, reason EvictReason) { got = append(got, reason) })
+
+	c.Set(&domain.Order{OrderUID: "a"})
+	c.Set(&domain.Order{OrderUID: "a"}) // replacing is not an eviction
+	require.True(t, c.Remove("a"))
+	require.False(t, c.Remove("a"))
+
+	require.Equal(t, []EvictReason{EvictRemoved}, got)
+	require.Equal(t, int64(1), c.Stats().Evictions["removed"])
+}
+
+func TestNewRejectsZeroCapacity(t *testing.T) {
+	_, err := New(config.Cache{})
+	require.Error(t, err)
+}
+
+type fakeReadStore struct {
+	mu    sync.Mutex
+	reads map[string]int64
+}
 
-	if _, ok := c.Get("ok1"); !ok {
-		t.Errorf("ok1 must be cached")
+func (f *fakeReadStore) AddReads(_ context.Context, reads map[string]int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for uid, n := range reads {
+		f.reads[uid] += n
 	}
-	if _, ok := c.Get("ok2"); !ok {
-		t.Errorf("ok2 must be cached")
+	return nil
+}
+
+func TestPersistReads(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 10})
+	c.Set(&domain.Order{OrderUID: "a"})
+	c.Get("a") // not counted before PersistReads starts
+
+	store := &fakeReadStore{reads: map[string]int64{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.PersistReads(ctx, store, time.Hour, zap.NewNop())
+	}()
+	require.Eventually(t, c.countReads.Load, time.Second, time.Millisecond)
+
+	c.Get("a")
+	c.Get("a")
+	c.Get("missing")
+
+	// Counters are saved on the way out.
+	cancel()
+	<-done
+	require.Equal(t, map[string]int64{"a": 2}, store.reads)
+	require.Empty(t, c.TakeReads())
+}
+
+func TestInspect(t *testing.T) {
+	c, clock := newTestCache(t, config.Cache{Cap: 2, TTL: time.Minute})
+	c.Set(&domain.Order{OrderUID: "a", Version: 7})
+	c.Set(&domain.Order{OrderUID: "b"})
+	clock.Advance(90 * time.Second)
+
+	info, ok := c.Inspect("a")
+	require.True(t, ok)
+	require.Equal(t, EntryInfo{
+		UID:       "a",
+		Version:   7,
+		Size:      Size(&domain.Order{OrderUID: "a", Version: 7}),
+		Age:       90 * time.Second,
+		ExpiresIn: -30 * time.Second,
+		Stale:     true,
+	}, info)
+	require.Equal(t, []string{"a", "b"}, c.Keys(), "inspecting does not touch recency")
+	require.Zero(t, c.Stats().Hits)
+
+	_, ok = c.Inspect("missing")
+	require.False(t, ok)
+}
+
+func TestResize(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 3})
+	for _, uid := range []string{"a", "b", "c"} {
+		c.Set(&domain.Order{OrderUID: uid})
 	}
-	if _, ok := c.Get("bad"); ok {
-		t.Errorf("bad must NOT be cached")
+
+	n, err := c.Resize(1)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{"c"}, c.Keys())
+	require.Equal(t, 1, c.Stats().Capacity)
+
+	_, err = c.Resize(2)
+	require.NoError(t, err)
+	c.Set(&domain.Order{OrderUID: "d"})
+	require.Equal(t, []string{"c", "d"}, c.Keys())
+
+	_, err = c.Resize(0)
+	require.Error(t, err)
+	require.Equal(t, 2, c.Cap())
+}
+
+func TestSecondaryIndexes(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 3})
+	day := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	c.Set(&domain.Order{OrderUID: "a", TrackNumber: "T1", CustomerID: "alice", DateCreated: day})
+	c.Set(&domain.Order{OrderUID: "b", TrackNumber: "T2", CustomerID: "alice", DateCreated: day.Add(time.Hour)})
+	c.Set(&domain.Order{OrderUID: "c", TrackNumber: "T3", CustomerID: "bob", DateCreated: day})
+
+	o, ok := c.GetByTrackNumber("T2")
+	require.True(t, ok)
+	require.Equal(t, "b", o.OrderUID)
+	require.Equal(t, []string{"b", "a"}, uids(c.ListByCustomer("alice")))
+
+	// Replacing an order moves it between index entries.
+	c.Set(&domain.Order{OrderUID: "a", TrackNumber: "T9", CustomerID: "bob", DateCreated: day})
+	_, ok = c.GetByTrackNumber("T1")
+	require.False(t, ok)
+	o, ok = c.GetByTrackNumber("T9")
+	require.True(t, ok)
+	require.Equal(t, "a", o.OrderUID)
+	require.Equal(t, []string{"b"}, uids(c.ListByCustomer("alice")))
+	require.Equal(t, []string{"a", "c"}, uids(c.ListByCustomer("bob")))
+
+	// Evicted orders leave the indexes ("c" is the least recently used).
+	c.Set(&domain.Order{OrderUID: "d", TrackNumber: "T4", CustomerID: "carol"})
+	_, ok = c.GetByTrackNumber("T3")
+	require.False(t, ok)
+	require.Equal(t, []string{"a"}, uids(c.ListByCustomer("bob")))
+
+	c.Remove("a")
+	_, ok = c.GetByTrackNumber("T9")
+	require.False(t, ok)
+	require.Empty(t, uids(c.ListByCustomer("bob")))
+}
+
+func TestTrackNumberIndexPrefersNewest(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 3})
+	day := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	c.Set(&domain.Order{OrderUID: "new", TrackNumber: "T", DateCreated: day.Add(time.Hour)})
+	c.Set(&domain.Order{OrderUID: "old", TrackNumber: "T", DateCreated: day})
+
+	o, ok := c.GetByTrackNumber("T")
+	require.True(t, ok)
+	require.Equal(t, "new", o.OrderUID)
+
+	// Removing the other order keeps the entry.
+	c.Remove("old")
+	_, ok = c.GetByTrackNumber("T")
+	require.True(t, ok)
+
+	// Removing the newest falls back to an older one with the track number.
+	c.Set(&domain.Order{OrderUID: "old", TrackNumber: "T", DateCreated: day})
+	c.Remove("new")
+	o, ok = c.GetByTrackNumber("T")
+	require.True(t, ok)
+	require.Equal(t, "old", o.OrderUID)
+}
+
+func TestCustomerComplete(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 3})
+	day := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	c.Set(&domain.Order{OrderUID: "a", CustomerID: "alice", DateCreated: day})
+
+	_, complete := c.ListByCustomer("alice")
+	require.False(t, complete, "the cache may hold only some of the orders")
+
+	// Storage listed two orders, one of which is not cached.
+	w := c.WatchCustomer("alice")
+	c.CustomerListed(w, 2)
+	_, complete = c.ListByCustomer("alice")
+	require.False(t, complete)
+
+	w = c.WatchCustomer("alice")
+	c.Set(&domain.Order{OrderUID: "b", CustomerID: "alice", DateCreated: day.Add(time.Hour)})
+	c.CustomerListed(w, 2)
+	orders, complete := c.ListByCustomer("alice")
+	require.True(t, complete)
+	require.Equal(t, []string{"b", "a"}, uids(orders, complete))
+
+	// A new order keeps the customer complete; one leaving the cache does not.
+	c.Set(&domain.Order{OrderUID: "c", CustomerID: "alice", DateCreated: day.Add(2 * time.Hour)})
+	_, complete = c.ListByCustomer("alice")
+	require.True(t, complete)
+	c.Remove("a")
+	_, complete = c.ListByCustomer("alice")
+	require.False(t, complete)
+
+	// An order leaving the cache while storage is listed cancels the watch.
+	w = c.WatchCustomer("alice")
+	c.Set(&domain.Order{OrderUID: "a", CustomerID: "alice", DateCreated: day})
+	c.Remove("c")
+	c.Set(&domain.Order{OrderUID: "c", CustomerID: "alice", DateCreated: day.Add(2 * time.Hour)})
+	c.CustomerListed(w, 3)
+	_, complete = c.ListByCustomer("alice")
+	require.False(t, complete)
+
+	w = c.WatchCustomer("alice")
+	c.CustomerListed(w, 3)
+	_, complete = c.ListByCustomer("alice")
+	require.True(t, complete)
+	c.ForgetCustomers()
+	_, complete = c.ListByCustomer("alice")
+	require.False(t, complete)
+}
+
+func uids(orders []*domain.Order, _ bool) []string {
+	out := make([]string, len(orders))
+	for i, o := range orders {
+		out[i] = o.OrderUID
 	}
+	return out
 }
@@ -0,0 +1,73 @@
+package cache
+
+import "sync"
+
+// CustomerWatch is taken before listing a customer's orders from storage and
+// passed back once they are cached, see Cache.WatchCustomer.
+type CustomerWatch struct {
+	customerID string
+	complete   bool
+}
+
+// customers tracks the customers whose orders are all cached, so that listing
+// them can skip storage. A customer is complete from a listing that found all
+// of their orders cached until one of those leaves the cache. New orders keep
+// a customer complete, as they are cached when written.
+type customers struct {
+	mu      sync.Mutex
+	watches map[string]*CustomerWatch
+}
+
+func newCustomers() *customers {
+	return &customers{watches: make(map[string]*CustomerWatch)}
+}
+
+func (c *customers) watch(customerID string) *CustomerWatch {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.watches[customerID]
+	if !ok {
+		w = &CustomerWatch{customerID: customerID}
+		c.watches[customerID] = w
+	}
+	return w
+}
+
+// listed marks w complete if ok and it is still watched, and otherwise stops
+// watching it unless it is complete already.
+func (c *customers) listed(w *CustomerWatch, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.watches[w.customerID] != w {
+		return
+	}
+	switch {
+	case ok:
+		w.complete = true
+	case !w.complete:
+		delete(c.watches, w.customerID)
+	}
+}
+
+func (c *customers) complete(customerID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w, ok := c.watches[customerID]
+	return ok && w.complete
+}
+
+// forget ends every watch of customerID; an order of theirs left the cache.
+func (c *customers) forget(customerID string) {
+	if customerID == "" {
+		return
+	}
+	c.mu.Lock()
+	delete(c.watches, customerID)
+	c.mu.Unlock()
+}
+
+func (c *customers) forgetAll() {
+	c.mu.Lock()
+	clear(c.watches)
+	c.mu.Unlock()
+}
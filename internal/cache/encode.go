@@ -0,0 +1,126 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+// EncodeJSON encodes the order the way the HTTP API writes it: indented by two
+// spaces, with a trailing newline.
+func EncodeJSON(o *domain.Order) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(o); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Encoded is a cached order as a response body, with the version and update
+// time of the order it was made from.
+type Encoded struct {
+	Body      []byte
+	Gzip      bool // Body is gzip-compressed
+	Version   int64
+	UpdatedAt *time.Time
+}
+
+// GetEncoded is Get for a response body: the fresh cached order as EncodeJSON
+// bytes, gzip-compressed if acceptGzip and cfg.EncodeGzip are set. The bytes are
+// made on first request and kept with the entry, counted in its size, until the
+// order is replaced. The returned body is shared and must not be modified.
+//
+// It reports false without counting a miss when encoding is off (cfg.Encode)
+// and when the order is not cached or is stale; the caller falls back to Get.
+func (c *Cache) GetEncoded(uid string, acceptGzip bool) (Encoded, bool) {
+	if !c.cfg.Encode {
+		return Encoded{}, false
+	}
+	enc, ok, evicted := c.shard(uid).encoded(uid, acceptGzip && c.cfg.EncodeGzip)
+	c.notify(evicted...)
+	if ok {
+		c.traced(uid)
+	}
+	return enc, ok
+}
+
+// encoded returns the order's encoded body, making and keeping it if needed.
+// Encoding runs outside the lock; the result is only kept if the entry has not
+// been replaced in the meantime.
+func (s *shard) encoded(uid string, gz bool) (Encoded, bool, []eviction) {
+	s.mu.Lock()
+	e, ok := s.items[uid]
+	if !ok || s.c.expired(e, s.c.now()) {
+		s.mu.Unlock()
+		return Encoded{}, false, nil
+	}
+	s.hits++
+	if s.c.countReads.Load() {
+		s.reads[uid]++
+	}
+	s.policy.Access(uid)
+	enc := Encoded{Gzip: gz, Version: e.order.Version, UpdatedAt: e.order.UpdatedAt}
+	if enc.Body = e.body(gz); enc.Body != nil {
+		s.mu.Unlock()
+		return enc, true, nil
+	}
+	order, plain, gen := e.order, e.json, e.gen
+	s.mu.Unlock()
+
+	var err error
+	if plain == nil {
+		if plain, err = EncodeJSON(&order); err != nil {
+			return Encoded{}, false, nil
+		}
+	}
+	enc.Body = plain
+	var zipped []byte
+	if gz {
+		if zipped, err = gzipBytes(plain); err != nil {
+			return Encoded{}, false, nil
+		}
+		enc.Body = zipped
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.items[uid]; !ok || cur != e || e.gen != gen {
+		return enc, true, nil
+	}
+	var grown int64
+	if e.json == nil {
+		e.json = plain
+		grown += int64(len(plain))
+	}
+	if zipped != nil && e.gzip == nil {
+		e.gzip = zipped
+		grown += int64(len(zipped))
+	}
+	e.size += grown
+	s.bytes += grown
+	return enc, true, s.shrink()
+}
+
+func (e *entry) body(gz bool) []byte {
+	if gz {
+		return e.gzip
+	}
+	return e.json
+}
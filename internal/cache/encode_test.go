@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func gunzip(t *testing.T, b []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	require.NoError(t, err)
+	out, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	return out
+}
+
+func TestGetEncoded(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 10, Encode: true, EncodeGzip: true})
+	updated := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+	order := &domain.Order{OrderUID: "a", TrackNumber: "T1", Version: 1, UpdatedAt: &updated}
+	c.Set(order)
+	plain, err := EncodeJSON(order)
+	require.NoError(t, err)
+	sizeBefore := c.Stats().Bytes
+
+	enc, ok := c.GetEncoded("a", false)
+	require.True(t, ok)
+	require.Equal(t, Encoded{Body: plain, Version: 1, UpdatedAt: &updated}, enc)
+
+	zipped, ok := c.GetEncoded("a", true)
+	require.True(t, ok)
+	require.True(t, zipped.Gzip)
+	require.Equal(t, plain, gunzip(t, zipped.Body))
+
+	// Kept bodies are counted in the entry size and reused.
+	require.Equal(t, sizeBefore+int64(len(plain)+len(zipped.Body)), c.Stats().Bytes)
+	again, _ := c.GetEncoded("a", true)
+	require.Same(t, &zipped.Body[0], &again.Body[0])
+	require.Equal(t, int64(1), again.Version)
+	require.Equal(t, int64(3), c.Stats().Hits)
+
+	// Replacing the order drops its bodies.
+	c.Set(&domain.Order{OrderUID: "a", TrackNumber: "T2", Version: 2})
+	require.Equal(t, sizeBefore, c.Stats().Bytes)
+	enc, ok = c.GetEncoded("a", false)
+	require.True(t, ok)
+	require.Contains(t, string(enc.Body), `"track_number": "T2"`)
+	require.Equal(t, int64(2), enc.Version)
+	require.Nil(t, enc.UpdatedAt)
+
+	// Misses are left to Get to count.
+	_, ok = c.GetEncoded("missing", false)
+	require.False(t, ok)
+	require.Zero(t, c.Stats().Misses)
+}
+
+func TestGetEncodedOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.Cache
+		ok, gzipped bool
+	}{
+		{name: "encoding off", cfg: config.Cache{Cap: 10}},
+		{name: "json only", cfg: config.Cache{Cap: 10, Encode: true}, ok: true},
+		{name: "json and gzip", cfg: config.Cache{Cap: 10, Encode: true, EncodeGzip: true}, ok: true, gzipped: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCache(t, tt.cfg)
+			c.Set(&domain.Order{OrderUID: "a"})
+			enc, ok := c.GetEncoded("a", true)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.gzipped, enc.Gzip)
+		})
+	}
+}
+
+func TestGetEncodedSkipsStale(t *testing.T) {
+	c, clock := newTestCache(t, config.Cache{Cap: 10, TTL: time.Minute, Encode: true})
+	c.Set(&domain.Order{OrderUID: "a"})
+	clock.Advance(2 * time.Minute)
+
+	_, ok := c.GetEncoded("a", false)
+	require.False(t, ok)
+}
+
+func TestGetEncodedRespectsByteBudget(t *testing.T) {
+	order := &domain.Order{OrderUID: "a"}
+	c, _ := newTestCache(t, config.Cache{Cap: 10, MaxBytes: Size(order) + 10, Encode: true})
+	var evicted []EvictReason
+	c.OnEvict(func(_ domain.Order, reason EvictReason) { evicted = append(evicted, reason) })
+	c.Set(order)
+
+	// The body is served, but keeping it overflows the budget.
+	_, ok := c.GetEncoded("a", false)
+	require.True(t, ok)
+	require.Equal(t, []EvictReason{EvictBytes}, evicted)
+	require.Zero(t, c.Len())
+}
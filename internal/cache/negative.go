@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Negative remembers order UIDs that storage reported as missing, so repeated
+// lookups of unknown UIDs do not reach the database. It is an LRU bounded by
+// count whose entries expire after a short TTL.
+//
+// A lookup that misses takes an Epoch before reading storage and passes it to
+// Add. Remove notes the UID it was called for, so a miss that raced with an
+// upsert of the same UID is not remembered, while misses of other UIDs are.
+// Removals are kept for the TTL; a lookup that started before a forgotten one
+// is not remembered either.
+type Negative struct {
+	cap int
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	ll       *list.List // front is the most recently added
+	items    map[string]*list.Element
+	epoch    uint64
+	removed  map[string]uint64 // epoch of the last Remove of each UID still kept
+	removals *list.List        // of *negativeRemoval, oldest at the front
+	horizon  uint64            // Add ignores epochs taken before it
+}
+
+type negativeEntry struct {
+	uid     string
+	expires time.Time
+}
+
+type negativeRemoval struct {
+	uid   string
+	epoch uint64
+	at    time.Time
+}
+
+func NewNegative(cap int, ttl time.Duration) *Negative {
+	return &Negative{
+		cap:      cap,
+		ttl:      ttl,
+		now:      time.Now,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+		removed:  make(map[string]uint64),
+		removals: list.New(),
+	}
+}
+
+// Has reports whether uid is known to be missing.
+func (n *Negative) Has(uid string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	el, ok := n.items[uid]
+	if !ok {
+		return false
+	}
+	if n.now().After(el.Value.(*negativeEntry).expires) {
+		n.ll.Remove(el)
+		delete(n.items, uid)
+		return false
+	}
+	return true
+}
+
+// Epoch returns a token to pass to Add for a lookup starting now.
+func (n *Negative) Epoch() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.epoch
+}
+
+// Add remembers uid as missing unless uid was removed since epoch was taken.
+func (n *Negative) Add(uid string, epoch uint64) {
+	if n.cap <= 0 || n.ttl <= 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.prune()
+	if epoch < n.horizon || n.removed[uid] > epoch {
+		return
+	}
+
+	expires := n.now().Add(n.ttl)
+	if el, ok := n.items[uid]; ok {
+		el.Value.(*negativeEntry).expires = expires
+		n.ll.MoveToFront(el)
+		return
+	}
+	n.items[uid] = n.ll.PushFront(&negativeEntry{uid: uid, expires: expires})
+	for n.ll.Len() > n.cap {
+		el := n.ll.Back()
+		n.ll.Remove(el)
+		delete(n.items, el.Value.(*negativeEntry).uid)
+	}
+}
+
+// Remove forgets uid, typically because it has just been written.
+func (n *Negative) Remove(uid string) {
+	if n.cap <= 0 || n.ttl <= 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.epoch++
+	n.removed[uid] = n.epoch
+	n.removals.PushBack(&negativeRemoval{uid: uid, epoch: n.epoch, at: n.now()})
+	n.prune()
+	if el, ok := n.items[uid]; ok {
+		n.ll.Remove(el)
+		delete(n.items, uid)
+	}
+}
+
+// Purge forgets every UID, for when writes may have been missed.
+func (n *Negative) Purge() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.epoch++
+	n.horizon = n.epoch
+	n.ll.Init()
+	clear(n.items)
+	n.removals.Init()
+	clear(n.removed)
+}
+
+// prune forgets the removals older than the TTL, moving the horizon past them.
+// The caller holds n.mu.
+func (n *Negative) prune() {
+	cutoff := n.now().Add(-n.ttl)
+	for el := n.removals.Front(); el != nil; el = n.removals.Front() {
+		r := el.Value.(*negativeRemoval)
+		if r.at.After(cutoff) {
+			return
+		}
+		n.removals.Remove(el)
+		if n.removed[r.uid] == r.epoch {
+			delete(n.removed, r.uid)
+		}
+		n.horizon = r.epoch
+	}
+}
+
+func (n *Negative) Len() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ll.Len()
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegative(t *testing.T) {
+	n := NewNegative(2, time.Minute)
+	clock := &fakeClock{now: time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)}
+	n.now = clock.Now
+
+	n.Add("a", n.Epoch())
+	require.True(t, n.Has("a"))
+	require.False(t, n.Has("b"))
+
+	// Bounded: the oldest UID goes first.
+	n.Add("b", n.Epoch())
+	n.Add("c", n.Epoch())
+	require.False(t, n.Has("a"))
+	require.Equal(t, 2, n.Len())
+
+	// Entries expire.
+	clock.Advance(2 * time.Minute)
+	require.False(t, n.Has("b"))
+	require.False(t, n.Has("c"))
+	require.Zero(t, n.Len())
+}
+
+func TestNegativeRemoveWinsOverRacingAdd(t *testing.T) {
+	n := NewNegative(10, time.Minute)
+
+	epoch := n.Epoch() // lookup starts and misses in storage
+	n.Remove("a")      // meanwhile the order is upserted
+	n.Add("a", epoch)  // the lookup finishes
+
+	require.False(t, n.Has("a"))
+}
+
+func TestNegativeIgnoresRemovalsOfOtherUIDs(t *testing.T) {
+	n := NewNegative(10, time.Minute)
+	clock := &fakeClock{now: time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)}
+	n.now = clock.Now
+
+	epoch := n.Epoch()
+	n.Remove("b") // ingestion goes on
+	n.Add("a", epoch)
+	require.True(t, n.Has("a"))
+
+	// A lookup outliving the removals kept cannot be told apart from one that
+	// raced with an upsert of its UID, so it is not remembered.
+	epoch = n.Epoch()
+	n.Remove("c")
+	clock.Advance(2 * time.Minute)
+	n.Remove("d")
+	n.Add("e", epoch)
+	require.False(t, n.Has("e"))
+	n.Add("e", n.Epoch())
+	require.True(t, n.Has("e"))
+
+	epoch = n.Epoch()
+	n.Purge()
+	n.Add("a", epoch)
+	require.False(t, n.Has("a"))
+}
+
+func TestNegativeDisabled(t *testing.T) {
+	n := NewNegative(0, time.Minute)
+	n.Add("a", n.Epoch())
+	require.False(t, n.Has("a"))
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+)
+
+// Policy decides which order the cache evicts when it is over its limits. It
+// tracks keys only; the cache keeps the orders. The cache calls it under its own
+// lock, so implementations need no locking.
+type Policy interface {
+	// Insert records a key new to the cache.
+	Insert(key string)
+	// Access records a hit on, or a replacement of, a cached key.
+	Access(key string)
+	// Remove forgets a key that leaves the cache other than through Evict.
+	Remove(key string)
+	// Evict picks the next key to evict and forgets it; false when no key is tracked.
+	Evict() (string, bool)
+	// Resize adapts the policy to a new entry limit.
+	Resize(capacity int)
+	// Keys returns the tracked keys, those closest to eviction first.
+	Keys() []string
+}
+
+// NewPolicy builds the policy named by config (config.CachePolicyLRU and so on;
+// empty means LRU) for a cache of capacity entries.
+func NewPolicy(name string, capacity int) (Policy, error) {
+	switch name {
+	case "", config.CachePolicyLRU:
+		return newLRU(), nil
+	case config.CachePolicy2Q:
+		return newTwoQueue(capacity), nil
+	case config.CachePolicyARC:
+		return newARC(capacity), nil
+	case config.CachePolicyTinyLFU:
+		return newTinyLFU(capacity), nil
+	default:
+		return nil, fmt.Errorf("unknown cache policy %q", name)
+	}
+}
+
+// lru evicts the least recently used key.
+type lru struct {
+	keys *keyList
+}
+
+func newLRU() *lru { return &lru{keys: newKeyList()} }
+
+func (p *lru) Insert(key string)     { p.keys.pushFront(key) }
+func (p *lru) Access(key string)     { p.keys.moveToFront(key) }
+func (p *lru) Remove(key string)     { p.keys.remove(key) }
+func (p *lru) Evict() (string, bool) { return p.keys.popBack() }
+func (p *lru) Resize(int)            {}
+func (p *lru) Keys() []string        { return p.keys.appendKeys(nil) }
+
+// keyList is a list of distinct keys with constant-time lookup; the front is
+// the most recently added or moved.
+type keyList struct {
+	ll  *list.List
+	els map[string]*list.Element
+}
+
+func newKeyList() *keyList {
+	return &keyList{ll: list.New(), els: make(map[string]*list.Element)}
+}
+
+func (l *keyList) len() int { return l.ll.Len() }
+
+func (l *keyList) has(key string) bool {
+	_, ok := l.els[key]
+	return ok
+}
+
+func (l *keyList) pushFront(key string) {
+	l.els[key] = l.ll.PushFront(key)
+}
+
+func (l *keyList) moveToFront(key string) bool {
+	el, ok := l.els[key]
+	if ok {
+		l.ll.MoveToFront(el)
+	}
+	return ok
+}
+
+func (l *keyList) remove(key string) bool {
+	el, ok := l.els[key]
+	if ok {
+		l.ll.Remove(el)
+		delete(l.els, key)
+	}
+	return ok
+}
+
+func (l *keyList) back() (string, bool) {
+	el := l.ll.Back()
+	if el == nil {
+		return "", false
+	}
+	return el.Value.(string), true
+}
+
+func (l *keyList) popBack() (string, bool) {
+	key, ok := l.back()
+	if ok {
+		l.remove(key)
+	}
+	return key, ok
+}
+
+// trim drops keys from the back until at most n remain.
+func (l *keyList) trim(n int) {
+	for l.len() > n {
+		l.popBack()
+	}
+}
+
+// appendKeys appends the keys to dst, back first.
+func (l *keyList) appendKeys(dst []string) []string {
+	for el := l.ll.Back(); el != nil; el = el.Prev() {
+		dst = append(dst, el.Value.(string))
+	}
+	return dst
+}
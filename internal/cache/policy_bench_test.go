@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+var (
+	tracePath = flag.String("policy.trace", "", "access trace to replay in BenchmarkPolicies, one order UID per line")
+	traceCap  = flag.Int("policy.cap", 1000, "cache capacity for BenchmarkPolicies")
+)
+
+// BenchmarkPolicies replays an access trace against every eviction policy and
+// reports the hit ratio:
+//
+//	go test ./internal/cache -run '^$' -bench Policies -policy.trace trace.txt -policy.cap 5000
+//
+// Without -policy.trace a synthetic trace is used: a Zipf-distributed working
+// set interleaved with scans of one-off orders, as warm-up and the spammer
+// produce.
+func BenchmarkPolicies(b *testing.B) {
+	trace := syntheticTrace()
+	if *tracePath != "" {
+		var err error
+		if trace, err = readTrace(*tracePath); err != nil {
+			b.Fatal(err)
+		}
+	}
+	for _, name := range policies {
+		b.Run(name, func(b *testing.B) {
+			var ratio float64
+			for i := 0; i < b.N; i++ {
+				ratio = replay(b, name, trace)
+			}
+			b.ReportMetric(100*ratio, "hit%")
+			b.ReportMetric(float64(len(trace)), "accesses")
+		})
+	}
+}
+
+// replay runs trace through a fresh cache, loading every miss, and returns the
+// hit ratio.
+func replay(tb testing.TB, policy string, trace []string) float64 {
+	c, err := New(config.Cache{Cap: *traceCap, Policy: policy})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for _, uid := range trace {
+		if _, ok := c.Get(uid); !ok {
+			c.Set(&domain.Order{OrderUID: uid})
+		}
+	}
+	st := c.Stats()
+	return float64(st.Hits) / float64(st.Hits+st.Misses)
+}
+
+// readTrace reads one UID per line, skipping blank lines and # comments.
+func readTrace(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var trace []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			trace = append(trace, line)
+		}
+	}
+	return trace, sc.Err()
+}
+
+// syntheticTrace returns 200k accesses to a 5000-order Zipf working set, with a
+// scan of 2000 new orders after every 10k accesses. It is the same on every call.
+func syntheticTrace() []string {
+	rnd := rand.New(rand.NewPCG(42, 42))
+	zipf := rand.NewZipf(rnd, 1.1, 1, 4999)
+	trace := make([]string, 0, 240_000)
+	scanned := 0
+	for i := 1; i <= 200_000; i++ {
+		trace = append(trace, fmt.Sprintf("hot-%d", zipf.Uint64()))
+		if i%10_000 == 0 {
+			for j := 0; j < 2000; j++ {
+				trace = append(trace, fmt.Sprintf("scan-%d", scanned))
+				scanned++
+			}
+		}
+	}
+	return trace
+}
@@ -0,0 +1,355 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+// shard is one lock's worth of a Cache: the orders whose UIDs hash to it, with
+// their own policy, share of the limits, secondary indexes and counters. The
+// configuration, clock, loader and eviction callbacks belong to the Cache.
+type shard struct {
+	c *Cache
+
+	mu       sync.Mutex
+	cap      int
+	maxBytes int64 // 0: unbounded
+	policy   Policy
+	items    map[string]*entry
+	bytes    int64
+	gen      uint64 // last entry generation
+
+	// Secondary indexes over the shard's orders, kept in step with items.
+	byTrack    map[string]map[string]struct{} // track number -> UIDs
+	byCustomer map[string]map[string]struct{} // customer ID -> UIDs
+
+	hits, staleHits, misses, expirations int64
+	evictions                            map[EvictReason]int64
+	reads                                map[string]int64 // hits per UID since the last TakeReads
+}
+
+func newShard(c *Cache, capacity int, maxBytes int64) (*shard, error) {
+	policy, err := NewPolicy(c.cfg.Policy, capacity)
+	if err != nil {
+		return nil, err
+	}
+	return &shard{
+		c:          c,
+		cap:        capacity,
+		maxBytes:   maxBytes,
+		policy:     policy,
+		items:      make(map[string]*entry),
+		byTrack:    make(map[string]map[string]struct{}),
+		byCustomer: make(map[string]map[string]struct{}),
+		evictions:  make(map[EvictReason]int64),
+		reads:      make(map[string]int64),
+	}, nil
+}
+
+// get returns a copy of the order and whether it is stale and should be
+// reloaded by the caller; evicted is set if it was dropped past its stale window.
+func (s *shard) get(uid string) (order domain.Order, ok, reload bool, evicted []eviction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[uid]
+	if !ok {
+		s.misses++
+		return domain.Order{}, false, false, nil
+	}
+
+	now := s.c.now()
+	if s.c.expired(e, now) {
+		if now.After(e.expires.Add(s.c.staleWindow())) {
+			s.misses++
+			return domain.Order{}, false, false, []eviction{s.remove(e, EvictExpired)}
+		}
+		s.staleHits++
+		if !e.refreshing {
+			e.refreshing = true
+			reload = true
+		}
+	} else {
+		s.hits++
+	}
+	if s.c.countReads.Load() {
+		s.reads[uid]++
+	}
+	s.policy.Access(uid)
+	return e.order, true, reload, nil
+}
+
+func (s *shard) set(order *domain.Order, size int64) []eviction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var evicted []eviction
+	e, ok := s.items[order.OrderUID]
+	switch {
+	case ok && order.Version != 0 && e.order.Version > order.Version:
+		return nil
+	case s.maxBytes > 0 && size > s.maxBytes:
+		// Not cached, so the customer's orders no longer all are.
+		s.c.customers.forget(order.CustomerID)
+		if ok {
+			evicted = append(evicted, s.remove(e, EvictBytes))
+		}
+	case ok:
+		s.bytes += size - e.size
+		s.unindex(&e.order)
+		s.gen++
+		*e = entry{order: *order, size: size, stored: s.c.now(), expires: s.c.expiry(), gen: s.gen}
+		s.index(&e.order)
+		s.policy.Access(order.OrderUID)
+	default:
+		s.gen++
+		e = &entry{order: *order, size: size, stored: s.c.now(), expires: s.c.expiry(), gen: s.gen}
+		s.items[order.OrderUID] = e
+		s.index(&e.order)
+		s.bytes += size
+		s.policy.Insert(order.OrderUID)
+	}
+	return append(evicted, s.shrink()...)
+}
+
+// track returns the UID and creation date of the shard's newest order with the
+// track number.
+func (s *shard) track(track string) (string, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var (
+		newest  string
+		created time.Time
+	)
+	for uid := range s.byTrack[track] {
+		c := s.items[uid].order.DateCreated
+		if newest == "" || c.After(created) || c.Equal(created) && uid < newest {
+			newest, created = uid, c
+		}
+	}
+	return newest, created, newest != ""
+}
+
+// customer appends copies of the customer's orders in the shard to orders.
+func (s *shard) customer(customerID string, orders []*domain.Order) []*domain.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uid := range s.byCustomer[customerID] {
+		order := s.items[uid].order
+		orders = append(orders, &order)
+	}
+	return orders
+}
+
+func (s *shard) customerLen(customerID string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.byCustomer[customerID])
+}
+
+func (s *shard) version(uid string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[uid]
+	if !ok {
+		return 0, false
+	}
+	return e.order.Version, true
+}
+
+func (s *shard) inspect(uid string) (EntryInfo, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[uid]
+	if !ok {
+		return EntryInfo{}, false
+	}
+	now := s.c.now()
+	info := EntryInfo{
+		UID:     uid,
+		Version: e.order.Version,
+		Size:    e.size,
+		Age:     now.Sub(e.stored),
+		Stale:   s.c.expired(e, now),
+	}
+	if !e.expires.IsZero() {
+		info.ExpiresIn = e.expires.Sub(now)
+	}
+	return info, true
+}
+
+// delete removes uid, if cached, as EvictRemoved.
+func (s *shard) delete(uid string) (eviction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.items[uid]
+	if !ok {
+		return eviction{}, false
+	}
+	return s.remove(e, EvictRemoved), true
+}
+
+// unmark lets the next stale hit on uid retry the reload.
+func (s *shard) unmark(uid string) {
+	s.mu.Lock()
+	if e, ok := s.items[uid]; ok {
+		e.refreshing = false
+	}
+	s.mu.Unlock()
+}
+
+func (s *shard) purge() []eviction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	evicted := make([]eviction, 0, len(s.items))
+	for _, uid := range s.policy.Keys() {
+		evicted = append(evicted, s.remove(s.items[uid], EvictRemoved))
+	}
+	return evicted
+}
+
+func (s *shard) purgeExpired() []eviction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.c.now()
+	var evicted []eviction
+	for _, e := range s.items {
+		if s.c.expired(e, now) && now.After(e.expires.Add(s.c.staleWindow())) {
+			evicted = append(evicted, s.remove(e, EvictExpired))
+		}
+	}
+	return evicted
+}
+
+func (s *shard) resize(capacity int) []eviction {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cap = capacity
+	s.policy.Resize(capacity)
+	return s.shrink()
+}
+
+func (s *shard) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.policy.Keys()
+}
+
+// orders appends copies of the shard's orders, those closest to eviction first.
+func (s *shard) orders(orders []domain.Order) []domain.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, uid := range s.policy.Keys() {
+		orders = append(orders, s.items[uid].order)
+	}
+	return orders
+}
+
+func (s *shard) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+// addStats adds the shard's size and counters to st.
+func (s *shard) addStats(st *observability.CacheStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st.Entries += len(s.items)
+	st.Bytes += s.bytes
+	st.Hits += s.hits
+	st.StaleHits += s.staleHits
+	st.Misses += s.misses
+	st.Expirations += s.expirations
+	for reason, n := range s.evictions {
+		st.Evictions[string(reason)] += n
+	}
+}
+
+// takeReads adds the shard's read counters to reads and resets them.
+func (s *shard) takeReads(reads map[string]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uid, n := range s.reads {
+		reads[uid] += n
+	}
+	s.reads = make(map[string]int64, len(s.reads))
+}
+
+// shrink evicts the entries the policy picks until both limits hold.
+// The caller holds s.mu.
+func (s *shard) shrink() []eviction {
+	var evicted []eviction
+	for len(s.items) > s.cap {
+		evicted = append(evicted, s.evict(EvictCapacity))
+	}
+	for s.maxBytes > 0 && s.bytes > s.maxBytes {
+		evicted = append(evicted, s.evict(EvictBytes))
+	}
+	return evicted
+}
+
+// evict drops the entry the policy picks. The caller holds s.mu.
+func (s *shard) evict(reason EvictReason) eviction {
+	uid, ok := s.policy.Evict()
+	if !ok {
+		panic("cache: policy has no key to evict from a non-empty cache")
+	}
+	return s.drop(s.items[uid], reason)
+}
+
+// remove drops e outside of the policy's choice. The caller holds s.mu.
+func (s *shard) remove(e *entry, reason EvictReason) eviction {
+	s.policy.Remove(e.order.OrderUID)
+	return s.drop(e, reason)
+}
+
+// drop unlinks e, which the policy no longer tracks, and counts the eviction.
+// The caller holds s.mu.
+func (s *shard) drop(e *entry, reason EvictReason) eviction {
+	delete(s.items, e.order.OrderUID)
+	s.unindex(&e.order)
+	s.c.customers.forget(e.order.CustomerID)
+	s.bytes -= e.size
+	if reason == EvictExpired {
+		s.expirations++
+	} else {
+		s.evictions[reason]++
+	}
+	return eviction{order: e.order, reason: reason}
+}
+
+// index adds o to the secondary indexes. The caller holds s.mu.
+func (s *shard) index(o *domain.Order) {
+	if o.TrackNumber != "" {
+		addUID(s.byTrack, o.TrackNumber, o.OrderUID)
+	}
+	if o.CustomerID != "" {
+		addUID(s.byCustomer, o.CustomerID, o.OrderUID)
+	}
+}
+
+// unindex removes o from the secondary indexes. The caller holds s.mu.
+func (s *shard) unindex(o *domain.Order) {
+	removeUID(s.byTrack, o.TrackNumber, o.OrderUID)
+	removeUID(s.byCustomer, o.CustomerID, o.OrderUID)
+}
+
+func addUID(index map[string]map[string]struct{}, key, uid string) {
+	uids, ok := index[key]
+	if !ok {
+		uids = make(map[string]struct{})
+		index[key] = uids
+	}
+	uids[uid] = struct{}{}
+}
+
+func removeUID(index map[string]map[string]struct{}, key, uid string) {
+	if uids, ok := index[key]; ok {
+		delete(uids, uid)
+		if len(uids) == 0 {
+			delete(index, key)
+		}
+	}
+}
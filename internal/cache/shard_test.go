@@ -0,0 +1,159 @@
+package cache
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func TestShardsSplitLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Cache
+		shards   int
+		caps     []int
+		maxBytes []int64
+	}{
+		{name: "default is one shard", cfg: config.Cache{Cap: 10}, shards: 1, caps: []int{10}, maxBytes: []int64{0}},
+		{name: "remainder goes to the first shards", cfg: config.Cache{Cap: 10, MaxBytes: 1001, Shards: 4},
+			shards: 4, caps: []int{3, 3, 2, 2}, maxBytes: []int64{251, 250, 250, 250}},
+		{name: "no more shards than capacity", cfg: config.Cache{Cap: 3, Shards: 16}, shards: 3, caps: []int{1, 1, 1}, maxBytes: []int64{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCache(t, tt.cfg)
+			require.Equal(t, tt.shards, c.Shards())
+			for i, s := range c.shards {
+				require.Equal(t, tt.caps[i], s.cap)
+				require.Equal(t, tt.maxBytes[i], s.maxBytes)
+			}
+			require.Equal(t, tt.cfg.Cap, c.Stats().Capacity)
+		})
+	}
+}
+
+func TestShardedCache(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 1000, Shards: 8})
+	day := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 200; i++ {
+		c.Set(&domain.Order{
+			OrderUID:    fmt.Sprintf("order-%03d", i),
+			TrackNumber: fmt.Sprintf("T%d", i%10), // shared by orders in different shards
+			CustomerID:  fmt.Sprintf("customer-%d", i%4),
+			DateCreated: day.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	require.Equal(t, 200, c.Len())
+	require.Len(t, c.Keys(), 200)
+	for _, s := range c.shards {
+		require.NotZero(t, s.len(), "every shard gets some orders")
+	}
+
+	for i := 0; i < 200; i++ {
+		uid := fmt.Sprintf("order-%03d", i)
+		o, ok := c.Get(uid)
+		require.True(t, ok)
+		require.Equal(t, uid, o.OrderUID)
+	}
+
+	// Secondary lookups see every shard.
+	o, ok := c.GetByTrackNumber("T3")
+	require.True(t, ok)
+	require.Equal(t, "order-193", o.OrderUID)
+	listed := uids(c.ListByCustomer("customer-1"))
+	require.Len(t, listed, 50)
+	require.Equal(t, "order-197", listed[0])
+	require.Equal(t, "order-001", listed[49])
+
+	st := c.Stats()
+	require.Equal(t, 200, st.Entries)
+	require.Equal(t, int64(201), st.Hits) // 200 Gets and the track lookup
+
+	require.Equal(t, 200, c.Purge())
+	require.Zero(t, c.Len())
+	require.Equal(t, int64(200), c.Stats().Evictions["removed"])
+}
+
+func TestShardedResize(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 100, Shards: 4})
+	for i := 0; i < 200; i++ {
+		c.Set(&domain.Order{OrderUID: fmt.Sprintf("order-%03d", i)})
+	}
+	// Keys are not spread evenly, so a shard may hold less than its share.
+	require.LessOrEqual(t, c.Len(), 100)
+
+	_, err := c.Resize(2)
+	require.NoError(t, err)
+	require.LessOrEqual(t, c.Len(), 2)
+	require.Equal(t, 2, c.Cap())
+	require.Equal(t, 4, c.Shards())
+
+	_, err = c.Resize(400)
+	require.NoError(t, err)
+	for i := 0; i < 200; i++ {
+		c.Set(&domain.Order{OrderUID: fmt.Sprintf("order-%03d", i)})
+	}
+	require.Equal(t, 200, c.Len())
+}
+
+func TestShardedSnapshotRoundTrip(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 100, Shards: 4})
+	for i := 0; i < 50; i++ {
+		c.Set(&domain.Order{OrderUID: fmt.Sprintf("order-%02d", i), Version: int64(i)})
+	}
+	path := filepath.Join(t.TempDir(), "snapshot")
+	_, err := c.SaveSnapshot(path)
+	require.NoError(t, err)
+
+	// The shard count may change between restarts.
+	restored, _ := newTestCache(t, config.Cache{Cap: 100, Shards: 3})
+	n, err := restored.LoadSnapshot(path, 0)
+	require.NoError(t, err)
+	require.Equal(t, 50, n)
+	for i := 0; i < 50; i++ {
+		v, ok := restored.Version(fmt.Sprintf("order-%02d", i))
+		require.True(t, ok)
+		require.Equal(t, int64(i), v)
+	}
+}
+
+func TestShardedConcurrentAccess(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 50, Shards: 4, Policy: config.CachePolicyTinyLFU})
+	var evicted atomic.Int64
+	c.OnEvict(func(domain.Order, EvictReason) { evicted.Add(1) })
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				uid := fmt.Sprintf("order-%d", (w*31+i)%200)
+				switch i % 4 {
+				case 0:
+					c.Set(&domain.Order{OrderUID: uid, CustomerID: "alice"})
+				case 1:
+					c.Remove(uid)
+				case 2:
+					c.ListByCustomer("alice")
+				default:
+					c.Get(uid)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.NotZero(t, evicted.Load())
+	require.LessOrEqual(t, c.Len(), 50)
+	require.Len(t, c.Keys(), c.Len())
+	require.Len(t, uids(c.ListByCustomer("alice")), c.Len())
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"unsafe"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+// entryOverhead approximates the bookkeeping around each cached order:
+// the list element, the entry and the map slot.
+const entryOverhead = int64(unsafe.Sizeof(entry{})) + 64
+
+// Size estimates the memory an order occupies in the cache: the structs
+// themselves plus the bytes behind their strings and the items slice.
+func Size(o *domain.Order) int64 {
+	n := entryOverhead + int64(cap(o.Items))*int64(unsafe.Sizeof(domain.Item{}))
+	n += strlen(o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature,
+		o.CustomerID, o.DeliveryService, o.ShardKey, o.OofShard)
+
+	d := &o.Delivery
+	n += strlen(d.Name, d.Phone, d.Zip, d.City, d.Address, d.Region, d.Email)
+
+	p := &o.Payment
+	n += strlen(p.Transaction, p.RequestID, p.Currency, p.Provider, p.Bank)
+
+	for i := range o.Items {
+		it := &o.Items[i]
+		n += strlen(it.TrackNumber, it.RID, it.Name, it.Size, it.Brand)
+	}
+	return n
+}
+
+func strlen(ss ...string) int64 {
+	var n int64
+	for _, s := range ss {
+		n += int64(len(s))
+	}
+	return n
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"go.uber.org/zap"
+)
+
+// Snapshot file layout:
+//
+//	magic "WBCS" | format version uint16 | created unix nanos int64 | count uint32
+//	gob stream of count orders shard by shard, within a shard those closest to eviction first
+//	CRC-32 (Castagnoli) of everything above, uint32
+//
+// Integers are big-endian.
+const (
+	snapshotMagic   = "WBCS"
+	snapshotVersion = 1
+	snapshotHeader  = len(snapshotMagic) + 2 + 8 + 4
+)
+
+var (
+	ErrSnapshotCorrupt = errors.New("cache snapshot is corrupt")
+	ErrSnapshotVersion = errors.New("cache snapshot has an unsupported format version")
+	ErrSnapshotStale   = errors.New("cache snapshot is too old")
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SaveSnapshot writes the cached orders to path, replacing it atomically,
+// and returns how many were written.
+func (c *Cache) SaveSnapshot(path string) (int, error) {
+	orders := c.orders()
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return 0, err
+		}
+	}
+	// A temp file of its own, so concurrent saves cannot interleave.
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmp := f.Name()
+
+	crc := crc32.New(crcTable)
+	w := bufio.NewWriter(io.MultiWriter(f, crc))
+	header := make([]byte, snapshotHeader)
+	copy(header, snapshotMagic)
+	binary.BigEndian.PutUint16(header[4:], snapshotVersion)
+	binary.BigEndian.PutUint64(header[6:], uint64(c.now().UnixNano()))
+	binary.BigEndian.PutUint32(header[14:], uint32(len(orders)))
+	_, err = w.Write(header)
+	if err == nil {
+		enc := gob.NewEncoder(w)
+		for i := range orders {
+			if err = enc.Encode(&orders[i]); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = binary.Write(f, binary.BigEndian, crc.Sum32())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+	return len(orders), nil
+}
+
+// LoadSnapshot fills the cache from the snapshot at path, restoring recency
+// order, and returns how many orders were loaded. Snapshots older than maxAge
+// (if positive) are refused with ErrSnapshotStale. A missing file is reported as
+// an error satisfying errors.Is(err, fs.ErrNotExist).
+//
+// Loaded orders may be outdated; revalidate them against storage (Syncer.Resync)
+// before relying on them.
+func (c *Cache) LoadSnapshot(path string, maxAge time.Duration) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) < snapshotHeader+4 || string(data[:4]) != snapshotMagic {
+		return 0, ErrSnapshotCorrupt
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.Checksum(body, crcTable) != sum {
+		return 0, ErrSnapshotCorrupt
+	}
+	if v := binary.BigEndian.Uint16(body[4:]); v != snapshotVersion {
+		return 0, fmt.Errorf("%w: %d", ErrSnapshotVersion, v)
+	}
+	created := time.Unix(0, int64(binary.BigEndian.Uint64(body[6:])))
+	if age := c.now().Sub(created); maxAge > 0 && age > maxAge {
+		return 0, fmt.Errorf("%w: created %s ago", ErrSnapshotStale, age.Round(time.Second))
+	}
+	count := int(binary.BigEndian.Uint32(body[14:]))
+
+	dec := gob.NewDecoder(bytes.NewReader(body[snapshotHeader:]))
+	orders := make([]domain.Order, 0, min(count, c.Cap()))
+	for i := 0; i < count; i++ {
+		var o domain.Order
+		if err := dec.Decode(&o); err != nil {
+			return 0, fmt.Errorf("%w: order %d: %v", ErrSnapshotCorrupt, i, err)
+		}
+		orders = append(orders, o)
+	}
+	for i := range orders {
+		c.Set(&orders[i])
+	}
+	return len(orders), nil
+}
+
+// RunSnapshots saves a snapshot to path every interval until ctx is done.
+// The final snapshot on shutdown is up to the caller, once RunSnapshots has
+// returned, so that a periodic save cannot replace it.
+func (c *Cache) RunSnapshots(ctx context.Context, path string, interval time.Duration, logger *zap.Logger) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		start := time.Now()
+		n, err := c.SaveSnapshot(path)
+		if err != nil {
+			logger.Error("failed to save cache snapshot", zap.String("path", path), zap.Error(err))
+			continue
+		}
+		logger.Debug("cache snapshot saved",
+			zap.String("path", path),
+			zap.Int("orders", n),
+			zap.Duration("elapsed", time.Since(start)),
+		)
+	}
+}
+
+// orders returns copies of the cached orders shard by shard, within a shard
+// those closest to eviction first. Loading them back in this order restores each
+// shard's order, whatever shard the orders land in.
+func (c *Cache) orders() []domain.Order {
+	orders := make([]domain.Order, 0, c.Len())
+	for _, s := range c.shards {
+		orders = s.orders(orders)
+	}
+	return orders
+}
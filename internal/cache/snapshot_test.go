@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func saveTestSnapshot(t *testing.T) (string, *fakeClock) {
+	c, clock := newTestCache(t, config.Cache{Cap: 10})
+	c.Set(&domain.Order{OrderUID: "a", Version: 3, TrackNumber: "WBILMTESTTRACK"})
+	c.Set(&domain.Order{OrderUID: "b", Version: 1})
+	c.Set(&domain.Order{OrderUID: "c", Version: 2})
+	c.Get("a") // "b" becomes the least recently used
+
+	path := filepath.Join(t.TempDir(), "cache", "snapshot")
+	n, err := c.SaveSnapshot(path)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	return path, clock
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	path, clock := saveTestSnapshot(t)
+
+	c, _ := newTestCache(t, config.Cache{Cap: 10})
+	c.now = clock.Now
+	n, err := c.LoadSnapshot(path, time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, []string{"b", "c", "a"}, c.Keys())
+	v, ok := c.Version("a")
+	require.True(t, ok)
+	require.Equal(t, int64(3), v)
+
+	o, ok := c.Get("a")
+	require.True(t, ok)
+	require.Equal(t, "WBILMTESTTRACK", o.TrackNumber)
+}
+
+func TestSnapshotKeepsMostRecentWhenSmaller(t *testing.T) {
+	path, clock := saveTestSnapshot(t)
+
+	c, _ := newTestCache(t, config.Cache{Cap: 2})
+	c.now = clock.Now
+	_, err := c.LoadSnapshot(path, 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c", "a"}, c.Keys())
+}
+
+func TestSnapshotRejected(t *testing.T) {
+	cases := []struct {
+		name    string
+		mangle  func(data []byte) []byte
+		advance time.Duration
+		err     error
+	}{
+		{
+			name:   "checksum",
+			mangle: func(data []byte) []byte { data[snapshotHeader+1] ^= 0xff; return data },
+			err:    ErrSnapshotCorrupt,
+		},
+		{
+			name:   "truncated",
+			mangle: func(data []byte) []byte { return data[:len(data)/2] },
+			err:    ErrSnapshotCorrupt,
+		},
+		{
+			name:   "magic",
+			mangle: func(data []byte) []byte { copy(data, "JSON"); return data },
+			err:    ErrSnapshotCorrupt,
+		},
+		{
+			name: "format version",
+			mangle: func(data []byte) []byte {
+				body := data[:len(data)-4]
+				binary.BigEndian.PutUint16(body[4:], snapshotVersion+1)
+				binary.BigEndian.PutUint32(data[len(data)-4:], crc32Sum(body))
+				return data
+			},
+			err: ErrSnapshotVersion,
+		},
+		{
+			name:    "too old",
+			mangle:  func(data []byte) []byte { return data },
+			advance: 2 * time.Hour,
+			err:     ErrSnapshotStale,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path, clock := saveTestSnapshot(t)
+			data, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NoError(t, os.WriteFile(path, tc.mangle(data), 0o644))
+			clock.Advance(tc.advance)
+
+			c, _ := newTestCache(t, config.Cache{Cap: 10})
+			c.now = clock.Now
+			_, err = c.LoadSnapshot(path, time.Hour)
+			require.ErrorIs(t, err, tc.err)
+			require.Zero(t, c.Len())
+		})
+	}
+}
+
+func TestSnapshotConcurrentSaves(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 100})
+	for i := range 100 {
+		c.Set(&domain.Order{OrderUID: fmt.Sprint(i), Version: 1})
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "snapshot")
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.SaveSnapshot(path)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	loaded, _ := newTestCache(t, config.Cache{Cap: 100})
+	n, err := loaded.LoadSnapshot(path, 0)
+	require.NoError(t, err)
+	require.Equal(t, 100, n)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "no temp files are left")
+}
+
+func TestSnapshotMissing(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 10})
+	_, err := c.LoadSnapshot(filepath.Join(t.TempDir(), "none"), time.Hour)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestPurge(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 10})
+	var reasons []EvictReason
+	c.OnEvict(func(_ domain.Order, reason EvictReason) { reasons = append(reasons, reason) })
+	c.Set(&domain.Order{OrderUID: "a"})
+	c.Set(&domain.Order{OrderUID: "b"})
+
+	require.Equal(t, 2, c.Purge())
+	require.Zero(t, c.Len())
+	require.Zero(t, c.Stats().Bytes)
+	require.Equal(t, []EvictReason{EvictRemoved, EvictRemoved}, reasons)
+}
+
+func crc32Sum(b []byte) uint32 {
+	return crc32.Checksum(b, crcTable)
+}
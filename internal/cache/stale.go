@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+// Stale keeps recently evicted orders as a fallback for when storage is down. It
+// is an LRU bounded by count whose entries are dropped after maxAge (0 = kept
+// until pushed out). Register Add with Cache.OnEvict; orders removed explicitly
+// are not kept, as they are known to be outdated.
+type Stale struct {
+	cap    int
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	ll    *list.List // front is the most recently evicted
+	items map[string]*list.Element
+}
+
+type staleEntry struct {
+	order   *domain.Order
+	evicted time.Time
+}
+
+func NewStale(cap int, maxAge time.Duration) *Stale {
+	return &Stale{
+		cap:    cap,
+		maxAge: maxAge,
+		now:    time.Now,
+		ll:     list.New(),
+		items:  make(map[string]*list.Element),
+	}
+}
+
+// Add keeps an order that left the cache for the given reason. It has the
+// EvictFunc signature.
+func (s *Stale) Add(order domain.Order, reason EvictReason) {
+	if s.cap <= 0 || reason == EvictRemoved {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := &staleEntry{order: &order, evicted: s.now()}
+	if el, ok := s.items[order.OrderUID]; ok {
+		el.Value = e
+		s.ll.MoveToFront(el)
+		return
+	}
+	s.items[order.OrderUID] = s.ll.PushFront(e)
+	for s.ll.Len() > s.cap {
+		el := s.ll.Back()
+		s.ll.Remove(el)
+		delete(s.items, el.Value.(*staleEntry).order.OrderUID)
+	}
+}
+
+// Get returns the last evicted copy of uid. It is shared and must not be modified.
+func (s *Stale) Get(uid string) (*domain.Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[uid]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*staleEntry)
+	if s.maxAge > 0 && s.now().Sub(e.evicted) > s.maxAge {
+		s.ll.Remove(el)
+		delete(s.items, uid)
+		return nil, false
+	}
+	return e.order, true
+}
+
+// Remove forgets uid, typically because a newer copy has just been written.
+func (s *Stale) Remove(uid string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[uid]; ok {
+		s.ll.Remove(el)
+		delete(s.items, uid)
+	}
+}
+
+func (s *Stale) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func TestStale(t *testing.T) {
+	s := NewStale(2, time.Minute)
+	clock := &fakeClock{now: time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)}
+	s.now = clock.Now
+
+	s.Add(domain.Order{OrderUID: "a"}, EvictCapacity)
+	got, ok := s.Get("a")
+	require.True(t, ok)
+	require.Equal(t, "a", got.OrderUID)
+
+	// Explicit removals are not kept.
+	s.Add(domain.Order{OrderUID: "r"}, EvictRemoved)
+	_, ok = s.Get("r")
+	require.False(t, ok)
+
+	// Bounded: the oldest eviction goes first.
+	s.Add(domain.Order{OrderUID: "b"}, EvictBytes)
+	s.Add(domain.Order{OrderUID: "c"}, EvictExpired)
+	_, ok = s.Get("a")
+	require.False(t, ok)
+	require.Equal(t, 2, s.Len())
+
+	s.Remove("b")
+	_, ok = s.Get("b")
+	require.False(t, ok)
+
+	// Entries age out.
+	clock.Advance(2 * time.Minute)
+	_, ok = s.Get("c")
+	require.False(t, ok)
+	require.Zero(t, s.Len())
+}
+
+func TestStaleKeepsCacheEvictions(t *testing.T) {
+	c, _ := newTestCache(t, config.Cache{Cap: 1})
+	s := NewStale(10, 0)
+	c.OnEvict(s.Add)
+
+	c.Set(&domain.Order{OrderUID: "a", TrackNumber: "T1"})
+	c.Set(&domain.Order{OrderUID: "b"})
+	got, ok := s.Get("a")
+	require.True(t, ok)
+	require.Equal(t, "T1", got.TrackNumber)
+
+	c.Remove("b")
+	_, ok = s.Get("b")
+	require.False(t, ok)
+}
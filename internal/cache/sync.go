@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// resyncChunk bounds the number of UIDs checked per Versions query during Resync.
+const resyncChunk = 1000
+
+// Syncer keeps a Cache consistent with changes made by other instances: it reacts
+// to change notifications and revalidates the whole cache when some may have been missed.
+type Syncer struct {
+	cache    *Cache
+	negative *Negative
+	stale    *Stale // nil without a stale store
+	repo     syncRepo
+	logger   *zap.Logger
+}
+
+// NewSyncer wires the syncer. stale may be nil when evicted orders are not kept.
+func NewSyncer(cache *Cache, negative *Negative, stale *Stale, repo syncRepo, logger *zap.Logger) *Syncer {
+	return &Syncer{
+		cache:    cache,
+		negative: negative,
+		stale:    stale,
+		repo:     repo,
+		logger:   logger,
+	}
+}
+
+// OrderChanged refreshes uid from storage if the cached copy is older than version.
+// Orders that are not cached are left alone; they will be read fresh on demand,
+// but as one may be new to its customer, no customer is taken to be fully
+// cached any more. The UID is no longer missing and its stale copy is outdated
+// either way.
+func (s *Syncer) OrderChanged(ctx context.Context, uid string, version int64) {
+	s.negative.Remove(uid)
+	s.removeStale(uid)
+
+	cur, ok := s.cache.Version(uid)
+	if !ok {
+		s.cache.ForgetCustomers()
+		return
+	}
+	if cur >= version {
+		return
+	}
+
+	order, err := s.repo.GetByUID(ctx, uid)
+	if err != nil {
+		s.cache.Remove(uid)
+		s.logger.Warn("failed to refresh changed order, evicted",
+			zap.String("order_uid", uid),
+			zap.Int64("version", version),
+			zap.Error(err),
+		)
+		return
+	}
+	s.cache.Set(order)
+	s.logger.Debug("cached order refreshed",
+		zap.String("order_uid", uid),
+		zap.Int64("from_version", cur),
+		zap.Int64("to_version", order.Version),
+	)
+}
+
+// Resync evicts every cached order whose version differs from storage or that no
+// longer exists there, along with its stale copy, and forgets every UID known to be missing and every
+// customer known to be fully cached. It returns the first storage error;
+// entries checked before it are still revalidated.
+func (s *Syncer) Resync(ctx context.Context) error {
+	s.negative.Purge()
+	s.cache.ForgetCustomers()
+
+	keys := s.cache.Keys()
+	dropped := 0
+	for start := 0; start < len(keys); start += resyncChunk {
+		chunk := keys[start:min(start+resyncChunk, len(keys))]
+		versions, err := s.repo.Versions(ctx, chunk)
+		if err != nil {
+			s.logger.Error("cache resync failed", zap.Int("checked", start), zap.Error(err))
+			return err
+		}
+		for _, uid := range chunk {
+			cur, ok := s.cache.Version(uid)
+			if !ok {
+				continue
+			}
+			if v, found := versions[uid]; !found || v != cur {
+				s.cache.Remove(uid)
+				s.removeStale(uid)
+				dropped++
+			}
+		}
+	}
+	s.logger.Info("cache resynced", zap.Int("checked", len(keys)), zap.Int("dropped", dropped))
+	return nil
+}
+
+func (s *Syncer) removeStale(uid string) {
+	if s.stale != nil {
+		s.stale.Remove(uid)
+	}
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func newSyncTest(t *testing.T, orders ...domain.Order) (*Cache, *MocksyncRepo, *Syncer) {
+	c, _, _, repo, s := newSyncTestStores(t, orders...)
+	return c, repo, s
+}
+
+func newSyncTestStores(t *testing.T, orders ...domain.Order) (*Cache, *Negative, *Stale, *MocksyncRepo, *Syncer) {
+	ctrl := gomock.NewController(t)
+	repo := NewMocksyncRepo(ctrl)
+
+	c, err := New(config.Cache{Cap: 10})
+	require.NoError(t, err)
+	for i := range orders {
+		c.Set(&orders[i])
+	}
+	negative := NewNegative(10, time.Minute)
+	stale := NewStale(10, 0)
+	return c, negative, stale, repo, NewSyncer(c, negative, stale, repo, zap.NewNop())
+}
+
+func TestSetKeepsNewerVersion(t *testing.T) {
+	c, err := New(config.Cache{Cap: 10})
+	require.NoError(t, err)
+
+	c.Set(&domain.Order{OrderUID: "a", Version: 3})
+	c.Set(&domain.Order{OrderUID: "a", Version: 2})
+
+	v, ok := c.Version("a")
+	require.True(t, ok)
+	require.Equal(t, int64(3), v)
+}
+
+func TestOrderChanged(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int64
+		setup   func(repo *MocksyncRepo)
+		cached  bool
+		want    int64
+	}{
+		{
+			name:    "newer version is reloaded",
+			version: 3,
+			setup: func(repo *MocksyncRepo) {
+				repo.EXPECT().GetByUID(gomock.Any(), "a").Return(&domain.Order{OrderUID: "a", Version: 3}, nil)
+			},
+			cached: true,
+			want:   3,
+		},
+		{
+			name:    "same version is ignored",
+			version: 2,
+			setup:   func(repo *MocksyncRepo) {},
+			cached:  true,
+			want:    2,
+		},
+		{
+			name:    "reload failure evicts",
+			version: 3,
+			setup: func(repo *MocksyncRepo) {
+				repo.EXPECT().GetByUID(gomock.Any(), "a").Return(nil, errors.New("db down"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, repo, s := newSyncTest(t, domain.Order{OrderUID: "a", Version: 2})
+			tt.setup(repo)
+
+			s.OrderChanged(context.Background(), "a", tt.version)
+
+			v, ok := c.Version("a")
+			require.Equal(t, tt.cached, ok)
+			require.Equal(t, tt.want, v)
+		})
+	}
+}
+
+func TestOrderChangedIgnoresUncached(t *testing.T) {
+	c, _, s := newSyncTest(t, domain.Order{OrderUID: "b", CustomerID: "alice"})
+	c.CustomerListed(c.WatchCustomer("alice"), 1)
+
+	s.OrderChanged(context.Background(), "a", 1)
+
+	_, ok := c.Get("a")
+	require.False(t, ok)
+	_, complete := c.ListByCustomer("alice")
+	require.False(t, complete, "a may be a new order of alice")
+}
+
+func TestResync(t *testing.T) {
+	c, repo, s := newSyncTest(t,
+		domain.Order{OrderUID: "fresh", Version: 1},
+		domain.Order{OrderUID: "stale", Version: 1},
+		domain.Order{OrderUID: "deleted", Version: 1},
+	)
+	repo.EXPECT().Versions(gomock.Any(), gomock.InAnyOrder([]string{"fresh", "stale", "deleted"})).
+		Return(map[string]int64{"fresh": 1, "stale": 2}, nil)
+
+	require.NoError(t, s.Resync(context.Background()))
+	require.Equal(t, []string{"fresh"}, c.Keys())
+}
+
+func TestResyncError(t *testing.T) {
+	c, repo, s := newSyncTest(t, domain.Order{OrderUID: "a", Version: 1})
+	repo.EXPECT().Versions(gomock.Any(), gomock.Any()).Return(nil, errors.New("db down"))
+
+	require.Error(t, s.Resync(context.Background()))
+	require.Equal(t, []string{"a"}, c.Keys())
+}
+
+func TestSyncerClearsNegative(t *testing.T) {
+	_, negative, _, repo, s := newSyncTestStores(t)
+	negative.Add("a", negative.Epoch())
+	negative.Add("b", negative.Epoch())
+
+	s.OrderChanged(context.Background(), "a", 1)
+	require.False(t, negative.Has("a"))
+	require.True(t, negative.Has("b"))
+
+	repo.EXPECT().Versions(gomock.Any(), gomock.Any()).Times(0)
+	require.NoError(t, s.Resync(context.Background()))
+	require.Zero(t, negative.Len())
+}
+
+func TestSyncerClearsStale(t *testing.T) {
+	c, _, stale, repo, s := newSyncTestStores(t,
+		domain.Order{OrderUID: "fresh", Version: 1},
+		domain.Order{OrderUID: "changed", Version: 1},
+	)
+	for _, uid := range []string{"a", "b", "fresh", "changed"} {
+		stale.Add(domain.Order{OrderUID: uid}, EvictCapacity)
+	}
+
+	s.OrderChanged(context.Background(), "a", 1)
+	_, ok := stale.Get("a")
+	require.False(t, ok, "a changed elsewhere")
+
+	repo.EXPECT().Versions(gomock.Any(), gomock.InAnyOrder([]string{"fresh", "changed"})).
+		Return(map[string]int64{"fresh": 1, "changed": 2}, nil)
+	require.NoError(t, s.Resync(context.Background()))
+	require.Equal(t, []string{"fresh"}, c.Keys())
+
+	_, ok = stale.Get("changed")
+	require.False(t, ok)
+	for _, uid := range []string{"b", "fresh"} {
+		_, ok := stale.Get(uid)
+		require.True(t, ok, uid)
+	}
+}
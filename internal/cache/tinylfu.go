@@ -0,0 +1,197 @@
+package cache
+
+import (
+	"hash/maphash"
+	"math/bits"
+)
+
+// tinyLFU is W-TinyLFU (Einziger, Friedman and Manes): a small LRU window admits
+// every new key, and a key leaving the window only enters the main segmented
+// LRU (probation and protected) if it has been requested more often than the
+// key main would evict for it. Frequencies come from a count-min sketch that is
+// halved periodically, so popularity ages. One-off keys of a scan die in the
+// window instead of pushing out the working set.
+type tinyLFU struct {
+	windowCap, protectedCap int
+
+	window, probation, protected *keyList
+	// candidate is the key last moved from the window into probation; the next
+	// eviction decides between it and the probation victim.
+	candidate string
+	sketch    *countMin
+}
+
+func newTinyLFU(capacity int) *tinyLFU {
+	p := &tinyLFU{window: newKeyList(), probation: newKeyList(), protected: newKeyList()}
+	p.Resize(capacity)
+	return p
+}
+
+func (p *tinyLFU) Resize(capacity int) {
+	p.windowCap = max(1, capacity/100)
+	p.protectedCap = (capacity - p.windowCap) * 8 / 10
+	if p.sketch == nil || p.sketch.capacity < capacity {
+		p.sketch = newCountMin(capacity)
+	}
+	p.demote()
+}
+
+func (p *tinyLFU) Insert(key string) {
+	p.sketch.add(key)
+	p.window.pushFront(key)
+	for p.window.len() > p.windowCap {
+		moved, _ := p.window.popBack()
+		p.probation.pushFront(moved)
+		p.candidate = moved
+	}
+}
+
+func (p *tinyLFU) Access(key string) {
+	p.sketch.add(key)
+	switch {
+	case p.window.moveToFront(key):
+	case p.probation.remove(key):
+		p.protected.pushFront(key)
+		p.demote()
+	default:
+		p.protected.moveToFront(key)
+	}
+}
+
+// demote moves keys over the protected share back to probation.
+func (p *tinyLFU) demote() {
+	for p.protected.len() > p.protectedCap {
+		key, _ := p.protected.popBack()
+		p.probation.pushFront(key)
+	}
+}
+
+func (p *tinyLFU) Remove(key string) {
+	if key == p.candidate {
+		p.candidate = ""
+	}
+	_ = p.window.remove(key) || p.probation.remove(key) || p.protected.remove(key)
+}
+
+func (p *tinyLFU) Evict() (string, bool) {
+	candidate := p.candidate
+	p.candidate = ""
+	victim, ok := p.probation.back()
+	if !ok {
+		if victim, ok = p.protected.back(); !ok {
+			return p.window.popBack()
+		}
+	}
+
+	// Admission: the newcomer stays only if it is more popular than the victim.
+	if candidate != "" && candidate != victim && p.probation.has(candidate) &&
+		p.sketch.estimate(candidate) <= p.sketch.estimate(victim) {
+		victim = candidate
+	}
+	_ = p.probation.remove(victim) || p.protected.remove(victim)
+	return victim, true
+}
+
+func (p *tinyLFU) Keys() []string {
+	keys := make([]string, 0, p.window.len()+p.probation.len()+p.protected.len())
+	return p.window.appendKeys(p.protected.appendKeys(p.probation.appendKeys(keys)))
+}
+
+// countMin is a count-min sketch for a cache of capacity entries: four rows of
+// at least 2 × capacity counters saturating at 15, behind a doorkeeper (a
+// two-probe Bloom filter of 16 bits per counter) that absorbs the first
+// occurrence of a key, so the one-off keys of a scan do not inflate the
+// counters. After 10 × capacity additions every counter is halved and the
+// doorkeeper is cleared.
+type countMin struct {
+	capacity  int
+	rows      [4][]uint8
+	door      []uint64
+	shift     uint // 64 - log2(row width)
+	doorShift uint // 64 - log2(doorkeeper bits)
+	seed      maphash.Seed
+	adds      int
+}
+
+// mixers derive independent row and doorkeeper positions from one hash
+// (multiplicative hashing, taking the high bits of the product).
+var mixers = [6]uint64{
+	0x9e3779b97f4a7c15, 0xc2b2ae3d27d4eb4f, 0x165667b19e3779f9,
+	0xd6e8feb86659fd93, 0xff51afd7ed558ccd, 0xc4ceb9fe1a85ec53,
+}
+
+func newCountMin(capacity int) *countMin {
+	logWidth := bits.Len(uint(max(2*capacity, 16) - 1))
+	width := 1 << logWidth
+	s := &countMin{
+		capacity:  capacity,
+		door:      make([]uint64, width/4),
+		shift:     uint(64 - logWidth),
+		doorShift: uint(64 - logWidth - 4),
+		seed:      maphash.MakeSeed(),
+	}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+// index returns the counter of a key with hash h in row i.
+func (s *countMin) index(h uint64, i int) uint64 {
+	return (h * mixers[i]) >> s.shift
+}
+
+// doorBits returns the doorkeeper bits of a key with hash h.
+func (s *countMin) doorBits(h uint64) [2]uint64 {
+	return [2]uint64{(h * mixers[4]) >> s.doorShift, (h * mixers[5]) >> s.doorShift}
+}
+
+func (s *countMin) add(key string) {
+	h := maphash.String(s.seed, key)
+	if !s.admitted(h) {
+		for _, b := range s.doorBits(h) {
+			s.door[b/64] |= 1 << (b % 64)
+		}
+	} else {
+		for i := range s.rows {
+			if c := &s.rows[i][s.index(h, i)]; *c < 15 {
+				*c++
+			}
+		}
+	}
+	if s.adds++; s.adds >= 10*s.capacity {
+		s.reset()
+	}
+}
+
+// admitted reports whether the doorkeeper has seen the key.
+func (s *countMin) admitted(h uint64) bool {
+	for _, b := range s.doorBits(h) {
+		if s.door[b/64]&(1<<(b%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *countMin) estimate(key string) uint8 {
+	h := maphash.String(s.seed, key)
+	if !s.admitted(h) {
+		return 0
+	}
+	est := uint8(15)
+	for i := range s.rows {
+		est = min(est, s.rows[i][s.index(h, i)])
+	}
+	return est + 1
+}
+
+func (s *countMin) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] /= 2
+		}
+	}
+	clear(s.door)
+	s.adds /= 2
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// traceBuffer bounds the lookups waiting to be written by RecordTrace; past it
+// they are dropped rather than slowing lookups down.
+const traceBuffer = 4096
+
+// traceFlushInterval is how often RecordTrace flushes the trace to its file.
+const traceFlushInterval = time.Second
+
+// RecordTrace appends the UID of every lookup by UID to the file at path, one
+// per line, until ctx is done: the access trace BenchmarkPolicies replays with
+// -policy.trace. A lookup is a Get, or a GetEncoded hit (its misses fall back to
+// Get). Lookups made while the file writer falls behind are dropped and counted
+// in the closing log line.
+func (c *Cache) RecordTrace(ctx context.Context, path string, logger *zap.Logger) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		logger.Error("failed to open cache access trace", zap.String("path", path), zap.Error(err))
+		return
+	}
+	uids := make(chan string, traceBuffer)
+	c.trace.Store(&uids)
+
+	w := bufio.NewWriter(f)
+	var written int64
+	write := func(uid string) {
+		w.WriteString(uid)
+		w.WriteByte('\n')
+		written++
+	}
+	stop := func() error {
+		c.trace.Store(nil)
+	drain:
+		for {
+			select {
+			case uid := <-uids:
+				write(uid)
+			default:
+				break drain
+			}
+		}
+		err := w.Flush() // write errors are sticky and surface here
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+
+	t := time.NewTicker(traceFlushInterval)
+	defer t.Stop()
+	for {
+		select {
+		case uid := <-uids:
+			write(uid)
+		case <-t.C:
+			if err := w.Flush(); err != nil {
+				stop()
+				logger.Error("failed to write cache access trace, stopped", zap.String("path", path), zap.Error(err))
+				return
+			}
+		case <-ctx.Done():
+			if err := stop(); err != nil {
+				logger.Error("failed to write cache access trace", zap.String("path", path), zap.Error(err))
+				return
+			}
+			logger.Info("cache access trace written",
+				zap.String("path", path),
+				zap.Int64("lookups", written),
+				zap.Int64("dropped", c.traceDropped.Load()),
+			)
+			return
+		}
+	}
+}
+
+// traced adds uid to the access trace if RecordTrace is running.
+func (c *Cache) traced(uid string) {
+	uids := c.trace.Load()
+	if uids == nil {
+		return
+	}
+	select {
+	case *uids <- uid:
+	default:
+		c.traceDropped.Add(1)
+	}
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func TestRecordTrace(t *testing.T) {
+	c, err := New(config.Cache{Cap: 10, Encode: true})
+	require.NoError(t, err)
+	c.Set(&domain.Order{OrderUID: "a"})
+	c.Get("before") // not traced yet
+
+	path := filepath.Join(t.TempDir(), "trace.txt")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.RecordTrace(ctx, path, zap.NewNop())
+	}()
+	require.Eventually(t, func() bool { return c.trace.Load() != nil }, time.Second, time.Millisecond)
+
+	c.Get("a")
+	c.Get("missing")
+	_, ok := c.GetEncoded("a", false)
+	require.True(t, ok)
+	_, ok = c.GetEncoded("other", false) // a miss falls back to Get, which traces it
+	require.False(t, ok)
+	c.GetByTrackNumber("WBILMTESTTRACK")
+
+	cancel()
+	<-done
+	c.Get("after")
+
+	trace, err := readTrace(path)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "missing", "a"}, trace)
+}
@@ -0,0 +1,57 @@
+package cache
+
+// twoQueue is the full 2Q policy (Johnson and Shasha). New keys enter a FIFO
+// (in) and are only promoted to the LRU of the working set (main) if they are
+// requested again after leaving it, which the ghost list out remembers. A scan
+// therefore cycles through in without evicting the working set.
+type twoQueue struct {
+	inCap, outCap int
+	in, out, main *keyList
+}
+
+func newTwoQueue(capacity int) *twoQueue {
+	p := &twoQueue{in: newKeyList(), out: newKeyList(), main: newKeyList()}
+	p.Resize(capacity)
+	return p
+}
+
+func (p *twoQueue) Resize(capacity int) {
+	p.inCap = max(1, capacity/4)
+	p.outCap = max(1, capacity/2)
+	p.out.trim(p.outCap)
+}
+
+func (p *twoQueue) Insert(key string) {
+	if p.out.remove(key) {
+		p.main.pushFront(key)
+		return
+	}
+	p.in.pushFront(key)
+}
+
+// Access only reorders main: a hit while in the FIFO says nothing yet about
+// long-term popularity.
+func (p *twoQueue) Access(key string) {
+	p.main.moveToFront(key)
+}
+
+func (p *twoQueue) Remove(key string) {
+	if !p.in.remove(key) {
+		p.main.remove(key)
+	}
+}
+
+func (p *twoQueue) Evict() (string, bool) {
+	if p.in.len() > p.inCap || p.main.len() == 0 {
+		if key, ok := p.in.popBack(); ok {
+			p.out.pushFront(key)
+			p.out.trim(p.outCap)
+			return key, true
+		}
+	}
+	return p.main.popBack()
+}
+
+func (p *twoQueue) Keys() []string {
+	return p.main.appendKeys(p.in.appendKeys(make([]string, 0, p.in.len()+p.main.len())))
+}
@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"go.uber.org/zap"
+)
+
+// WarmState is the phase of a warm-up.
+type WarmState string
+
+const (
+	WarmPending   WarmState = "pending"
+	WarmRunning   WarmState = "running"
+	WarmDone      WarmState = "done"
+	WarmTimeout   WarmState = "timeout"   // the deadline passed first; the cache is partly warm
+	WarmCancelled WarmState = "cancelled" // stopped by shutdown; the cache is partly warm
+	WarmFailed    WarmState = "failed"    // the IDs to load could not be read
+	WarmDisabled  WarmState = "disabled"  // strategy "none"
+	WarmSkipped   WarmState = "skipped"   // the cache was restored from a snapshot
+)
+
+// WarmProgress is a snapshot of a warm-up, reported by readiness.
+type WarmProgress struct {
+	Strategy  string    `json:"strategy"`
+	State     WarmState `json:"state"`
+	Total     int       `json:"total"`      // orders selected by the strategy
+	Loaded    int       `json:"loaded"`     // orders put in the cache
+	Missing   int       `json:"missing"`    // selected but gone from storage
+	Failed    int       `json:"failed"`     // in batches that failed to load
+	Errors    int       `json:"errors"`     // failed queries
+	ElapsedMs int64     `json:"elapsed_ms"` // so far, or in total once finished
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Warmer fills a Cache at startup in the background: it picks up to the cache
+// capacity of order IDs by strategy and loads them in batches, one query per
+// batch, with bounded parallelism and an optional deadline.
+type Warmer struct {
+	cache  *Cache
+	repo   warmRepo
+	cfg    config.Warmup
+	logger *zap.Logger
+
+	mu                sync.Mutex
+	running           bool
+	progress          WarmProgress
+	started, finished time.Time
+}
+
+func NewWarmer(cache *Cache, repo warmRepo, cfg config.Warmup, logger *zap.Logger) *Warmer {
+	if cfg.Parallelism <= 0 {
+		cfg.Parallelism = 1
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return &Warmer{
+		cache:    cache,
+		repo:     repo,
+		cfg:      cfg,
+		logger:   logger,
+		progress: WarmProgress{Strategy: cfg.Strategy, State: WarmPending},
+	}
+}
+
+// Run warms the cache and returns when the warm-up is over.
+func (w *Warmer) Run(ctx context.Context) {
+	if w.cfg.Strategy == config.WarmNone {
+		w.update(func(p *WarmProgress) { p.State = WarmDisabled })
+		w.logger.Info("cache warm-up disabled")
+		return
+	}
+	if !w.begin() {
+		return
+	}
+	w.run(ctx)
+}
+
+// Rewarm starts another warm-up in the background, on top of what is cached,
+// and reports whether it started; it does not while a warm-up is running.
+func (w *Warmer) Rewarm(ctx context.Context) bool {
+	if w.cfg.Strategy == config.WarmNone || !w.begin() {
+		return false
+	}
+	w.logger.Info("cache re-warm started", zap.String("strategy", w.cfg.Strategy))
+	go w.run(ctx)
+	return true
+}
+
+// begin resets the progress for a new warm-up unless one is running.
+func (w *Warmer) begin() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.running {
+		return false
+	}
+	w.running = true
+	w.progress = WarmProgress{Strategy: w.cfg.Strategy, State: WarmRunning}
+	w.started, w.finished = time.Now(), time.Time{}
+	return true
+}
+
+func (w *Warmer) run(ctx context.Context) {
+	if w.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.cfg.Timeout)
+		defer cancel()
+	}
+
+	ids, err := w.ids(ctx)
+	if err != nil {
+		w.finish(ctx, WarmFailed, func(p *WarmProgress) {
+			p.Errors++
+			p.LastError = err.Error()
+		})
+		w.logger.Error("cache warm-up failed to select orders", zap.String("strategy", w.cfg.Strategy), zap.Error(err))
+		return
+	}
+	w.update(func(p *WarmProgress) { p.Total = len(ids) })
+
+	batches := make(chan []string)
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				w.load(ctx, batch)
+			}
+		}()
+	}
+send:
+	for start := 0; start < len(ids); start += w.cfg.BatchSize {
+		select {
+		case batches <- ids[start:min(start+w.cfg.BatchSize, len(ids))]:
+		case <-ctx.Done():
+			break send
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	w.finish(ctx, WarmDone, func(*WarmProgress) {})
+	p := w.Progress()
+	w.logger.Info("cache warm-up finished",
+		zap.String("strategy", p.Strategy),
+		zap.String("state", string(p.State)),
+		zap.Int("total", p.Total),
+		zap.Int("loaded", p.Loaded),
+		zap.Int("missing", p.Missing),
+		zap.Int("failed", p.Failed),
+		zap.Int("errors", p.Errors),
+		zap.Int64("elapsed_ms", p.ElapsedMs),
+	)
+}
+
+// Skip marks the warm-up as not needed, for when the cache was filled otherwise.
+func (w *Warmer) Skip() {
+	w.update(func(p *WarmProgress) { p.State = WarmSkipped })
+}
+
+// ids selects the orders to load. "frequent" is topped up with the newest
+// orders, so a fresh deployment without read history still warms up.
+func (w *Warmer) ids(ctx context.Context) ([]string, error) {
+	limit := w.cache.Cap()
+	if w.cfg.Strategy != config.WarmFrequent {
+		return w.repo.RecentOrderIDs(ctx, limit)
+	}
+
+	ids, err := w.repo.FrequentOrderIDs(ctx, limit)
+	if err != nil || len(ids) >= limit {
+		return ids, err
+	}
+	recent, err := w.repo.RecentOrderIDs(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		seen[id] = struct{}{}
+	}
+	for _, id := range recent {
+		if len(ids) >= limit {
+			break
+		}
+		if _, ok := seen[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (w *Warmer) load(ctx context.Context, batch []string) {
+	orders, err := w.repo.GetByUIDs(ctx, batch)
+	if err != nil {
+		if ctx.Err() == nil {
+			w.logger.Warn("cache warm-up batch failed", zap.Int("orders", len(batch)), zap.Error(err))
+		}
+		w.update(func(p *WarmProgress) {
+			p.Failed += len(batch)
+			p.Errors++
+			p.LastError = err.Error()
+		})
+		return
+	}
+	for _, o := range orders {
+		w.cache.Set(o)
+	}
+	w.update(func(p *WarmProgress) {
+		p.Loaded += len(orders)
+		p.Missing += len(batch) - len(orders)
+	})
+}
+
+// finish applies fn and ends the warm-up in state, or in WarmTimeout if the
+// deadline has passed, or in WarmCancelled if ctx was cancelled.
+func (w *Warmer) finish(ctx context.Context, state WarmState, fn func(p *WarmProgress)) {
+	switch err := ctx.Err(); {
+	case errors.Is(err, context.DeadlineExceeded):
+		state = WarmTimeout
+	case errors.Is(err, context.Canceled):
+		state = WarmCancelled
+	}
+	w.mu.Lock()
+	fn(&w.progress)
+	w.progress.State = state
+	w.finished = time.Now()
+	w.running = false
+	w.mu.Unlock()
+}
+
+func (w *Warmer) update(fn func(p *WarmProgress)) {
+	w.mu.Lock()
+	fn(&w.progress)
+	w.mu.Unlock()
+}
+
+// Progress returns a snapshot of the warm-up.
+func (w *Warmer) Progress() WarmProgress {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p := w.progress
+	switch {
+	case !w.finished.IsZero():
+		p.ElapsedMs = w.finished.Sub(w.started).Milliseconds()
+	case !w.started.IsZero():
+		p.ElapsedMs = time.Since(w.started).Milliseconds()
+	}
+	return p
+}
+
+// Readiness reports the progress. Unless cfg.BlockReady is set the service is
+// ready throughout, since cache misses are served from storage.
+func (w *Warmer) Readiness() (any, bool) {
+	p := w.Progress()
+	finished := p.State != WarmPending && p.State != WarmRunning
+	return p, finished || !w.cfg.BlockReady
+}
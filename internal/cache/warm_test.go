@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func orders(uids ...string) map[string]*domain.Order {
+	out := make(map[string]*domain.Order, len(uids))
+	for _, uid := range uids {
+		out[uid] = &domain.Order{OrderUID: uid}
+	}
+	return out
+}
+
+func TestWarmer(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Warmup
+		setup    func(repo *MockwarmRepo)
+		cached   []string
+		expected WarmProgress
+	}{
+		{
+			name: "recent in batches",
+			cfg:  config.Warmup{Strategy: config.WarmRecent, Parallelism: 2, BatchSize: 2},
+			setup: func(repo *MockwarmRepo) {
+				repo.EXPECT().RecentOrderIDs(gomock.Any(), 4).Return([]string{"1", "2", "3", "4"}, nil)
+				repo.EXPECT().GetByUIDs(gomock.Any(), []string{"1", "2"}).Return(orders("1", "2"), nil)
+				repo.EXPECT().GetByUIDs(gomock.Any(), []string{"3", "4"}).Return(orders("3"), nil)
+			},
+			cached:   []string{"1", "2", "3"},
+			expected: WarmProgress{Strategy: "recent", State: WarmDone, Total: 4, Loaded: 3, Missing: 1},
+		},
+		{
+			name: "failed batch is counted",
+			cfg:  config.Warmup{Strategy: config.WarmRecent, Parallelism: 1, BatchSize: 2},
+			setup: func(repo *MockwarmRepo) {
+				repo.EXPECT().RecentOrderIDs(gomock.Any(), 4).Return([]string{"ok1", "ok2", "bad1", "bad2"}, nil)
+				repo.EXPECT().GetByUIDs(gomock.Any(), []string{"ok1", "ok2"}).Return(orders("ok1", "ok2"), nil)
+				repo.EXPECT().GetByUIDs(gomock.Any(), []string{"bad1", "bad2"}).Return(nil, errors.New("db read err"))
+			},
+			cached: []string{"ok1", "ok2"},
+			expected: WarmProgress{Strategy: "recent", State: WarmDone, Total: 4, Loaded: 2,
+				Failed: 2, Errors: 1, LastError: "db read err"},
+		},
+		{
+			name: "ids error",
+			cfg:  config.Warmup{Strategy: config.WarmRecent},
+			setup: func(repo *MockwarmRepo) {
+				repo.EXPECT().RecentOrderIDs(gomock.Any(), 4).Return(nil, errors.New("repo error"))
+			},
+			expected: WarmProgress{Strategy: "recent", State: WarmFailed, Errors: 1, LastError: "repo error"},
+		},
+		{
+			name: "frequent topped up with recent",
+			cfg:  config.Warmup{Strategy: config.WarmFrequent, BatchSize: 10},
+			setup: func(repo *MockwarmRepo) {
+				repo.EXPECT().FrequentOrderIDs(gomock.Any(), 4).Return([]string{"hot", "warm"}, nil)
+				repo.EXPECT().RecentOrderIDs(gomock.Any(), 4).Return([]string{"new", "hot", "newer", "newest"}, nil)
+				repo.EXPECT().GetByUIDs(gomock.Any(), []string{"hot", "warm", "new", "newer"}).
+					Return(orders("hot", "warm", "new", "newer"), nil)
+			},
+			cached:   []string{"hot", "warm", "new", "newer"},
+			expected: WarmProgress{Strategy: "frequent", State: WarmDone, Total: 4, Loaded: 4},
+		},
+		{
+			name:     "none",
+			cfg:      config.Warmup{Strategy: config.WarmNone},
+			setup:    func(repo *MockwarmRepo) {},
+			expected: WarmProgress{Strategy: "none", State: WarmDisabled},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := NewMockwarmRepo(ctrl)
+			tt.setup(repo)
+
+			c, err := New(config.Cache{Cap: 4})
+			require.NoError(t, err)
+			w := NewWarmer(c, repo, tt.cfg, zap.NewNop())
+			w.Run(context.Background())
+
+			p := w.Progress()
+			p.ElapsedMs = 0
+			require.Equal(t, tt.expected, p)
+			require.ElementsMatch(t, tt.cached, c.Keys())
+		})
+	}
+}
+
+func TestWarmerTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := NewMockwarmRepo(ctrl)
+	repo.EXPECT().RecentOrderIDs(gomock.Any(), 4).Return([]string{"1", "2"}, nil)
+	repo.EXPECT().GetByUIDs(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ []string) (map[string]*domain.Order, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+	c, err := New(config.Cache{Cap: 4})
+	require.NoError(t, err)
+	w := NewWarmer(c, repo, config.Warmup{Strategy: config.WarmRecent, BatchSize: 10, Timeout: 20 * time.Millisecond, BlockReady: true}, zap.NewNop())
+
+	_, ready := w.Readiness()
+	require.False(t, ready, "not ready before the warm-up ends")
+
+	w.Run(context.Background())
+	p, ready := w.Readiness()
+	require.True(t, ready)
+	require.Equal(t, WarmTimeout, p.(WarmProgress).State)
+	require.Equal(t, 2, p.(WarmProgress).Failed)
+}
+
+func TestWarmerCancelled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := NewMockwarmRepo(ctrl)
+	ctx, cancel := context.WithCancel(context.Background())
+	repo.EXPECT().RecentOrderIDs(gomock.Any(), 4).Return([]string{"1", "2"}, nil)
+	repo.EXPECT().GetByUIDs(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ []string) (map[string]*domain.Order, error) {
+			cancel()
+			return nil, ctx.Err()
+		})
+
+	c, err := New(config.Cache{Cap: 4})
+	require.NoError(t, err)
+	w := NewWarmer(c, repo, config.Warmup{Strategy: config.WarmRecent, BatchSize: 10, Timeout: time.Minute}, zap.NewNop())
+
+	w.Run(ctx)
+	p := w.Progress()
+	require.Equal(t, WarmCancelled, p.State, "shutdown is not reported as done")
+	require.Equal(t, 2, p.Failed)
+}
+
+func TestWarmerRewarm(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := NewMockwarmRepo(ctrl)
+	release := make(chan struct{})
+	repo.EXPECT().RecentOrderIDs(gomock.Any(), 4).Return([]string{"1"}, nil).Times(2)
+	repo.EXPECT().GetByUIDs(gomock.Any(), []string{"1"}).DoAndReturn(
+		func(context.Context, []string) (map[string]*domain.Order, error) {
+			<-release
+			return orders("1"), nil
+		}).Times(2)
+
+	c, err := New(config.Cache{Cap: 4})
+	require.NoError(t, err)
+	w := NewWarmer(c, repo, config.Warmup{Strategy: config.WarmRecent}, zap.NewNop())
+
+	require.True(t, w.Rewarm(context.Background()))
+	require.False(t, w.Rewarm(context.Background()), "a warm-up is already running")
+	release <- struct{}{}
+	require.Eventually(t, func() bool { return w.Progress().State == WarmDone }, time.Second, time.Millisecond)
+
+	require.True(t, w.Rewarm(context.Background()))
+	require.Equal(t, WarmProgress{Strategy: "recent", State: WarmRunning}, w.Progress().withoutElapsed())
+	release <- struct{}{}
+	require.Eventually(t, func() bool { return w.Progress().State == WarmDone }, time.Second, time.Millisecond)
+	require.Equal(t, 1, w.Progress().Loaded)
+}
+
+func TestWarmerRewarmDisabled(t *testing.T) {
+	c, err := New(config.Cache{Cap: 4})
+	require.NoError(t, err)
+	w := NewWarmer(c, nil, config.Warmup{Strategy: config.WarmNone}, zap.NewNop())
+	require.False(t, w.Rewarm(context.Background()))
+}
+
+func (p WarmProgress) withoutElapsed() WarmProgress {
+	p.ElapsedMs = 0
+	return p
+}
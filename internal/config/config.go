@@ -1,7 +1,11 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"log"
+	"maps"
 	"net"
 	"net/url"
 	"os"
@@ -18,6 +22,7 @@ type Tables struct {
 	Delivery string
 	Payment  string
 	Item     string
+	Reads    string // per-order read counters for the "frequent" warm-up strategy
 }
 
 type Kafka struct {
@@ -34,6 +39,25 @@ type Postgres struct {
 	User     string
 	Password string
 	SSLMode  string
+
+	// Pool sizing; zero values keep the pgxpool defaults.
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+
+	// QueryTimeout bounds queries whose context has no deadline of its own.
+	QueryTimeout time.Duration
+	// StatsInterval is how often pool statistics are reported to metrics.
+	StatsInterval time.Duration
+	// NotifyChannel receives a NOTIFY per upserted order; other instances LISTEN on it
+	// to invalidate their caches. Empty (PG_NOTIFY_CHANNEL=off) disables both sides.
+	NotifyChannel string
+
+	// ConnectTimeout bounds a single startup attempt; Connect is the backoff between attempts.
+	ConnectTimeout time.Duration
+	Connect        Retry
 }
 
 type Breaker struct {
@@ -49,15 +73,230 @@ type Retry struct {
 	JitterFactor float64
 }
 
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+	StorageFile     = "file"
+)
+
+// Storage selects the order store: Postgres for real deployments, an in-memory
+// store for tests or a file-backed one (Path) for laptop demos.
+type Storage struct {
+	Driver string
+	Path   string
+}
+
+// WriteBehind makes order writes return once they are in a local write-ahead log
+// (segments in Dir); a background flusher writes them to storage in batches and
+// the log is replayed at startup.
+type WriteBehind struct {
+	Enabled       bool
+	Dir           string
+	BatchSize     int // orders per storage write; a full batch is flushed at once
+	FlushInterval time.Duration
+	MaxPending    int           // writes wait while this many orders are unflushed; 0 = unbounded
+	MaxLag        time.Duration // not ready while the oldest unflushed order is older; 0 = always ready
+}
+
+// Partitions controls maintenance of the monthly order/item partitions.
+type Partitions struct {
+	Enabled   bool
+	Ahead     int // months of future partitions kept ready
+	Retention int // months of history kept; 0 keeps everything
+	Interval  time.Duration
+}
+
+// Cache eviction policies.
+const (
+	CachePolicyLRU     = "lru"
+	CachePolicy2Q      = "2q"
+	CachePolicyARC     = "arc"
+	CachePolicyTinyLFU = "tinylfu" // W-TinyLFU
+)
+
+// Cache bounds the in-memory order cache.
+type Cache struct {
+	Cap      int    // maximum number of orders
+	MaxBytes int64  // estimated memory budget; 0 = unbounded
+	Policy   string // what to evict, one of the CachePolicy constants
+	// Shards splits the cache by UID hash into independently locked parts, each
+	// with its own policy and an equal share of Cap and MaxBytes.
+	Shards int
+	// Encode keeps each order's JSON response body with the cached order once it
+	// has been read, and EncodeGzip its gzip-compressed copy, so hits skip encoding.
+	Encode     bool
+	EncodeGzip bool
+	// TTL is how long an order is served as fresh; 0 = forever. For StaleTTL after
+	// that it is still served while being reloaded in the background.
+	TTL           time.Duration
+	StaleTTL      time.Duration
+	StatsInterval time.Duration // how often cache statistics are reported to metrics
+
+	// NegativeCap and NegativeTTL bound the cache of UIDs known to be missing;
+	// either set to 0 disables it.
+	NegativeCap int
+	NegativeTTL time.Duration
+
+	// StaleStoreCap bounds the recently evicted orders kept to serve while
+	// storage is unavailable, for up to StaleStoreMaxAge after their eviction
+	// (0 = until pushed out); a cap of 0 disables the fallback.
+	StaleStoreCap    int
+	StaleStoreMaxAge time.Duration
+
+	// SnapshotPath is where the cache is saved on shutdown and every
+	// SnapshotInterval (0 = on shutdown only), and restored from at startup
+	// if younger than SnapshotMaxAge. Empty disables snapshots.
+	SnapshotPath     string
+	SnapshotInterval time.Duration
+	SnapshotMaxAge   time.Duration
+
+	// TracePath is a file every lookup by UID is appended to, one UID per line,
+	// to replay against the eviction policies. Empty disables the trace.
+	TracePath string
+}
+
+// Warm-up strategies: which orders fill the cache at startup.
+const (
+	WarmRecent   = "recent"   // newest by date_created
+	WarmFrequent = "frequent" // most read, topped up with the newest
+	WarmNone     = "none"
+)
+
+// Warmup controls how the cache is filled at startup, in the background.
+type Warmup struct {
+	Strategy    string
+	Parallelism int           // concurrent batch loads
+	BatchSize   int           // orders per query
+	Timeout     time.Duration // 0 = no deadline
+	BlockReady  bool          // report not ready until the warm-up ends
+	// ReadsFlushInterval is how often read counters for the "frequent" strategy
+	// are saved to storage; 0 disables counting.
+	ReadsFlushInterval time.Duration
+}
+
+// Admin protects the administration endpoints.
+type Admin struct {
+	Token string // bearer token; empty disables the endpoints
+}
+
+// Roles granted by API keys and tokens, each including the ones before it.
+const (
+	RoleReader = "reader" // reads orders
+	RoleWriter = "writer" // also upserts orders
+	RoleAdmin  = "admin"  // also administers the cache
+)
+
+// APIKey is a static key, stored as the hex SHA-256 of the key itself.
+type APIKey struct {
+	Name string // identifies the caller in logs
+	Role string
+	Hash string
+}
+
+// Auth configures API authentication. With neither keys nor a JWT secret the
+// API is open.
+type Auth struct {
+	// APIKeys is read from AUTH_API_KEYS as comma-separated name:role:sha256hex.
+	APIKeys []APIKey
+	// JWTSecret verifies HS256 bearer tokens; empty disables them. Issuer and
+	// Audience, when set, must match the iss and aud claims.
+	JWTSecret   string
+	JWTIssuer   string
+	JWTAudience string
+	JWTLeeway   time.Duration // allowed clock skew for exp and nbf
+}
+
+// Enabled reports whether requests must authenticate.
+func (a Auth) Enabled() bool {
+	return len(a.APIKeys) > 0 || a.JWTSecret != ""
+}
+
+// Limit is a token bucket: Rate requests per second on average, up to Burst at
+// once. A Rate of 0 disables the limit.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimit limits requests per client, the API key or token subject if the
+// request is authenticated and the client IP otherwise, per route class.
+type RateLimit struct {
+	Enabled bool
+	Read    Limit // order lookups
+	Write   Limit // order upserts
+	Admin   Limit // /admin
+	// TrustProxy takes the client IP from the last X-Forwarded-For entry, as
+	// appended by a reverse proxy in front of the server.
+	TrustProxy bool
+}
+
+// HTTP bounds what a client can make the server hold on to.
+type HTTP struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxBodyBytes      int64 // request body limit, 0 = unlimited
+	BatchMaxUIDs      int   // UIDs per POST /api/v1/orders:batchGet
+	RateLimit         RateLimit
+	// CacheControl is the Cache-Control header by route pattern, such as
+	// /api/v1/orders/{uid}. HTTP_CACHE_CONTROL overrides entries with
+	// semicolon-separated route=directives pairs; an empty value drops one.
+	CacheControl map[string]string
+	Compression  Compression
+}
+
+// Compression encodes response bodies in a content coding the client accepts.
+type Compression struct {
+	Enabled bool
+	// Encodings are the codings offered (zstd, gzip, deflate), preferred first
+	// when the client accepts several equally.
+	Encodings []string
+	MinBytes  int // smaller bodies are sent as is
+}
+
+// defaultCacheControl makes clients revalidate orders on every use, which
+// conditional requests keep cheap, and lets them keep the API docs for a while.
+var defaultCacheControl = map[string]string{
+	"/api/v1/orders/{uid}": "private, no-cache",
+	"/api/v1/orders":       "private, no-cache",
+	"/order/{uid}":         "private, no-cache",
+	"/orders":              "private, no-cache",
+	"/openapi.json":        "public, max-age=300",
+	"/docs":                "public, max-age=300",
+}
+
+// Stream feeds GET /api/v1/orders/stream with the orders upserted on this
+// instance.
+type Stream struct {
+	Enabled bool
+	// Buffer is how many recent events are kept for clients resuming with
+	// Last-Event-ID.
+	Buffer int
+	// Queue is how many events may wait for a subscriber; one that falls
+	// further behind is dropped and has to reconnect.
+	Queue          int
+	MaxSubscribers int           // 0 = unlimited
+	Heartbeat      time.Duration // interval of keep-alive comments
+}
+
 type Config struct {
 	HTTPAddr string
-	CacheCap int
+	HTTP     HTTP
+	Stream   Stream
+	Admin    Admin
+	Auth     Auth
+	Cache    Cache
+	Warmup   Warmup
 
-	Pg      Postgres
-	Tables  Tables
-	Kafka   Kafka
-	Breaker Breaker
-	Retry   Retry
+	Storage     Storage
+	WriteBehind WriteBehind
+	Pg          Postgres
+	Tables      Tables
+	Partitions  Partitions
+	Kafka       Kafka
+	Breaker     Breaker
+	Retry       Retry
 }
 
 // Load keeps the original API and fatals on error for simplicity in main().
@@ -74,7 +313,88 @@ func load() (Config, error) {
 
 	cfg := Config{
 		HTTPAddr: envDefault("HTTP_ADDR", ":8081"),
-		CacheCap: envInt("CACHE_CAP", 1000),
+		HTTP: HTTP{
+			ReadHeaderTimeout: envDurationMS("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+			ReadTimeout:       envDurationMS("HTTP_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:      envDurationMS("HTTP_WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:       envDurationMS("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+			MaxBodyBytes:      int64(envInt("HTTP_MAX_BODY_BYTES", 1<<20)),
+			BatchMaxUIDs:      envInt("HTTP_BATCH_MAX_UIDS", 100),
+			RateLimit: RateLimit{
+				Enabled:    envBool("RATE_LIMIT_ENABLED", true),
+				Read:       Limit{Rate: envFloat64("RATE_LIMIT_READ_RPS", 100), Burst: envInt("RATE_LIMIT_READ_BURST", 200)},
+				Write:      Limit{Rate: envFloat64("RATE_LIMIT_WRITE_RPS", 20), Burst: envInt("RATE_LIMIT_WRITE_BURST", 40)},
+				Admin:      Limit{Rate: envFloat64("RATE_LIMIT_ADMIN_RPS", 2), Burst: envInt("RATE_LIMIT_ADMIN_BURST", 5)},
+				TrustProxy: envBool("HTTP_TRUST_PROXY", false),
+			},
+			Compression: Compression{
+				Enabled:   envBool("HTTP_COMPRESSION_ENABLED", true),
+				Encodings: splitCSV(strings.ToLower(envDefault("HTTP_COMPRESSION_ENCODINGS", "zstd,gzip,deflate"))),
+				MinBytes:  envInt("HTTP_COMPRESSION_MIN_BYTES", 1024),
+			},
+		},
+		Stream: Stream{
+			Enabled:        envBool("STREAM_ENABLED", true),
+			Buffer:         envInt("STREAM_BUFFER", 1000),
+			Queue:          envInt("STREAM_QUEUE", 64),
+			MaxSubscribers: envInt("STREAM_MAX_SUBSCRIBERS", 100),
+			Heartbeat:      envDurationMS("STREAM_HEARTBEAT", 15*time.Second),
+		},
+		Admin: Admin{
+			Token: strings.TrimSpace(os.Getenv("ADMIN_TOKEN")),
+		},
+		Auth: Auth{
+			JWTSecret:   strings.TrimSpace(os.Getenv("AUTH_JWT_SECRET")),
+			JWTIssuer:   strings.TrimSpace(os.Getenv("AUTH_JWT_ISSUER")),
+			JWTAudience: strings.TrimSpace(os.Getenv("AUTH_JWT_AUDIENCE")),
+			JWTLeeway:   envDurationMS("AUTH_JWT_LEEWAY", 30*time.Second),
+		},
+
+		Cache: Cache{
+			Cap:           envInt("CACHE_CAP", 1000),
+			MaxBytes:      int64(envInt("CACHE_MAX_BYTES", 0)),
+			Policy:        strings.ToLower(envDefault("CACHE_POLICY", CachePolicyLRU)),
+			Shards:        envInt("CACHE_SHARDS", 16),
+			Encode:        envBool("CACHE_ENCODE", true),
+			EncodeGzip:    envBool("CACHE_ENCODE_GZIP", true),
+			TTL:           envDurationMS("CACHE_TTL", 0),
+			StaleTTL:      envDurationMS("CACHE_STALE_TTL", 0),
+			StatsInterval: envDurationMS("CACHE_STATS_INTERVAL", 10*time.Second),
+			NegativeCap:   envInt("CACHE_NEGATIVE_CAP", 10000),
+			NegativeTTL:   envDurationMS("CACHE_NEGATIVE_TTL", 5*time.Second),
+
+			StaleStoreCap:    envInt("CACHE_STALE_STORE_CAP", 10000),
+			StaleStoreMaxAge: envDurationMS("CACHE_STALE_STORE_MAX_AGE", 24*time.Hour),
+
+			SnapshotPath:     envDefault("CACHE_SNAPSHOT_PATH", "data/cache.snapshot"),
+			SnapshotInterval: envDurationMS("CACHE_SNAPSHOT_INTERVAL", 5*time.Minute),
+			SnapshotMaxAge:   envDurationMS("CACHE_SNAPSHOT_MAX_AGE", time.Hour),
+
+			TracePath: strings.TrimSpace(os.Getenv("CACHE_TRACE_PATH")),
+		},
+
+		Warmup: Warmup{
+			Strategy:           strings.ToLower(envDefault("CACHE_WARM_STRATEGY", WarmRecent)),
+			Parallelism:        envInt("CACHE_WARM_PARALLELISM", 4),
+			BatchSize:          envInt("CACHE_WARM_BATCH", 200),
+			Timeout:            envDurationMS("CACHE_WARM_TIMEOUT", time.Minute),
+			BlockReady:         envBool("CACHE_WARM_BLOCK_READY", false),
+			ReadsFlushInterval: envDurationMS("CACHE_READS_FLUSH_INTERVAL", 30*time.Second),
+		},
+
+		Storage: Storage{
+			Driver: strings.ToLower(envDefault("STORAGE_DRIVER", StoragePostgres)),
+			Path:   envDefault("STORAGE_PATH", "data/orders.db"),
+		},
+
+		WriteBehind: WriteBehind{
+			Enabled:       envBool("WRITE_BEHIND_ENABLED", false),
+			Dir:           envDefault("WRITE_BEHIND_DIR", "data/wal"),
+			BatchSize:     envInt("WRITE_BEHIND_BATCH", 500),
+			FlushInterval: envDurationMS("WRITE_BEHIND_FLUSH_INTERVAL", 200*time.Millisecond),
+			MaxPending:    envInt("WRITE_BEHIND_MAX_PENDING", 10000),
+			MaxLag:        envDurationMS("WRITE_BEHIND_MAX_LAG", 30*time.Second),
+		},
 
 		Pg: Postgres{
 			Host:     strings.TrimSpace(os.Getenv("PG_HOST")),
@@ -83,6 +403,24 @@ func load() (Config, error) {
 			User:     strings.TrimSpace(os.Getenv("PG_USER")),
 			Password: strings.TrimSpace(os.Getenv("PG_PASSWORD")),
 			SSLMode:  strings.TrimSpace(envDefault("PG_SSLMODE", "disable")),
+
+			MaxConns:          int32(envInt("PG_MAX_CONNS", 0)),
+			MinConns:          int32(envInt("PG_MIN_CONNS", 0)),
+			MaxConnLifetime:   envDurationMS("PG_MAX_CONN_LIFETIME", 0),
+			MaxConnIdleTime:   envDurationMS("PG_MAX_CONN_IDLE_TIME", 0),
+			HealthCheckPeriod: envDurationMS("PG_HEALTH_CHECK_PERIOD", 0),
+
+			QueryTimeout:  envDurationMS("PG_QUERY_TIMEOUT", 5*time.Second),
+			StatsInterval: envDurationMS("PG_STATS_INTERVAL", 10*time.Second),
+			NotifyChannel: envDefault("PG_NOTIFY_CHANNEL", "orders_changed"),
+
+			ConnectTimeout: envDurationMS("PG_CONNECT_TIMEOUT", 5*time.Second),
+			Connect: Retry{
+				Attempts:     envInt("PG_CONNECT_ATTEMPTS", 10),
+				Base:         envDurationMS("PG_CONNECT_BASE", 500*time.Millisecond),
+				Max:          envDurationMS("PG_CONNECT_MAX", 10*time.Second),
+				JitterFactor: 0.2,
+			},
 		},
 
 		Tables: Tables{
@@ -91,6 +429,14 @@ func load() (Config, error) {
 			Delivery: strings.TrimSpace(os.Getenv("TBL_DELIVERY")),
 			Payment:  strings.TrimSpace(os.Getenv("TBL_PAYMENT")),
 			Item:     strings.TrimSpace(os.Getenv("TBL_ITEM")),
+			Reads:    envDefault("TBL_READS", "order_reads"),
+		},
+
+		Partitions: Partitions{
+			Enabled:   envBool("PARTITION_ENABLED", true),
+			Ahead:     envInt("PARTITION_AHEAD", 3),
+			Retention: envInt("PARTITION_RETENTION", 0),
+			Interval:  envDurationMS("PARTITION_INTERVAL", time.Hour),
 		},
 
 		Kafka: Kafka{
@@ -114,6 +460,25 @@ func load() (Config, error) {
 		},
 	}
 
+	cacheControl, err := parseCacheControl(os.Getenv("HTTP_CACHE_CONTROL"))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.HTTP.CacheControl = cacheControl
+
+	apiKeys, err := parseAPIKeys(os.Getenv("AUTH_API_KEYS"))
+	if err != nil {
+		return Config{}, err
+	}
+	cfg.Auth.APIKeys = apiKeys
+
+	if strings.EqualFold(cfg.Cache.SnapshotPath, "off") {
+		cfg.Cache.SnapshotPath = ""
+	}
+	if strings.EqualFold(cfg.Pg.NotifyChannel, "off") {
+		cfg.Pg.NotifyChannel = ""
+	}
+
 	// Validate required envs and basic sanity.
 	if err := cfg.validate(); err != nil {
 		return Config{}, err
@@ -122,21 +487,33 @@ func load() (Config, error) {
 }
 
 func (c Config) validate() error {
+	switch c.Storage.Driver {
+	case StoragePostgres, StorageMemory, StorageFile:
+	default:
+		return fmt.Errorf("unknown STORAGE_DRIVER %q (want %s, %s or %s)",
+			c.Storage.Driver, StoragePostgres, StorageMemory, StorageFile)
+	}
+
 	var missing []string
 	req := map[string]string{
-		"PG_HOST":       c.Pg.Host,
-		"PG_DB":         c.Pg.DB,
-		"PG_USER":       c.Pg.User,
-		"PG_PASSWORD":   c.Pg.Password,
-		"DB_SCHEMA":     c.Tables.Schema,
-		"TBL_ORDER":     c.Tables.Order,
-		"TBL_DELIVERY":  c.Tables.Delivery,
-		"TBL_PAYMENT":   c.Tables.Payment,
-		"TBL_ITEM":      c.Tables.Item,
 		"KAFKA_BROKERS": strings.Join(c.Kafka.Brokers, ","),
 		"KAFKA_TOPIC":   c.Kafka.Topic,
 		"KAFKA_GROUP":   c.Kafka.Group,
 	}
+	if c.Storage.Driver == StoragePostgres {
+		req["PG_HOST"] = c.Pg.Host
+		req["PG_DB"] = c.Pg.DB
+		req["PG_USER"] = c.Pg.User
+		req["PG_PASSWORD"] = c.Pg.Password
+		req["DB_SCHEMA"] = c.Tables.Schema
+		req["TBL_ORDER"] = c.Tables.Order
+		req["TBL_DELIVERY"] = c.Tables.Delivery
+		req["TBL_PAYMENT"] = c.Tables.Payment
+		req["TBL_ITEM"] = c.Tables.Item
+	}
+	if c.Storage.Driver == StorageFile && strings.TrimSpace(c.Storage.Path) == "" {
+		missing = append(missing, "STORAGE_PATH")
+	}
 	for k, v := range req {
 		if strings.TrimSpace(v) == "" {
 			missing = append(missing, k)
@@ -146,8 +523,23 @@ func (c Config) validate() error {
 		return &missingEnvError{Keys: missing}
 	}
 
-	if c.CacheCap <= 0 {
-		log.Printf("CACHE_CAP is %d, adjusting to 1", c.CacheCap)
+	switch c.Warmup.Strategy {
+	case WarmRecent, WarmFrequent, WarmNone:
+	default:
+		return fmt.Errorf("unknown CACHE_WARM_STRATEGY %q (want %s, %s or %s)",
+			c.Warmup.Strategy, WarmRecent, WarmFrequent, WarmNone)
+	}
+	switch c.Cache.Policy {
+	case CachePolicyLRU, CachePolicy2Q, CachePolicyARC, CachePolicyTinyLFU:
+	default:
+		return fmt.Errorf("unknown CACHE_POLICY %q (want %s, %s, %s or %s)",
+			c.Cache.Policy, CachePolicyLRU, CachePolicy2Q, CachePolicyARC, CachePolicyTinyLFU)
+	}
+	if c.Cache.Cap <= 0 {
+		log.Printf("CACHE_CAP is %d, adjusting to 1", c.Cache.Cap)
+	}
+	if c.Cache.Shards <= 0 {
+		log.Printf("CACHE_SHARDS is %d, adjusting to 1", c.Cache.Shards)
 	}
 	if c.Retry.Attempts < 0 {
 		log.Printf("RETRY_ATTEMPTS is %d, adjusting to 0", c.Retry.Attempts)
@@ -158,12 +550,95 @@ func (c Config) validate() error {
 	if c.Retry.Max < c.Retry.Base {
 		log.Printf("RETRY_MAX (%v) < RETRY_BASE (%v), adjusting max to base", c.Retry.Max, c.Retry.Base)
 	}
+	if c.Pg.MinConns > c.Pg.MaxConns && c.Pg.MaxConns > 0 {
+		return fmt.Errorf("PG_MIN_CONNS (%d) > PG_MAX_CONNS (%d)", c.Pg.MinConns, c.Pg.MaxConns)
+	}
+	if c.Pg.Connect.Attempts <= 0 {
+		log.Printf("PG_CONNECT_ATTEMPTS is %d, adjusting to 1", c.Pg.Connect.Attempts)
+	}
+	if c.Partitions.Ahead < 0 {
+		log.Printf("PARTITION_AHEAD is %d, adjusting to 0", c.Partitions.Ahead)
+	}
+	for name, l := range map[string]Limit{"READ": c.HTTP.RateLimit.Read, "WRITE": c.HTTP.RateLimit.Write, "ADMIN": c.HTTP.RateLimit.Admin} {
+		if l.Rate < 0 {
+			return fmt.Errorf("RATE_LIMIT_%s_RPS is %v, want 0 (unlimited) or more", name, l.Rate)
+		}
+		if l.Rate > 0 && l.Burst < 1 {
+			log.Printf("RATE_LIMIT_%s_BURST is %d, adjusting to 1", name, l.Burst)
+		}
+	}
+	if c.Stream.Enabled && c.Stream.Queue < 1 {
+		log.Printf("STREAM_QUEUE is %d, adjusting to 1", c.Stream.Queue)
+	}
+	if c.Stream.Enabled && c.Stream.Heartbeat <= 0 {
+		log.Printf("STREAM_HEARTBEAT is %v, adjusting to 15s", c.Stream.Heartbeat)
+	}
+	if c.HTTP.Compression.Enabled && len(c.HTTP.Compression.Encodings) == 0 {
+		return fmt.Errorf("HTTP_COMPRESSION_ENCODINGS is empty, set HTTP_COMPRESSION_ENABLED=false to disable compression")
+	}
+	if c.Auth.JWTSecret != "" && len(c.Auth.JWTSecret) < 32 {
+		log.Printf("AUTH_JWT_SECRET is %d bytes, HS256 wants at least 32", len(c.Auth.JWTSecret))
+	}
 	if len(c.Kafka.Brokers) == 0 {
 		return &missingEnvError{Keys: []string{"KAFKA_BROKERS"}}
 	}
 	return nil
 }
 
+// parseCacheControl applies route=directives pairs separated by semicolons to
+// defaultCacheControl.
+func parseCacheControl(s string) (map[string]string, error) {
+	out := maps.Clone(defaultCacheControl)
+	for _, entry := range strings.Split(s, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		route, directives, ok := strings.Cut(entry, "=")
+		route = strings.TrimSpace(route)
+		if !ok || !strings.HasPrefix(route, "/") {
+			return nil, fmt.Errorf("HTTP_CACHE_CONTROL: entry %q is not route=directives", entry)
+		}
+		if directives = strings.TrimSpace(directives); directives == "" {
+			delete(out, route)
+			continue
+		}
+		out[route] = directives
+	}
+	return out, nil
+}
+
+// parseAPIKeys reads name:role:sha256hex entries separated by commas.
+func parseAPIKeys(s string) ([]APIKey, error) {
+	var keys []APIKey
+	names := make(map[string]bool)
+	for _, entry := range splitCSV(strings.TrimSpace(s)) {
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("AUTH_API_KEYS: entry %q is not name:role:sha256hex", entry)
+		}
+		k := APIKey{
+			Name: strings.TrimSpace(parts[0]),
+			Role: strings.ToLower(strings.TrimSpace(parts[1])),
+			Hash: strings.ToLower(strings.TrimSpace(parts[2])),
+		}
+		switch {
+		case k.Name == "":
+			return nil, fmt.Errorf("AUTH_API_KEYS: entry %q has no name", entry)
+		case names[k.Name]:
+			return nil, fmt.Errorf("AUTH_API_KEYS: duplicate name %q", k.Name)
+		case k.Role != RoleReader && k.Role != RoleWriter && k.Role != RoleAdmin:
+			return nil, fmt.Errorf("AUTH_API_KEYS: key %q has unknown role %q (want %s, %s or %s)",
+				k.Name, k.Role, RoleReader, RoleWriter, RoleAdmin)
+		}
+		if b, err := hex.DecodeString(k.Hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("AUTH_API_KEYS: key %q: hash is not a hex SHA-256", k.Name)
+		}
+		names[k.Name] = true
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
 type missingEnvError struct{ Keys []string }
 
 func (e *missingEnvError) Error() string {
@@ -206,6 +681,19 @@ func envInt(k string, def int) int {
 	return n
 }
 
+func envBool(k string, def bool) bool {
+	v := strings.TrimSpace(os.Getenv(k))
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %t: %v", k, v, def, err)
+		return def
+	}
+	return b
+}
+
 func envUint32(k string, def uint32) uint32 {
 	v := strings.TrimSpace(os.Getenv(k))
 	if v == "" {
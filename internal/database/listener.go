@@ -0,0 +1,116 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	listenMinBackoff = time.Second
+	listenMaxBackoff = 30 * time.Second
+)
+
+// ChangeHandler receives order change notifications from a Listener.
+type ChangeHandler interface {
+	OrderChanged(ctx context.Context, uid string, version int64)
+	// Resync is called every time the listener (re)subscribes, since
+	// notifications sent while it was disconnected are lost.
+	Resync(ctx context.Context) error
+}
+
+// Listener subscribes to the channel Repo notifies on and forwards every change
+// to a handler. It holds its own connection outside the pool and reconnects with
+// exponential backoff when it is lost.
+type Listener struct {
+	pool    *pgxpool.Pool
+	channel string
+	handler ChangeHandler
+	logger  *zap.Logger
+}
+
+func NewListener(pool *pgxpool.Pool, channel string, handler ChangeHandler, logger *zap.Logger) *Listener {
+	return &Listener{
+		pool:    pool,
+		channel: channel,
+		handler: handler,
+		logger:  logger,
+	}
+}
+
+// Run listens until ctx is done.
+func (l *Listener) Run(ctx context.Context) {
+	backoff := listenMinBackoff
+	for {
+		subscribed, err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if subscribed {
+			backoff = listenMinBackoff
+		}
+		l.logger.Warn("order change listener disconnected, reconnecting",
+			zap.String("channel", l.channel),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, listenMaxBackoff)
+	}
+}
+
+// listen runs one subscription and reports whether LISTEN succeeded before it failed.
+func (l *Listener) listen(ctx context.Context) (bool, error) {
+	conn, err := pgx.ConnectConfig(ctx, l.pool.Config().ConnConfig)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+		return false, err
+	}
+	l.logger.Info("listening for order changes", zap.String("channel", l.channel))
+
+	// Anything changed before LISTEN took effect was not delivered to us.
+	if err := l.handler.Resync(ctx); err != nil {
+		l.logger.Warn("resync after subscribing failed", zap.Error(err))
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return true, err
+		}
+		msg, err := parseNotification(n.Payload)
+		if err != nil {
+			l.logger.Warn("malformed order change notification",
+				zap.String("payload", n.Payload),
+				zap.Error(err),
+			)
+			continue
+		}
+		l.handler.OrderChanged(ctx, msg.UID, msg.Version)
+	}
+}
+
+func parseNotification(payload string) (Notification, error) {
+	var n Notification
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		return n, err
+	}
+	if n.UID == "" {
+		return n, fmt.Errorf("uid is empty")
+	}
+	return n, nil
+}
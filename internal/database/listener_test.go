@@ -0,0 +1,44 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseNotification(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected Notification
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			payload:  `{"uid":"b563feb7b2b84b6test","version":3}`,
+			expected: Notification{UID: "b563feb7b2b84b6test", Version: 3},
+		},
+		{
+			name:    "not json",
+			payload: "b563feb7b2b84b6test",
+			wantErr: true,
+		},
+		{
+			name:    "empty uid",
+			payload: `{"version":3}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNotification(tt.payload)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Partitioner maintains monthly range partitions of the order and item tables:
+// it creates partitions ahead of time and drops the ones past the retention.
+type Partitioner struct {
+	pool   *pgxpool.Pool
+	tables config.Tables
+	cfg    config.Partitions
+	logger *zap.Logger
+}
+
+func NewPartitioner(pool *pgxpool.Pool, t config.Tables, cfg config.Partitions, logger *zap.Logger) *Partitioner {
+	return &Partitioner{
+		pool:   pool,
+		tables: t,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// Run maintains partitions immediately and then every cfg.Interval until ctx is done.
+func (p *Partitioner) Run(ctx context.Context) {
+	interval := p.cfg.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := p.Maintain(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			p.logger.Error("partition maintenance failed", zap.Error(err))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Maintain creates missing future partitions and drops expired ones.
+func (p *Partitioner) Maintain(ctx context.Context, now time.Time) error {
+	return errors.Join(p.EnsurePartitions(ctx, now), p.DropExpired(ctx, now))
+}
+
+// EnsurePartitions creates partitions for the current month and cfg.Ahead months
+// after it. A month that fails is logged and the later ones are still created;
+// the failures are returned together.
+//
+// A month whose orders already landed in the default partition, dated beyond
+// the partitions made ahead, is split out of it (see split).
+func (p *Partitioner) EnsurePartitions(ctx context.Context, now time.Time) error {
+	first := monthStart(now)
+	var errs []error
+	for i := 0; i <= max(p.cfg.Ahead, 0); i++ {
+		from := first.AddDate(0, i, 0)
+		if err := p.ensureMonth(ctx, from); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			p.logger.Error("failed to create partition", zap.String("partition", partitionName(p.tables.Order, from)), zap.Error(err))
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ensureMonth creates the order and item partitions of the month starting at
+// from unless they exist, moving the month's rows out of the default partition.
+func (p *Partitioner) ensureMonth(ctx context.Context, from time.Time) error {
+	to := from.AddDate(0, 1, 0)
+	name := partitionName(p.tables.Order, from)
+
+	var exists, inDefault bool
+	err := p.pool.QueryRow(ctx, fmt.Sprintf(`
+		SELECT to_regclass($1) IS NOT NULL AND to_regclass($2) IS NOT NULL,
+		  EXISTS (SELECT 1 FROM %s WHERE date_created >= $3 AND date_created < $4)
+	`, p.qt(p.tables.Order+"_default")),
+		p.qt(name), p.qt(partitionName(p.tables.Item, from)), from, to,
+	).Scan(&exists, &inDefault)
+	if err != nil {
+		return fmt.Errorf("check partition %s: %w", name, err)
+	}
+	if exists {
+		return nil
+	}
+	if inDefault {
+		return p.split(ctx, from)
+	}
+	return p.create(ctx, p.pool, from)
+}
+
+// split moves the month starting at from out of the default partitions into
+// its own, in one transaction. The default order partition cannot be detached
+// while delivery, payment and items reference it, and deleting its rows
+// cascades to them, so the month's rows of all four tables are copied aside,
+// deleted, and inserted again once the partitions exist. Writes to the default
+// partitions wait until it commits.
+func (p *Partitioner) split(ctx context.Context, from time.Time) error {
+	to := from.AddDate(0, 1, 0)
+	name := partitionName(p.tables.Order, from)
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, fmt.Sprintf(`LOCK TABLE %s, %s IN EXCLUSIVE MODE`,
+		p.qt(p.tables.Order+"_default"), p.qt(p.tables.Item+"_default"))); err != nil {
+		return fmt.Errorf("lock default partitions: %w", err)
+	}
+
+	// Parents before children, both to copy and to insert again.
+	tables := []string{p.tables.Order, p.tables.Item, p.tables.Delivery, p.tables.Payment}
+	var moved int64
+	for _, tbl := range tables {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`CREATE TEMP TABLE %s (LIKE %s) ON COMMIT DROP`, splitTable(tbl), p.qt(tbl))); err != nil {
+			return fmt.Errorf("split %s: %w", name, err)
+		}
+		tag, err := tx.Exec(ctx, fmt.Sprintf(
+			`INSERT INTO %s SELECT * FROM %s WHERE date_created >= $1 AND date_created < $2`, splitTable(tbl), p.qt(tbl),
+		), from, to)
+		if err != nil {
+			return fmt.Errorf("split %s: %w", name, err)
+		}
+		if tbl == p.tables.Order {
+			moved = tag.RowsAffected()
+		}
+	}
+
+	// Cascades to the month's items, delivery and payment.
+	if _, err := tx.Exec(ctx, fmt.Sprintf(
+		`DELETE FROM %s WHERE date_created >= $1 AND date_created < $2`, p.qt(p.tables.Order+"_default"),
+	), from, to); err != nil {
+		return fmt.Errorf("split %s: %w", name, err)
+	}
+	if err := p.create(ctx, tx, from); err != nil {
+		return err
+	}
+	for _, tbl := range tables {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`INSERT INTO %s SELECT * FROM %s`, p.qt(tbl), splitTable(tbl))); err != nil {
+			return fmt.Errorf("split %s: %w", name, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	p.logger.Info("orders moved out of the default partition",
+		zap.String("partition", name), zap.Int64("orders", moved))
+	return nil
+}
+
+// create creates the order and item partitions of the month starting at from.
+func (p *Partitioner) create(ctx context.Context, db execer, from time.Time) error {
+	to := from.AddDate(0, 1, 0)
+	// Items reference orders, so the order partition goes first.
+	for _, tbl := range []string{p.tables.Order, p.tables.Item} {
+		_, err := db.Exec(ctx, fmt.Sprintf(
+			`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+			p.qt(partitionName(tbl, from)), p.qt(tbl),
+			from.Format(time.RFC3339), to.Format(time.RFC3339),
+		))
+		if err != nil {
+			return fmt.Errorf("create partition %s: %w", partitionName(tbl, from), err)
+		}
+	}
+	return nil
+}
+
+// execer is what create needs from a pool or a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// splitTable names the temporary table split copies tbl's rows into.
+func splitTable(tbl string) string { return pgx.Identifier{"split_" + tbl}.Sanitize() }
+
+// DropExpired detaches and drops monthly partitions that end before the retention
+// cutoff and removes expired rows that ended up in the default partitions.
+// A zero cfg.Retention keeps everything.
+func (p *Partitioner) DropExpired(ctx context.Context, now time.Time) error {
+	if p.cfg.Retention <= 0 {
+		return nil
+	}
+	cutoff := monthStart(now).AddDate(0, -p.cfg.Retention, 0)
+
+	names, err := p.partitions(ctx, p.tables.Order)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		from, ok := parsePartitionMonth(p.tables.Order, name)
+		if !ok || from.AddDate(0, 1, 0).After(cutoff) {
+			continue
+		}
+		if err := p.drop(ctx, from); err != nil {
+			return fmt.Errorf("drop partition %s: %w", name, err)
+		}
+		p.logger.Info("partition dropped", zap.String("partition", name), zap.Time("cutoff", cutoff))
+	}
+
+	// Deleting from the order table cascades to delivery, payment and items.
+	_, err = p.pool.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE date_created < $1`, p.qt(p.tables.Order+"_default")), cutoff)
+	return err
+}
+
+// drop removes the month starting at from: rows referencing it from the
+// unpartitioned delivery and payment tables first, then the item and order partitions.
+func (p *Partitioner) drop(ctx context.Context, from time.Time) error {
+	to := from.AddDate(0, 1, 0)
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, tbl := range []string{p.tables.Delivery, p.tables.Payment} {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(
+			`DELETE FROM %s WHERE date_created >= $1 AND date_created < $2`, p.qt(tbl),
+		), from, to); err != nil {
+			return err
+		}
+	}
+	for _, tbl := range []string{p.tables.Item, p.tables.Order} {
+		part := p.qt(partitionName(tbl, from))
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s DETACH PARTITION %s`, p.qt(tbl), part)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`DROP TABLE %s`, part)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (p *Partitioner) partitions(ctx context.Context, tbl string) ([]string, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class t ON t.oid = i.inhparent
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE n.nspname = $1 AND t.relname = $2
+		ORDER BY c.relname
+	`, p.tables.Schema, tbl)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (p *Partitioner) qt(tbl string) string { return fmt.Sprintf(`"%s"."%s"`, p.tables.Schema, tbl) }
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// partitionName follows the naming used by migrations: <table>_pYYYY_MM.
+func partitionName(tbl string, month time.Time) string {
+	return fmt.Sprintf("%s_p%04d_%02d", tbl, month.Year(), int(month.Month()))
+}
+
+func parsePartitionMonth(tbl, name string) (time.Time, bool) {
+	suffix, ok := strings.CutPrefix(name, tbl+"_p")
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse("2006_01", suffix)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/storage/storagetest"
+)
+
+func TestPartitionName(t *testing.T) {
+	month := monthStart(time.Date(2025, time.March, 17, 23, 59, 0, 0, time.FixedZone("MSK", 3*3600)))
+
+	require.Equal(t, time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC), month)
+	require.Equal(t, "order_p2025_03", partitionName("order", month))
+}
+
+func TestParsePartitionMonth(t *testing.T) {
+	tests := []struct {
+		name     string
+		relname  string
+		expected time.Time
+		ok       bool
+	}{
+		{
+			name:     "monthly partition",
+			relname:  "order_p2024_12",
+			expected: time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC),
+			ok:       true,
+		},
+		{
+			name:    "default partition",
+			relname: "order_default",
+		},
+		{
+			name:    "other table",
+			relname: "item_p2024_12",
+		},
+		{
+			name:    "bad month",
+			relname: "order_p2024_13",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parsePartitionMonth("order", tt.relname)
+			require.Equal(t, tt.ok, ok)
+			require.Equal(t, tt.expected, got)
+		})
+	}
+}
+
+// TestEnsurePartitionsSplitsDefault is skipped unless TEST_PG_DSN is set; the
+// tables are truncated.
+func TestEnsurePartitionsSplitsDefault(t *testing.T) {
+	dsn := os.Getenv("TEST_PG_DSN")
+	if dsn == "" {
+		t.Skip("TEST_PG_DSN is not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	tables := config.Tables{Schema: "orders", Order: "order", Delivery: "delivery", Payment: "payment", Item: "item", Reads: "order_reads"}
+	repo := New(pool, tables, config.Postgres{QueryTimeout: 5 * time.Second})
+	p := NewPartitioner(pool, tables, config.Partitions{}, zap.NewNop())
+	_, err = pool.Exec(ctx, fmt.Sprintf(`TRUNCATE %s CASCADE`, repo.qt(tables.Order)))
+	require.NoError(t, err)
+
+	// A month no partition is made ahead for.
+	month := time.Date(2031, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var exists bool
+	require.NoError(t, pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, p.qt(partitionName(tables.Order, month))).Scan(&exists))
+	if exists {
+		require.NoError(t, p.drop(ctx, month))
+	}
+
+	o := storagetest.NewOrder("split", 0)
+	o.DateCreated = month.Add(36 * time.Hour)
+	require.NoError(t, repo.Upsert(ctx, o))
+
+	partitionOf := func(tbl string) []string {
+		rows, err := pool.Query(ctx, fmt.Sprintf(
+			`SELECT c.relname FROM %s t JOIN pg_class c ON c.oid = t.tableoid WHERE t.order_uid = $1`, repo.qt(tbl)), o.OrderUID)
+		require.NoError(t, err)
+		names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		require.NoError(t, err)
+		return names
+	}
+	require.Equal(t, []string{"order_default"}, partitionOf(tables.Order))
+
+	require.NoError(t, p.EnsurePartitions(ctx, month))
+	require.Equal(t, []string{"order_p2031_01"}, partitionOf(tables.Order))
+	require.Equal(t, []string{"item_p2031_01", "item_p2031_01"}, partitionOf(tables.Item))
+
+	got, err := repo.GetByUID(ctx, o.OrderUID)
+	require.NoError(t, err)
+	require.Equal(t, o.Delivery, got.Delivery)
+	require.Equal(t, o.Payment, got.Payment)
+	require.Len(t, got.Items, 2)
+}
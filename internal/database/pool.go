@@ -0,0 +1,155 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"github.com/TemirB/wb-tech-L0/internal/pkg/retry"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// Connect builds a pool from dsn and the pool settings in cfg and waits for
+// Postgres to answer a ping, retrying with cfg.Connect backoff. It returns
+// an error instead of panicking so callers decide how to fail.
+func Connect(ctx context.Context, dsn string, cfg config.Postgres, logger *zap.Logger) (*pgxpool.Pool, error) {
+	pcfg, err := poolConfig(dsn, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Connect.Attempts <= 0 {
+		cfg.Connect.Attempts = 1
+	}
+
+	var pool *pgxpool.Pool
+	attempt := 0
+	err = retry.Do(ctx, cfg.Connect, func() error {
+		attempt++
+		p, err := pgxpool.NewWithConfig(ctx, pcfg)
+		if err == nil {
+			pingCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+			err = p.Ping(pingCtx)
+			cancel()
+			if err != nil {
+				p.Close()
+			}
+		}
+		if err != nil {
+			logger.Warn("postgres is not available yet",
+				zap.Int("attempt", attempt),
+				zap.Int("attempts", cfg.Connect.Attempts),
+				zap.Error(err),
+			)
+			return err
+		}
+		pool = p
+		return nil
+	})
+	if pool == nil {
+		return nil, fmt.Errorf("connect to postgres after %d attempts: %w", attempt, err)
+	}
+
+	logger.Info("connected to postgres",
+		zap.Int("attempt", attempt),
+		zap.Int32("max_conns", pcfg.MaxConns),
+		zap.Int32("min_conns", pcfg.MinConns),
+	)
+	return pool, nil
+}
+
+func poolConfig(dsn string, cfg config.Postgres) (*pgxpool.Config, error) {
+	pcfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxConns > 0 {
+		pcfg.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		pcfg.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		pcfg.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		pcfg.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	if cfg.HealthCheckPeriod > 0 {
+		pcfg.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+
+	// By default pgx closes the connection when a query's context ends. Ask the server
+	// to cancel the statement instead, so request deadlines act as statement timeouts
+	// and the connection survives; it is only closed if the cancel goes unanswered.
+	pcfg.ConnConfig.BuildContextWatcherHandler = func(c *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{
+			Conn:               c,
+			CancelRequestDelay: 0,
+			DeadlineDelay:      time.Second,
+		}
+	}
+	return pcfg, nil
+}
+
+// needsReset reports errors after which pooled connections are probably pointing at
+// a server that is gone or is no longer the primary (failover, restart).
+func needsReset(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code {
+	case "25006", // read_only_sql_transaction: connected to a demoted primary
+		"57P01", // admin_shutdown
+		"57P02", // crash_shutdown
+		"57P03": // cannot_connect_now
+		return true
+	}
+	return false
+}
+
+// ReportPoolStats sends pool statistics to m every interval until ctx is done.
+// The wait time is the average time an acquire waited during the last interval.
+func ReportPoolStats(ctx context.Context, pool *pgxpool.Pool, m observability.Metrics, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	var prevCount int64
+	var prevWait time.Duration
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		st := pool.Stat()
+		count, wait := st.AcquireCount(), st.AcquireDuration()
+		var waitMs float64
+		if n := count - prevCount; n > 0 {
+			waitMs = float64((wait - prevWait).Microseconds()) / 1000.0 / float64(n)
+		}
+		prevCount, prevWait = count, wait
+
+		m.ObserveDBPool(observability.PoolStats{
+			Acquired:      st.AcquiredConns(),
+			Idle:          st.IdleConns(),
+			Total:         st.TotalConns(),
+			Max:           st.MaxConns(),
+			Acquires:      count,
+			EmptyAcquires: st.EmptyAcquireCount(),
+			AcquireWaitMs: waitMs,
+		})
+	}
+}
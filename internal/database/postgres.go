@@ -2,8 +2,11 @@ package database
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	"github.com/TemirB/wb-tech-L0/internal/config"
 	"github.com/TemirB/wb-tech-L0/internal/domain"
@@ -15,35 +18,165 @@ import (
 type Repo struct {
 	pool   *pgxpool.Pool
 	tables config.Tables
+	pg     config.Postgres
 }
 
-func New(pool *pgxpool.Pool, t config.Tables) *Repo { return &Repo{pool: pool, tables: t} }
+// New returns a repository over pool. pg.QueryTimeout bounds calls whose context has
+// no deadline; pg.NotifyChannel, if set, receives a notification per upserted order.
+func New(pool *pgxpool.Pool, t config.Tables, pg config.Postgres) *Repo {
+	return &Repo{pool: pool, tables: t, pg: pg}
+}
 
-func Connect(ctx context.Context, dsn string) *pgxpool.Pool {
-	pool, err := pgxpool.New(ctx, dsn)
-	if err != nil {
-		panic(err)
+// Notification is the payload sent on pg.NotifyChannel when an order changes.
+type Notification struct {
+	UID     string `json:"uid"`
+	Version int64  `json:"version"`
+}
+
+// withTimeout applies the default query timeout unless ctx already has a deadline.
+// Deadlines cancel the running statement on the server (see poolConfig).
+func (r *Repo) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || r.pg.QueryTimeout <= 0 {
+		return ctx, func() {}
 	}
-	if err := pool.Ping(ctx); err != nil {
-		panic(err)
+	return context.WithTimeout(ctx, r.pg.QueryTimeout)
+}
+
+// check drops every pooled connection after errors that indicate a failover or a
+// server restart, so the next queries reconnect instead of failing one by one.
+func (r *Repo) check(err error) {
+	if err != nil && needsReset(err) {
+		r.pool.Reset()
 	}
-	return pool
 }
 
 func (r *Repo) qt(tbl string) string { return fmt.Sprintf(`"%s"."%s"`, r.tables.Schema, tbl) }
 
+// Upsert writes the order with all its parts in one transaction.
 func (r *Repo) Upsert(ctx context.Context, o *domain.Order) error {
+	return r.UpsertBatch(ctx, []*domain.Order{o})
+}
+
+// UpsertBatch writes several orders in one transaction with the same semantics
+// as Upsert; items of the whole batch are loaded with a single COPY.
+// An order_uid must not appear twice in one batch.
+//
+// Orders are partitioned by date_created, so order_uid alone is not a unique key
+// in Postgres. Each order takes an advisory lock on its order_uid and, if the
+// stored date_created differs from the incoming one, the old row is deleted
+// (cascading to delivery, payment and items) so the order moves to its new partition.
+//
+// Every order gets the next version and the update time, written back into
+// o.Version and o.UpdatedAt, and a NOTIFY that Postgres delivers to listeners
+// only once the transaction commits.
+//
+// A batch of more than one order is a bulk write, as in imports and
+// write-behind flushes: like StreamOrders, it is bounded only by ctx, not by the
+// default query timeout meant for single orders.
+func (r *Repo) UpsertBatch(ctx context.Context, orders []*domain.Order) error {
+	return r.upsert(ctx, orders, nil)
+}
+
+// UpsertIf upserts o like Upsert if the stored order passes p, else it returns
+// domain.ErrVersionMismatch. The version is checked under the order's advisory
+// lock, in the transaction that bumps it, so concurrent writers cannot both
+// pass with the same version.
+func (r *Repo) UpsertIf(ctx context.Context, o *domain.Order, p domain.Precondition) error {
+	return r.upsert(ctx, []*domain.Order{o}, &p)
+}
+
+// upsert writes the orders in one transaction; with a precondition, every
+// stored order must pass it.
+func (r *Repo) upsert(ctx context.Context, orders []*domain.Order, p *domain.Precondition) (err error) {
+	defer func() { r.check(err) }()
+	if len(orders) == 1 {
+		var cancel context.CancelFunc
+		ctx, cancel = r.withTimeout(ctx)
+		defer cancel()
+	}
+
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback(ctx)
 
+	// Statements of the transaction must finish within the caller's deadline even
+	// if the client-side cancel never reaches the server.
+	if dl, ok := ctx.Deadline(); ok {
+		ms := time.Until(dl).Milliseconds()
+		if ms < 1 {
+			return context.DeadlineExceeded
+		}
+		if _, err := tx.Exec(ctx, `SELECT set_config('statement_timeout', $1, true)`, strconv.FormatInt(ms, 10)); err != nil {
+			return err
+		}
+	}
+
+	var items [][]any
+	for _, o := range orders {
+		if err := r.upsertOrder(ctx, tx, o, p); err != nil {
+			return fmt.Errorf("order %s: %w", o.OrderUID, err)
+		}
+		for _, it := range o.Items {
+			items = append(items, []any{
+				o.OrderUID, o.DateCreated, it.ChrtID, it.TrackNumber, it.Price, it.RID, it.Name, it.Sale,
+				it.Size, it.TotalPrice, it.NmID, it.Brand, it.Status,
+			})
+		}
+	}
+
+	if len(items) > 0 {
+		if _, err := tx.CopyFrom(ctx,
+			pgx.Identifier{r.tables.Schema, r.tables.Item},
+			[]string{"order_uid", "date_created", "chrt_id", "track_number", "price", "rid", "name", "sale", "size",
+				"total_price", "nm_id", "brand", "status"},
+			pgx.CopyFromRows(items),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// upsertOrder writes everything but the items and clears the items currently
+// stored. With a precondition, the stored version must pass it.
+func (r *Repo) upsertOrder(ctx context.Context, tx pgx.Tx, o *domain.Order, p *domain.Precondition) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, o.OrderUID); err != nil {
+		return err
+	}
+
+	var (
+		prev        time.Time
+		prevVersion int64
+	)
+	err := tx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT date_created, version FROM %s WHERE order_uid=$1
+	`, r.qt(r.tables.Order)), o.OrderUID).Scan(&prev, &prevVersion)
+	version := prevVersion + 1
+	// Postgres keeps microseconds; the order gets the time as stored.
+	updated := time.Now().UTC().Truncate(time.Microsecond)
+	stored := true
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		stored = false
+	case err != nil:
+		return err
+	}
+	if p != nil && !p.Allows(prevVersion) {
+		return domain.ErrVersionMismatch
+	}
+	if stored && !prev.Equal(o.DateCreated) {
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE order_uid=$1`, r.qt(r.tables.Order)), o.OrderUID); err != nil {
+			return err
+		}
+	}
+
 	_, err = tx.Exec(ctx, fmt.Sprintf(`
 		INSERT INTO %s (order_uid, track_number, entry, locale, internal_signature,
-		  customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
-		ON CONFLICT (order_uid) DO UPDATE SET
+		  customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, version, updated_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+		ON CONFLICT (order_uid, date_created) DO UPDATE SET
 		  track_number=EXCLUDED.track_number,
 		  entry=EXCLUDED.entry,
 		  locale=EXCLUDED.locale,
@@ -52,24 +185,38 @@ func (r *Repo) Upsert(ctx context.Context, o *domain.Order) error {
 		  delivery_service=EXCLUDED.delivery_service,
 		  shardkey=EXCLUDED.shardkey,
 		  sm_id=EXCLUDED.sm_id,
-		  date_created=EXCLUDED.date_created,
-		  oof_shard=EXCLUDED.oof_shard
+		  oof_shard=EXCLUDED.oof_shard,
+		  version=EXCLUDED.version,
+		  updated_at=EXCLUDED.updated_at
 	`, r.qt(r.tables.Order)),
 		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
-		o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard,
+		o.DeliveryService, o.ShardKey, o.SmID, o.DateCreated, o.OofShard, version, updated,
 	)
 	if err != nil {
 		return err
 	}
+	o.Version = version
+	o.UpdatedAt = &updated
+
+	if r.pg.NotifyChannel != "" {
+		payload, err := json.Marshal(Notification{UID: o.OrderUID, Version: version})
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `SELECT pg_notify($1, $2)`, r.pg.NotifyChannel, string(payload)); err != nil {
+			return err
+		}
+	}
 
 	_, err = tx.Exec(ctx, fmt.Sprintf(`
-		INSERT INTO %s (order_uid, name, phone, zip, city, address, region, email)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+		INSERT INTO %s (order_uid, date_created, name, phone, zip, city, address, region, email)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
 		ON CONFLICT (order_uid) DO UPDATE SET
+		  date_created=EXCLUDED.date_created,
 		  name=EXCLUDED.name, phone=EXCLUDED.phone, zip=EXCLUDED.zip, city=EXCLUDED.city,
 		  address=EXCLUDED.address, region=EXCLUDED.region, email=EXCLUDED.email
 	`, r.qt(r.tables.Delivery)),
-		o.OrderUID, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City,
+		o.OrderUID, o.DateCreated, o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City,
 		o.Delivery.Address, o.Delivery.Region, o.Delivery.Email,
 	)
 	if err != nil {
@@ -77,52 +224,42 @@ func (r *Repo) Upsert(ctx context.Context, o *domain.Order) error {
 	}
 
 	_, err = tx.Exec(ctx, fmt.Sprintf(`
-		INSERT INTO %s (transaction, order_uid, request_id, currency, provider, amount,
+		INSERT INTO %s (transaction, order_uid, date_created, request_id, currency, provider, amount,
 		  payment_dt, bank, delivery_cost, goods_total, custom_fee)
-		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
 		ON CONFLICT (transaction) DO UPDATE SET
-		  order_uid=EXCLUDED.order_uid, request_id=EXCLUDED.request_id, currency=EXCLUDED.currency,
-		  provider=EXCLUDED.provider, amount=EXCLUDED.amount, payment_dt=EXCLUDED.payment_dt, bank=EXCLUDED.bank,
-		  delivery_cost=EXCLUDED.delivery_cost, goods_total=EXCLUDED.goods_total, custom_fee=EXCLUDED.custom_fee
+		  order_uid=EXCLUDED.order_uid, date_created=EXCLUDED.date_created, request_id=EXCLUDED.request_id,
+		  currency=EXCLUDED.currency, provider=EXCLUDED.provider, amount=EXCLUDED.amount,
+		  payment_dt=EXCLUDED.payment_dt, bank=EXCLUDED.bank, delivery_cost=EXCLUDED.delivery_cost,
+		  goods_total=EXCLUDED.goods_total, custom_fee=EXCLUDED.custom_fee
 	`, r.qt(r.tables.Payment)),
-		o.Payment.Transaction, o.OrderUID, o.Payment.RequestID, o.Payment.Currency, o.Payment.Provider,
-		o.Payment.Amount, o.Payment.PaymentDT, o.Payment.Bank, o.Payment.DeliveryCost,
+		o.Payment.Transaction, o.OrderUID, o.DateCreated, o.Payment.RequestID, o.Payment.Currency,
+		o.Payment.Provider, o.Payment.Amount, o.Payment.PaymentDT, o.Payment.Bank, o.Payment.DeliveryCost,
 		o.Payment.GoodsTotal, o.Payment.CustomFee,
 	)
 	if err != nil {
 		return err
 	}
 
-	if _, err := tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE order_uid=$1`, r.qt(r.tables.Item)), o.OrderUID); err != nil {
-		return err
-	}
-	batch := &pgx.Batch{}
-	for _, it := range o.Items {
-		batch.Queue(fmt.Sprintf(`
-			INSERT INTO %s (order_uid, chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status)
-			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
-		`, r.qt(r.tables.Item)),
-			o.OrderUID, it.ChrtID, it.TrackNumber, it.Price, it.RID, it.Name, it.Sale,
-			it.Size, it.TotalPrice, it.NmID, it.Brand, it.Status,
-		)
-	}
-	if br := tx.SendBatch(ctx, batch); br != nil {
-		if err := br.Close(); err != nil {
-			return err
-		}
-	}
-	return tx.Commit(ctx)
+	_, err = tx.Exec(ctx, fmt.Sprintf(`
+		DELETE FROM %s WHERE order_uid=$1 AND date_created=$2
+	`, r.qt(r.tables.Item)), o.OrderUID, o.DateCreated)
+	return err
 }
 
-func (r *Repo) GetByUID(ctx context.Context, uid string) (*domain.Order, error) {
+func (r *Repo) GetByUID(ctx context.Context, uid string) (_ *domain.Order, err error) {
+	defer func() { r.check(err) }()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	var o domain.Order
-	err := r.pool.QueryRow(ctx, fmt.Sprintf(`
+	err = r.pool.QueryRow(ctx, fmt.Sprintf(`
 		SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
-		       shardkey, sm_id, date_created, oof_shard
+		       shardkey, sm_id, date_created, oof_shard, version, updated_at
 		FROM %s WHERE order_uid=$1
 	`, r.qt(r.tables.Order)), uid).Scan(
 		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature, &o.CustomerID,
-		&o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard,
+		&o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard, &o.Version, &o.UpdatedAt,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, domain.ErrNotFound
@@ -152,10 +289,11 @@ func (r *Repo) GetByUID(ctx context.Context, uid string) (*domain.Order, error)
 		return nil, err
 	}
 
+	// date_created lets Postgres prune the item partitions it has to look at.
 	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
 		SELECT chrt_id, track_number, price, rid, name, sale, size, total_price, nm_id, brand, status
-		FROM %s WHERE order_uid=$1
-	`, r.qt(r.tables.Item)), uid)
+		FROM %s WHERE order_uid=$1 AND date_created=$2
+	`, r.qt(r.tables.Item)), uid, o.DateCreated)
 	if err != nil {
 		return &o, err
 	}
@@ -172,7 +310,11 @@ func (r *Repo) GetByUID(ctx context.Context, uid string) (*domain.Order, error)
 	return &o, rows.Err()
 }
 
-func (r *Repo) RecentOrderIDs(ctx context.Context, limit int) ([]string, error) {
+func (r *Repo) RecentOrderIDs(ctx context.Context, limit int) (_ []string, err error) {
+	defer func() { r.check(err) }()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
 	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
 		SELECT order_uid FROM %s
 		ORDER BY date_created DESC NULLS LAST
@@ -193,3 +335,246 @@ func (r *Repo) RecentOrderIDs(ctx context.Context, limit int) ([]string, error)
 	}
 	return ids, rows.Err()
 }
+
+// FrequentOrderIDs returns existing orders with recorded reads, most read first.
+func (r *Repo) FrequentOrderIDs(ctx context.Context, limit int) (_ []string, err error) {
+	defer func() { r.check(err) }()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
+		SELECT r.order_uid FROM %s r
+		WHERE EXISTS (SELECT 1 FROM %s o WHERE o.order_uid = r.order_uid)
+		ORDER BY r.reads DESC, r.order_uid
+		LIMIT $1
+	`, r.qt(r.tables.Reads), r.qt(r.tables.Order)), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// AddReads adds to the read counters FrequentOrderIDs ranks by, in one statement.
+func (r *Repo) AddReads(ctx context.Context, reads map[string]int64) (err error) {
+	if len(reads) == 0 {
+		return nil
+	}
+	defer func() { r.check(err) }()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	uids := make([]string, 0, len(reads))
+	counts := make([]int64, 0, len(reads))
+	for uid, n := range reads {
+		uids = append(uids, uid)
+		counts = append(counts, n)
+	}
+	_, err = r.pool.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s AS r (order_uid, reads, last_read)
+		SELECT uid, n, now() FROM unnest($1::text[], $2::bigint[]) AS t(uid, n)
+		ON CONFLICT (order_uid) DO UPDATE
+		SET reads = r.reads + EXCLUDED.reads, last_read = EXCLUDED.last_read
+	`, r.qt(r.tables.Reads)), uids, counts)
+	return err
+}
+
+// StreamOrders reads complete orders matching f ordered by date_created and calls fn
+// for each one as rows arrive, so exports never hold the whole table in memory.
+// Being a bulk read, it is bounded only by ctx, not by the default query timeout.
+func (r *Repo) StreamOrders(ctx context.Context, f domain.OrderFilter, fn func(*domain.Order) error) (err error) {
+	defer func() { r.check(err) }()
+
+	var from, to *time.Time
+	if !f.From.IsZero() {
+		from = &f.From
+	}
+	if !f.To.IsZero() {
+		to = &f.To
+	}
+
+	rows, err := r.pool.Query(ctx, r.selectOrders()+`
+		WHERE ($1::timestamptz IS NULL OR o.date_created >= $1)
+		  AND ($2::timestamptz IS NULL OR o.date_created < $2)
+		  AND ($3 = '' OR o.customer_id = $3)
+		ORDER BY o.date_created, o.order_uid
+	`, from, to, f.CustomerID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetByUIDs reads the orders in uids that exist with one query, keyed by UID.
+func (r *Repo) GetByUIDs(ctx context.Context, uids []string) (_ map[string]*domain.Order, err error) {
+	defer func() { r.check(err) }()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, r.selectOrders()+`
+		WHERE o.order_uid = ANY($1)
+	`, uids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]*domain.Order, len(uids))
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		out[o.OrderUID] = o
+	}
+	return out, rows.Err()
+}
+
+// GetByTrackNumber reads the newest order with the track number.
+func (r *Repo) GetByTrackNumber(ctx context.Context, track string) (_ *domain.Order, err error) {
+	defer func() { r.check(err) }()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, r.selectOrders()+`
+		WHERE o.track_number = $1
+		ORDER BY o.date_created DESC, o.order_uid
+		LIMIT 1
+	`, track)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrNotFound
+	}
+	return scanOrder(rows)
+}
+
+// CustomerOrderIDs returns the IDs of the customer's orders, newest first.
+func (r *Repo) CustomerOrderIDs(ctx context.Context, customerID string, limit int) (_ []string, err error) {
+	defer func() { r.check(err) }()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
+		SELECT order_uid FROM %s
+		WHERE customer_id = $1
+		ORDER BY date_created DESC, order_uid
+		LIMIT $2
+	`, r.qt(r.tables.Order)), customerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// selectOrders selects complete orders, one row each with the items aggregated
+// into JSON, for scanOrder. Callers append the WHERE and ORDER BY clauses.
+func (r *Repo) selectOrders() string {
+	return fmt.Sprintf(`
+		SELECT o.order_uid, o.track_number, COALESCE(o.entry, ''), COALESCE(o.locale, ''),
+		       COALESCE(o.internal_signature, ''), COALESCE(o.customer_id, ''), COALESCE(o.delivery_service, ''),
+		       COALESCE(o.shardkey, ''), COALESCE(o.sm_id, 0), o.date_created, COALESCE(o.oof_shard, ''), o.version, o.updated_at,
+		       COALESCE(d.name, ''), COALESCE(d.phone, ''), COALESCE(d.zip, ''), COALESCE(d.city, ''),
+		       COALESCE(d.address, ''), COALESCE(d.region, ''), COALESCE(d.email, ''),
+		       COALESCE(p.transaction, ''), COALESCE(p.request_id, ''), COALESCE(p.currency, ''),
+		       COALESCE(p.provider, ''), COALESCE(p.amount, 0), COALESCE(p.payment_dt, 0), COALESCE(p.bank, ''),
+		       COALESCE(p.delivery_cost, 0), COALESCE(p.goods_total, 0), COALESCE(p.custom_fee, 0),
+		       (SELECT COALESCE(json_agg(json_build_object(
+		                 'chrt_id', i.chrt_id, 'track_number', i.track_number, 'price', i.price, 'rid', i.rid,
+		                 'name', i.name, 'sale', i.sale, 'size', i.size, 'total_price', i.total_price,
+		                 'nm_id', i.nm_id, 'brand', i.brand, 'status', i.status)), '[]')
+		          FROM %[4]s i WHERE i.order_uid = o.order_uid AND i.date_created = o.date_created)
+		FROM %[1]s o
+		LEFT JOIN %[2]s d ON d.order_uid = o.order_uid
+		LEFT JOIN LATERAL (
+		  SELECT * FROM %[3]s WHERE order_uid = o.order_uid LIMIT 1
+		) p ON true`,
+		r.qt(r.tables.Order), r.qt(r.tables.Delivery), r.qt(r.tables.Payment), r.qt(r.tables.Item))
+}
+
+func scanOrder(rows pgx.Rows) (*domain.Order, error) {
+	var (
+		o     domain.Order
+		items []byte
+	)
+	if err := rows.Scan(
+		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature, &o.CustomerID,
+		&o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard, &o.Version, &o.UpdatedAt,
+		&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City,
+		&o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
+		&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency, &o.Payment.Provider,
+		&o.Payment.Amount, &o.Payment.PaymentDT, &o.Payment.Bank, &o.Payment.DeliveryCost,
+		&o.Payment.GoodsTotal, &o.Payment.CustomFee,
+		&items,
+	); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(items, &o.Items); err != nil {
+		return nil, fmt.Errorf("order %s items: %w", o.OrderUID, err)
+	}
+	return &o, nil
+}
+
+// Versions returns the stored version of every order in uids that exists.
+func (r *Repo) Versions(ctx context.Context, uids []string) (_ map[string]int64, err error) {
+	defer func() { r.check(err) }()
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := r.pool.Query(ctx, fmt.Sprintf(`
+		SELECT order_uid, version FROM %s WHERE order_uid = ANY($1)
+	`, r.qt(r.tables.Order)), uids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]int64, len(uids))
+	for rows.Next() {
+		var (
+			uid     string
+			version int64
+		)
+		if err := rows.Scan(&uid, &version); err != nil {
+			return nil, err
+		}
+		out[uid] = version
+	}
+	return out, rows.Err()
+}
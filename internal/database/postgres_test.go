@@ -0,0 +1,42 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/storage/storagetest"
+)
+
+// TestPostgresConformance runs the storage conformance suite against a real database
+// with migrations applied. It is skipped unless TEST_PG_DSN is set; the tables are truncated.
+func TestPostgresConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_PG_DSN")
+	if dsn == "" {
+		t.Skip("TEST_PG_DSN is not set")
+	}
+
+	ctx := context.Background()
+	pool, err := pgxpool.New(ctx, dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	tables := config.Tables{Schema: "orders", Order: "order", Delivery: "delivery", Payment: "payment", Item: "item", Reads: "order_reads"}
+	repo := New(pool, tables, config.Postgres{QueryTimeout: 5 * time.Second, NotifyChannel: "orders_changed"})
+	// The suite's orders are dated March-May 2025; make sure their partitions exist.
+	p := NewPartitioner(pool, tables, config.Partitions{Ahead: 3}, zap.NewNop())
+
+	storagetest.Run(t, func(t *testing.T) storagetest.Repo {
+		_, err := pool.Exec(ctx, fmt.Sprintf(`TRUNCATE %s, %s CASCADE`, repo.qt(tables.Order), repo.qt(tables.Reads)))
+		require.NoError(t, err)
+		require.NoError(t, p.EnsurePartitions(ctx, storagetest.NewOrder("", 0).DateCreated))
+		return repo
+	})
+}
@@ -2,11 +2,38 @@ package domain
 
 import (
 	"errors"
+	"slices"
 	"time"
 )
 
 var ErrNotFound = errors.New("order not found")
 
+// ErrVersionMismatch is returned by a conditional upsert when the stored order
+// is not a version its Precondition allows.
+var ErrVersionMismatch = errors.New("order version mismatch")
+
+// Precondition restricts an upsert to stored versions of the order, as the
+// entity tags of If-Match do. Any allows every stored version; an order that
+// is not stored never passes.
+type Precondition struct {
+	Any      bool
+	Versions []int64
+}
+
+// Allows reports whether an order stored at version passes; version is 0 when
+// the order is not stored.
+func (p Precondition) Allows(version int64) bool {
+	return version > 0 && (p.Any || slices.Contains(p.Versions, version))
+}
+
+// OrderFilter narrows bulk reads of orders. Zero values mean "no restriction";
+// the date range is half-open: From <= date_created < To.
+type OrderFilter struct {
+	From       time.Time
+	To         time.Time
+	CustomerID string
+}
+
 type Order struct {
 	OrderUID          string    `json:"order_uid"`
 	TrackNumber       string    `json:"track_number"`
@@ -22,6 +49,11 @@ type Order struct {
 	SmID              int       `json:"sm_id"`
 	DateCreated       time.Time `json:"date_created"`
 	OofShard          string    `json:"oof_shard"`
+
+	// Version is assigned by storage and bumped on every upsert; incoming values are ignored.
+	Version int64 `json:"version,omitempty"`
+	// UpdatedAt is set by storage on every upsert, like Version.
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
 }
 
 type Delivery struct {
@@ -60,3 +92,12 @@ type Item struct {
 	Brand       string `json:"brand"`
 	Status      int    `json:"status"`
 }
+
+// Validate checks the invariants every ingestion path (HTTP, Kafka, import) relies on.
+func (o Order) Validate() error {
+	if o.OrderUID == "" {
+		return errors.New("order_uid is required")
+	}
+	// ... some new validate params
+	return nil
+}
@@ -0,0 +1,146 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/TemirB/wb-tech-L0/internal/auth"
+	"github.com/TemirB/wb-tech-L0/internal/cache"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+//go:generate mockgen -source internal/httpapi/admin.go -destination=internal/httpapi/admin_mock_test.go -package=httpapi
+
+// CacheAdmin is what the admin endpoints operate on (see cache.Cache).
+type CacheAdmin interface {
+	Stats() observability.CacheStats
+	Inspect(uid string) (cache.EntryInfo, bool)
+	Remove(uid string) bool
+	Purge() int
+	Resize(capacity int) (evicted int, err error)
+	// Rewarm starts a background warm-up and reports whether it started.
+	Rewarm() bool
+}
+
+type cacheEntry struct {
+	UID         string `json:"uid"`
+	Cached      bool   `json:"cached"`
+	Version     int64  `json:"version,omitempty"`
+	SizeBytes   int64  `json:"size_bytes,omitempty"`
+	AgeMs       int64  `json:"age_ms,omitempty"`
+	ExpiresInMs int64  `json:"expires_in_ms,omitempty"` // negative once expired, absent without a TTL
+	Stale       bool   `json:"stale,omitempty"`
+}
+
+type resizeRequest struct {
+	Capacity int `json:"capacity"`
+}
+
+// EnableAdmin registers the cache administration endpoints under /admin/cache,
+// accessible with "Authorization: Bearer <token>", or to the admin role once
+// EnableAuth is called. An empty token without EnableAuth leaves them disabled.
+// Must be called before the server starts.
+func (s *Server) EnableAdmin(admin CacheAdmin, token string) {
+	if token == "" && s.auth == nil {
+		return
+	}
+	s.admin = admin
+	auth := s.adminAuth(token)
+	s.router.Method(http.MethodGet, "/admin/cache", auth(s.cacheStats))
+	s.router.Method(http.MethodDelete, "/admin/cache", auth(s.purgeCache))
+	s.router.Method(http.MethodPut, "/admin/cache/capacity", auth(s.resizeCache))
+	s.router.Method(http.MethodPost, "/admin/cache/warm", auth(s.rewarmCache))
+	s.router.Method(http.MethodGet, "/admin/cache/{uid}", auth(s.inspectCache))
+	s.router.Method(http.MethodDelete, "/admin/cache/{uid}", auth(s.evictCache))
+}
+
+func (s *Server) adminAuth(token string) func(http.HandlerFunc) http.Handler {
+	return func(next http.HandlerFunc) http.Handler {
+		if s.auth != nil {
+			return s.allow(auth.RoleAdmin, next)
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				s.refuse(w, r, http.StatusUnauthorized, "unauthorized")
+				return
+			}
+			// The token holder is the admin role, rate limited as such.
+			s.allow(auth.RoleAdmin, next)(w, r)
+		})
+	}
+}
+
+func (s *Server) cacheStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.admin.Stats())
+}
+
+func (s *Server) inspectCache(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "uid")
+	resp := cacheEntry{UID: uid}
+	if info, ok := s.admin.Inspect(uid); ok {
+		resp = cacheEntry{
+			UID:         uid,
+			Cached:      true,
+			Version:     info.Version,
+			SizeBytes:   info.Size,
+			AgeMs:       info.Age.Milliseconds(),
+			ExpiresInMs: info.ExpiresIn.Milliseconds(),
+			Stale:       info.Stale,
+		}
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) evictCache(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "uid")
+	evicted := s.admin.Remove(uid)
+	s.logAdmin(r, "cache entry evicted", zap.String("order_uid", uid), zap.Bool("was_cached", evicted))
+	writeJSON(w, map[string]any{"uid": uid, "evicted": evicted})
+}
+
+func (s *Server) purgeCache(w http.ResponseWriter, r *http.Request) {
+	n := s.admin.Purge()
+	s.logAdmin(r, "cache purged", zap.Int("orders", n))
+	writeJSON(w, map[string]int{"purged": n})
+}
+
+func (s *Server) resizeCache(w http.ResponseWriter, r *http.Request) {
+	var req resizeRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		if bodyTooLarge(w, err) {
+			return
+		}
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	evicted, err := s.admin.Resize(req.Capacity)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.logAdmin(r, "cache resized", zap.Int("capacity", req.Capacity), zap.Int("evicted", evicted))
+	writeJSON(w, map[string]int{"capacity": req.Capacity, "evicted": evicted})
+}
+
+func (s *Server) rewarmCache(w http.ResponseWriter, r *http.Request) {
+	if !s.admin.Rewarm() {
+		http.Error(w, "cache warm-up is already running or disabled", http.StatusConflict)
+		return
+	}
+	s.logAdmin(r, "cache re-warm requested")
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, map[string]bool{"started": true})
+}
+
+// logAdmin records a state-changing admin action.
+func (s *Server) logAdmin(r *http.Request, msg string, fields ...zap.Field) {
+	s.log(r).Info("admin: "+msg, append(fields, zap.String("remote", r.RemoteAddr))...)
+}
@@ -0,0 +1,190 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/TemirB/wb-tech-L0/internal/cache"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+const testAdminToken = "s3cret"
+
+func TestServer_Admin(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		token          string
+		setup          func(admin *MockCacheAdmin)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "no token",
+			method:         http.MethodDelete,
+			path:           "/admin/cache",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "unauthorized",
+		},
+		{
+			name:           "wrong token",
+			method:         http.MethodDelete,
+			path:           "/admin/cache",
+			token:          "guess",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:   "stats",
+			method: http.MethodGet,
+			path:   "/admin/cache",
+			token:  testAdminToken,
+			setup: func(admin *MockCacheAdmin) {
+				admin.EXPECT().Stats().Return(observability.CacheStats{Entries: 3, Capacity: 10})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"Entries": 3`,
+		},
+		{
+			name:   "inspect cached",
+			method: http.MethodGet,
+			path:   "/admin/cache/a",
+			token:  testAdminToken,
+			setup: func(admin *MockCacheAdmin) {
+				admin.EXPECT().Inspect("a").Return(cache.EntryInfo{UID: "a", Version: 2, Size: 512, Age: 1500 * time.Millisecond}, true)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"cached": true,` + "\n" + `  "version": 2,` + "\n" + `  "size_bytes": 512,` + "\n" + `  "age_ms": 1500`,
+		},
+		{
+			name:   "inspect not cached",
+			method: http.MethodGet,
+			path:   "/admin/cache/b",
+			token:  testAdminToken,
+			setup: func(admin *MockCacheAdmin) {
+				admin.EXPECT().Inspect("b").Return(cache.EntryInfo{}, false)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"cached": false`,
+		},
+		{
+			name:   "evict",
+			method: http.MethodDelete,
+			path:   "/admin/cache/a",
+			token:  testAdminToken,
+			setup: func(admin *MockCacheAdmin) {
+				admin.EXPECT().Remove("a").Return(true)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"evicted": true`,
+		},
+		{
+			name:   "purge",
+			method: http.MethodDelete,
+			path:   "/admin/cache",
+			token:  testAdminToken,
+			setup: func(admin *MockCacheAdmin) {
+				admin.EXPECT().Purge().Return(5)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"purged": 5`,
+		},
+		{
+			name:   "resize",
+			method: http.MethodPut,
+			path:   "/admin/cache/capacity",
+			body:   `{"capacity": 2}`,
+			token:  testAdminToken,
+			setup: func(admin *MockCacheAdmin) {
+				admin.EXPECT().Resize(2).Return(3, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"evicted": 3`,
+		},
+		{
+			name:   "resize invalid",
+			method: http.MethodPut,
+			path:   "/admin/cache/capacity",
+			body:   `{"capacity": 0}`,
+			token:  testAdminToken,
+			setup: func(admin *MockCacheAdmin) {
+				admin.EXPECT().Resize(0).Return(0, errors.New("cache capacity must be positive, got 0"))
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "must be positive",
+		},
+		{
+			name:           "resize bad json",
+			method:         http.MethodPut,
+			path:           "/admin/cache/capacity",
+			body:           `{"cap": 2}`,
+			token:          testAdminToken,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "bad json",
+		},
+		{
+			name:   "rewarm",
+			method: http.MethodPost,
+			path:   "/admin/cache/warm",
+			token:  testAdminToken,
+			setup: func(admin *MockCacheAdmin) {
+				admin.EXPECT().Rewarm().Return(true)
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   `"started": true`,
+		},
+		{
+			name:   "rewarm while running",
+			method: http.MethodPost,
+			path:   "/admin/cache/warm",
+			token:  testAdminToken,
+			setup: func(admin *MockCacheAdmin) {
+				admin.EXPECT().Rewarm().Return(false)
+			},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			admin := NewMockCacheAdmin(ctrl)
+			if tt.setup != nil {
+				tt.setup(admin)
+			}
+
+			server := New(NewMockServerWithStats(ctrl), zaptest.NewLogger(t), observability.NewNoop())
+			server.EnableAdmin(admin, testAdminToken)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Contains(t, w.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestServer_AdminDisabledWithoutToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	server := New(NewMockServerWithStats(ctrl), zaptest.NewLogger(t), observability.NewNoop())
+	server.EnableAdmin(NewMockCacheAdmin(ctrl), "")
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/cache", nil)
+	w := httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
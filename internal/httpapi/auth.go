@@ -0,0 +1,91 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/auth"
+)
+
+// EnableAuth requires callers of the order and admin endpoints to authenticate
+// with a, and checks their role per route: reader for lookups, writer for
+// upserts, admin for /admin. The admin endpoints then ignore the admin token.
+// Must be called before EnableAdmin and before the server starts.
+func (s *Server) EnableAuth(a auth.Authenticator) {
+	s.auth = a
+}
+
+// allow serves next to callers holding role once EnableAuth is called, and to
+// everyone before, within the rate limit of the role's route class (see
+// EnableLimits). The caller's identity is put on the request context.
+func (s *Server) allow(role auth.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.limitBody(w, r)
+		if s.auth == nil {
+			if s.rateLimit(w, r, role) {
+				next(w, r)
+			}
+			return
+		}
+		id, err := s.auth.Authenticate(r)
+		switch {
+		case errors.Is(err, auth.ErrNoCredentials):
+			s.refuse(w, r, http.StatusUnauthorized, "authentication required", zap.Error(err))
+			return
+		case err != nil:
+			s.refuse(w, r, http.StatusUnauthorized, "invalid credentials", zap.Error(err))
+			return
+		case !id.Role.Allows(role):
+			s.refuse(w, r, http.StatusForbidden, "role "+id.Role.String()+" may not do this, "+role.String()+" is required",
+				callerFields(id)...)
+			return
+		}
+		r = r.WithContext(auth.WithIdentity(r.Context(), id))
+		if s.rateLimit(w, r, role) {
+			next(w, r)
+		}
+	}
+}
+
+// refuse denies a request that failed authentication or authorization. Such
+// requests count against the reader rate limit of the client IP, so guessing
+// credentials or flooding without them is throttled like unauthenticated
+// reads; past the limit the answer is 429.
+func (s *Server) refuse(w http.ResponseWriter, r *http.Request, status int, msg string, fields ...zap.Field) {
+	if s.rateLimit(w, r, auth.RoleReader) {
+		s.deny(w, r, status, msg, fields...)
+	}
+}
+
+func (s *Server) deny(w http.ResponseWriter, r *http.Request, status int, msg string, fields ...zap.Field) {
+	s.logger.Warn("request denied", append(fields,
+		zap.Int("status", status),
+		zap.String("method", r.Method),
+		zap.String("path", r.URL.Path),
+		zap.String("remote", r.RemoteAddr),
+	)...)
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="orders"`)
+		writeError(w, status, codeUnauthenticated, msg)
+		return
+	}
+	writeError(w, status, codeForbidden, msg)
+}
+
+// log returns the server logger annotated with the caller of r, if known.
+func (s *Server) log(r *http.Request) *zap.Logger {
+	if id, ok := auth.FromContext(r.Context()); ok {
+		return s.logger.With(callerFields(id)...)
+	}
+	return s.logger
+}
+
+func callerFields(id auth.Identity) []zap.Field {
+	return []zap.Field{
+		zap.String("caller", id.Subject),
+		zap.String("caller_role", id.Role.String()),
+		zap.String("auth_method", id.Method),
+	}
+}
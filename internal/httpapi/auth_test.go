@@ -0,0 +1,185 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/auth"
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+const testJWTSecret = "0123456789abcdef0123456789abcdef"
+
+func TestServer_Auth(t *testing.T) {
+	authenticator, err := auth.New(config.Auth{
+		APIKeys: []config.APIKey{
+			{Name: "dashboard", Role: config.RoleReader, Hash: auth.HashAPIKey("reader-key")},
+			{Name: "ops", Role: config.RoleAdmin, Hash: auth.HashAPIKey("admin-key")},
+		},
+		JWTSecret: testJWTSecret,
+	})
+	require.NoError(t, err)
+
+	writerJWT, err := auth.SignJWT([]byte(testJWTSecret), auth.Claims{
+		Subject: "ingest", Role: config.RoleWriter, ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+	expiredJWT, err := auth.SignJWT([]byte(testJWTSecret), auth.Claims{
+		Subject: "ingest", Role: config.RoleWriter, ExpiresAt: time.Now().Add(-time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+
+	order := &domain.Order{OrderUID: "a", Items: []domain.Item{{ChrtID: 1}}}
+	upsertBody := `{"order_uid":"a","items":[{"chrt_id":1}]}`
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		header http.Header
+		setup  func(svc *MockServerWithStats, admin *MockCacheAdmin)
+		status int
+		code   string // structured error code
+	}{
+		{
+			name: "no credentials", method: http.MethodGet, path: "/api/v1/orders/a",
+			status: http.StatusUnauthorized, code: "unauthenticated",
+		},
+		{
+			name: "unknown api key", method: http.MethodGet, path: "/api/v1/orders/a",
+			header: http.Header{"X-Api-Key": {"guess"}},
+			status: http.StatusUnauthorized, code: "unauthenticated",
+		},
+		{
+			name: "expired token", method: http.MethodGet, path: "/api/v1/orders/a",
+			header: http.Header{"Authorization": {"Bearer " + expiredJWT}},
+			status: http.StatusUnauthorized, code: "unauthenticated",
+		},
+		{
+			name: "reader reads", method: http.MethodGet, path: "/api/v1/orders/a",
+			header: http.Header{"X-Api-Key": {"reader-key"}},
+			setup: func(svc *MockServerWithStats, _ *MockCacheAdmin) {
+				svc.EXPECT().GetByUIDWithStats(gomock.Any(), "a").Return(order, service.LookupStats{Source: service.SourceCache}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "reader reads a legacy path", method: http.MethodGet, path: "/order/a",
+			header: http.Header{"X-Api-Key": {"reader-key"}},
+			setup: func(svc *MockServerWithStats, _ *MockCacheAdmin) {
+				svc.EXPECT().GetByUIDWithStats(gomock.Any(), "a").Return(order, service.LookupStats{Source: service.SourceCache}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "reader may not write", method: http.MethodPost, path: "/api/v1/orders", body: upsertBody,
+			header: http.Header{"X-Api-Key": {"reader-key"}},
+			status: http.StatusForbidden, code: "forbidden",
+		},
+		{
+			name: "writer writes", method: http.MethodPost, path: "/api/v1/orders", body: upsertBody,
+			header: http.Header{"Authorization": {"Bearer " + writerJWT}},
+			setup: func(svc *MockServerWithStats, _ *MockCacheAdmin) {
+				svc.EXPECT().UpsertWithStats(gomock.Any(), gomock.Any()).Return(service.UpsertStats{}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "writer may not administer", method: http.MethodGet, path: "/admin/cache",
+			header: http.Header{"Authorization": {"Bearer " + writerJWT}},
+			status: http.StatusForbidden, code: "forbidden",
+		},
+		{
+			name: "admin token is not accepted", method: http.MethodGet, path: "/admin/cache",
+			header: http.Header{"Authorization": {"Bearer " + testAdminToken}},
+			status: http.StatusUnauthorized, code: "unauthenticated",
+		},
+		{
+			name: "admin administers", method: http.MethodDelete, path: "/admin/cache",
+			header: http.Header{"X-Api-Key": {"admin-key"}},
+			setup: func(_ *MockServerWithStats, admin *MockCacheAdmin) {
+				admin.EXPECT().Purge().Return(2)
+			},
+			status: http.StatusOK,
+		},
+		{name: "readiness is open", method: http.MethodGet, path: "/readyz", status: http.StatusOK},
+		{name: "docs are open", method: http.MethodGet, path: "/openapi.json", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			admin := NewMockCacheAdmin(ctrl)
+			if tt.setup != nil {
+				tt.setup(svc, admin)
+			}
+
+			s := New(svc, zap.NewNop(), observability.NewNoop())
+			s.EnableAuth(authenticator)
+			s.EnableAdmin(admin, testAdminToken)
+			require.NoError(t, s.EnableContractValidation(func(r *http.Request, err error) {
+				t.Errorf("%s %s: %v", r.Method, r.URL, err)
+			}))
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.code != "" {
+				require.Contains(t, w.Body.String(), `"code": "`+tt.code+`"`)
+			}
+			if tt.status == http.StatusUnauthorized {
+				require.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestServer_AuthLogsCaller(t *testing.T) {
+	authenticator, err := auth.New(config.Auth{
+		APIKeys: []config.APIKey{{Name: "ops", Role: config.RoleAdmin, Hash: auth.HashAPIKey("admin-key")}},
+	})
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	admin := NewMockCacheAdmin(ctrl)
+	admin.EXPECT().Remove("a").Return(true)
+
+	core, logs := observer.New(zap.InfoLevel)
+	s := New(NewMockServerWithStats(ctrl), zap.New(core), observability.NewNoop())
+	s.EnableAuth(authenticator)
+	s.EnableAdmin(admin, "")
+
+	req := httptest.NewRequest(http.MethodDelete, "/admin/cache/a", nil)
+	req.Header.Set(auth.APIKeyHeader, "admin-key")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	entries := logs.FilterMessage("admin: cache entry evicted").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	require.Equal(t, "ops", fields["caller"])
+	require.Equal(t, "admin", fields["caller_role"])
+	require.Equal(t, "api_key", fields["auth_method"])
+}
@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+// defaultBatchMaxUIDs applies when EnableLimits sets no BatchMaxUIDs.
+const defaultBatchMaxUIDs = 100
+
+type batchGetRequest struct {
+	UIDs []string `json:"uids"`
+}
+
+type batchGetResponse struct {
+	Orders  []batchOrder `json:"orders"`
+	Missing []string     `json:"missing"`
+}
+
+type batchOrder struct {
+	Source service.LookupSource `json:"source"`
+	Order  *domain.Order        `json:"order"`
+}
+
+// batchGetOrders serves POST /api/v1/orders:batchGet: the orders found among
+// the requested UIDs, each with where it came from, and the UIDs not found.
+func (s *Server) batchGetOrders(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "application/json") {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var req batchGetRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		if bodyTooLarge(w, err) {
+			return
+		}
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	maxUIDs := s.limits.BatchMaxUIDs
+	if maxUIDs <= 0 {
+		maxUIDs = defaultBatchMaxUIDs
+	}
+	switch {
+	case len(req.UIDs) == 0:
+		http.Error(w, "uids required", http.StatusBadRequest)
+		return
+	case len(req.UIDs) > maxUIDs:
+		http.Error(w, "at most "+strconv.Itoa(maxUIDs)+" uids per request", http.StatusBadRequest)
+		return
+	}
+	for _, uid := range req.UIDs {
+		if uid == "" {
+			http.Error(w, "uids must not be empty", http.StatusBadRequest)
+			return
+		}
+	}
+
+	items, missing, st, err := s.service.GetByUIDsWithStats(r.Context(), req.UIDs)
+	switch {
+	case errors.Is(err, service.ErrStorageUnavailable):
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "storage unavailable", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		s.log(r).Error("batch lookup failed", zap.Int("uids", len(req.UIDs)), zap.Error(err))
+		http.Error(w, "Service error", http.StatusInternalServerError)
+		return
+	}
+
+	resp := batchGetResponse{Orders: make([]batchOrder, len(items)), Missing: missing}
+	for i, item := range items {
+		resp.Orders[i] = batchOrder{Source: item.Source, Order: item.Order}
+	}
+	if resp.Missing == nil {
+		resp.Missing = []string{}
+	}
+
+	observability.AppendServerTiming(w, "cache", st.CacheMs, "")
+	observability.AppendServerTiming(w, "db", st.DBMs, "")
+	writeJSON(w, resp)
+}
@@ -0,0 +1,96 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+func TestServer_BatchGetOrders(t *testing.T) {
+	a, b := &domain.Order{OrderUID: "a"}, &domain.Order{OrderUID: "b"}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		setup       func(svc *MockServerWithStats)
+		status      int
+		want        string
+	}{
+		{
+			name: "found and missing", body: `{"uids":["a","b","gone"]}`,
+			setup: func(svc *MockServerWithStats) {
+				svc.EXPECT().GetByUIDsWithStats(gomock.Any(), []string{"a", "b", "gone"}).Return(
+					[]service.BatchItem{{Order: a, Source: service.SourceCache}, {Order: b, Source: service.SourceDB}},
+					[]string{"gone"}, service.BatchStats{CacheHits: 1, DBLoads: 1}, nil)
+			},
+			status: http.StatusOK,
+			want:   `"source": "db"`,
+		},
+		{
+			name: "none found", body: `{"uids":["gone"]}`,
+			setup: func(svc *MockServerWithStats) {
+				svc.EXPECT().GetByUIDsWithStats(gomock.Any(), []string{"gone"}).
+					Return(nil, []string{"gone"}, service.BatchStats{}, nil)
+			},
+			status: http.StatusOK,
+			want:   `"orders": []`,
+		},
+		{name: "no uids", body: `{"uids":[]}`, status: http.StatusBadRequest, want: "uids required"},
+		{name: "too many uids", body: `{"uids":["a","b","c","d"]}`, status: http.StatusBadRequest, want: "at most 3 uids"},
+		{name: "empty uid", body: `{"uids":["a",""]}`, status: http.StatusBadRequest},
+		{name: "unknown field", body: `{"ids":["a"]}`, status: http.StatusBadRequest},
+		{name: "not json", contentType: "text/plain", body: `{"uids":["a"]}`, status: http.StatusUnsupportedMediaType},
+		{
+			name: "storage unavailable", body: `{"uids":["a"]}`,
+			setup: func(svc *MockServerWithStats) {
+				svc.EXPECT().GetByUIDsWithStats(gomock.Any(), []string{"a"}).
+					Return(nil, nil, service.BatchStats{}, service.ErrStorageUnavailable)
+			},
+			status: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			if tt.setup != nil {
+				tt.setup(svc)
+			}
+			s := New(svc, zaptest.NewLogger(t), observability.NewNoop())
+			s.EnableLimits(config.HTTP{BatchMaxUIDs: 3})
+			validateRequests := tt.status != http.StatusBadRequest && tt.status != http.StatusUnsupportedMediaType
+			require.NoError(t, s.EnableContractValidation(func(r *http.Request, err error) {
+				if validateRequests || !strings.HasPrefix(err.Error(), "request:") {
+					t.Errorf("%s %s: %v", r.Method, r.URL, err)
+				}
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders:batchGet", strings.NewReader(tt.body))
+			ct := tt.contentType
+			if ct == "" {
+				ct = "application/json"
+			}
+			req.Header.Set("Content-Type", ct)
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			require.Contains(t, w.Body.String(), tt.want)
+			if tt.status == http.StatusOK {
+				require.Contains(t, w.Body.String(), `"missing": [`)
+			}
+		})
+	}
+}
@@ -0,0 +1,315 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+)
+
+// Content codings EnableCompression can offer. HTTP's deflate is zlib-wrapped
+// DEFLATE (RFC 9110 section 8.4.1.2).
+const (
+	codingZstd    = "zstd"
+	codingGzip    = "gzip"
+	codingDeflate = "deflate"
+)
+
+// encoder is what the gzip, zlib and zstd writers have in common.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var newEncoder = map[string]func() encoder{
+	codingZstd: func() encoder {
+		// Browsers refuse windows over 8 MiB; orders are far smaller anyway.
+		e, _ := zstd.NewWriter(nil,
+			zstd.WithEncoderLevel(zstd.SpeedDefault),
+			zstd.WithEncoderConcurrency(1),
+			zstd.WithWindowSize(1<<20),
+		)
+		return e
+	},
+	codingGzip:    func() encoder { return gzip.NewWriter(nil) },
+	codingDeflate: func() encoder { return zlib.NewWriter(nil) },
+}
+
+// compressor negotiates a content coding per request and encodes response
+// bodies with pooled encoders.
+type compressor struct {
+	codings  []string // preferred first
+	minBytes int
+	pools    map[string]*sync.Pool
+}
+
+type codingCtxKey struct{}
+
+// EnableCompression compresses responses in the coding of cfg.Encodings the
+// client accepts with the highest quality, preferring earlier ones on ties.
+// Bodies under cfg.MinBytes and responses a handler already encoded are sent
+// as is. Must be called before the server starts.
+func (s *Server) EnableCompression(cfg config.Compression) error {
+	s.compression = nil
+	if !cfg.Enabled {
+		return nil
+	}
+	c := &compressor{minBytes: cfg.MinBytes, pools: make(map[string]*sync.Pool)}
+	for _, coding := range cfg.Encodings {
+		newEnc, ok := newEncoder[coding]
+		if !ok {
+			return fmt.Errorf("compression: unsupported coding %q (want %s, %s or %s)",
+				coding, codingZstd, codingGzip, codingDeflate)
+		}
+		if c.pools[coding] != nil {
+			continue
+		}
+		c.codings = append(c.codings, coding)
+		c.pools[coding] = &sync.Pool{New: func() any { return newEnc() }}
+	}
+	s.compression = c
+	return nil
+}
+
+func (c *compressor) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var coding string
+		if r.Method != http.MethodHead {
+			coding = c.negotiate(r.Header.Get("Accept-Encoding"))
+		}
+		if coding != "" {
+			r = r.WithContext(context.WithValue(r.Context(), codingCtxKey{}, coding))
+		}
+		cw := &compressWriter{ResponseWriter: w, c: c, coding: coding}
+		next.ServeHTTP(cw, r)
+		cw.close()
+	})
+}
+
+func (c *compressor) negotiate(header string) string {
+	accepted := acceptEncoding(header)
+	best, bestQ := "", 0.0
+	for _, coding := range c.codings {
+		if q := quality(accepted, coding); q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// responseCoding is the coding the response to r will be compressed in if it
+// is large enough, "" if none. Handlers that set an ETag tell codings apart
+// with it.
+func responseCoding(r *http.Request) string {
+	coding, _ := r.Context().Value(codingCtxKey{}).(string)
+	return coding
+}
+
+// acceptEncoding reads the quality of each coding in an Accept-Encoding
+// header, "*" included.
+func acceptEncoding(header string) map[string]float64 {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		if coding == "x-gzip" {
+			coding = codingGzip
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+		accepted[coding] = q
+	}
+	return accepted
+}
+
+// quality is the quality the client gives coding, directly or through "*"; 0
+// means not acceptable.
+func quality(accepted map[string]float64, coding string) float64 {
+	if q, ok := accepted[coding]; ok {
+		return q
+	}
+	return accepted["*"]
+}
+
+// compressWriter holds the header back until it knows whether to compress:
+// when the body reaches minBytes, is flushed, or ends. Compressed bodies are
+// streamed through the encoder rather than buffered.
+type compressWriter struct {
+	http.ResponseWriter
+	c      *compressor
+	coding string // negotiated, "" = identity
+
+	status  int
+	pending bool   // header held back, body in buf
+	buf     []byte // at most minBytes
+	enc     encoder
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+	h := w.Header()
+	if h.Get("Content-Encoding") == "" && (h.Get("Content-Type") == "" || compressibleType(h.Get("Content-Type"))) {
+		addVary(h, "Accept-Encoding")
+	}
+	if w.coding == "" || !w.compressible() {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < w.c.minBytes {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.pending = true
+}
+
+// compressible reports whether the response may be encoded at all.
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	switch {
+	case w.status == http.StatusNoContent, w.status == http.StatusNotModified, w.status == http.StatusPartialContent:
+		return false
+	case h.Get("Content-Encoding") != "", h.Get("Content-Range") != "":
+		return false
+	}
+	return compressibleType(h.Get("Content-Type"))
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		// Sniff like net/http would, since the type decides compression.
+		h := w.Header()
+		if _, ok := h["Content-Type"]; !ok && h.Get("Content-Encoding") == "" && len(p) > 0 {
+			h.Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	switch {
+	case w.enc != nil:
+		return w.enc.Write(p)
+	case !w.pending:
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.c.minBytes {
+		if err := w.startEncoding(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// startEncoding sends the held back header for an encoded body and passes the
+// buffered part through the encoder.
+func (w *compressWriter) startEncoding() error {
+	h := w.Header()
+	h.Set("Content-Encoding", w.coding)
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	// A strong ETag names exact bytes. Handlers that know the coding put it in
+	// the tag; weaken the tags of those that do not.
+	if tag := h.Get("ETag"); strings.HasPrefix(tag, `"`) && !strings.HasSuffix(tag, "-"+w.coding+`"`) {
+		h.Set("ETag", "W/"+tag)
+	}
+	w.pending = false
+	w.enc = w.c.pools[w.coding].Get().(encoder)
+	w.enc.Reset(w.ResponseWriter)
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	_, err := w.enc.Write(buf)
+	return err
+}
+
+// Flush sends what has been written so far, committing a held back response to
+// compression since a flushing handler is streaming.
+func (w *compressWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.pending {
+		_ = w.startEncoding()
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// close finishes the response once the handler returns: a body that stayed
+// under minBytes goes out as is.
+func (w *compressWriter) close() {
+	if w.pending {
+		w.pending = false
+		w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		w.ResponseWriter.WriteHeader(w.status)
+		_, _ = w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		w.enc.Reset(nil)
+		w.c.pools[w.coding].Put(w.enc)
+		w.enc = nil
+	}
+}
+
+// compressibleType reports whether a media type is text that compresses well.
+func compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// addVary adds a field to the Vary header unless it is listed already.
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if f = strings.TrimSpace(f); f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
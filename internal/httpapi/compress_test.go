@@ -0,0 +1,240 @@
+package httpapi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zlib"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+var testCompression = config.Compression{Enabled: true, Encodings: []string{"zstd", "gzip", "deflate"}, MinBytes: 256}
+
+func decode(t *testing.T, coding string, body []byte) []byte {
+	t.Helper()
+	var r io.Reader
+	var err error
+	switch coding {
+	case "":
+		return body
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	case "zstd":
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(bytes.NewReader(body))
+		r = d
+	default:
+		t.Fatalf("unexpected coding %q", coding)
+	}
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return out
+}
+
+func TestServer_Compression(t *testing.T) {
+	order := benchOrder()
+	order.Version = 3
+
+	tests := []struct {
+		name     string
+		accept   string
+		minBytes int
+		coding   string
+		etag     string
+	}{
+		{name: "no accept-encoding", etag: `"3"`},
+		{name: "gzip", accept: "gzip", coding: "gzip", etag: `"3-gzip"`},
+		{name: "deflate", accept: "deflate", coding: "deflate", etag: `"3-deflate"`},
+		{name: "server preference on ties", accept: "gzip, deflate, zstd", coding: "zstd", etag: `"3-zstd"`},
+		{name: "client quality first", accept: "zstd;q=0.5, gzip", coding: "gzip", etag: `"3-gzip"`},
+		{name: "wildcard", accept: "*", coding: "zstd", etag: `"3-zstd"`},
+		{name: "nothing offered", accept: "br, zstd;q=0", etag: `"3"`},
+		// The tag names the negotiated coding: the same request always gets
+		// the same bytes, compressed or not.
+		{name: "under the threshold", accept: "gzip", minBytes: 1 << 20, etag: `"3-gzip"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			svc.EXPECT().GetByUIDWithStats(gomock.Any(), order.OrderUID).
+				Return(order, service.LookupStats{Source: service.SourceCache, CacheMs: 1}, nil)
+			s := New(svc, zaptest.NewLogger(t), observability.NewNoop())
+			cfg := testCompression
+			if tt.minBytes > 0 {
+				cfg.MinBytes = tt.minBytes
+			}
+			require.NoError(t, s.EnableCompression(cfg))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+order.OrderUID, nil)
+			req.Header.Set("Accept-Encoding", tt.accept)
+			w := httptest.NewRecorder()
+			ServerTimingApp(observability.NewNoop())(s.Handler()).ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, []string{"Accept-Encoding"}, w.Header().Values("Vary"))
+			require.Equal(t, tt.coding, w.Header().Get("Content-Encoding"))
+			timing := strings.Join(w.Header().Values("Server-Timing"), ", ")
+			require.Contains(t, timing, "cache;dur=")
+			require.Contains(t, timing, "app;dur=")
+
+			require.Equal(t, tt.etag, w.Header().Get("ETag"))
+
+			body := decode(t, tt.coding, w.Body.Bytes())
+			require.Contains(t, string(body), `"order_uid": "`+order.OrderUID+`"`)
+			if tt.coding != "" {
+				require.Empty(t, w.Header().Get("Content-Length"))
+				require.Less(t, w.Body.Len(), len(body))
+			}
+		})
+	}
+}
+
+func TestServer_CompressionNotModified(t *testing.T) {
+	order := benchOrder()
+	order.Version = 3
+	ctrl := gomock.NewController(t)
+	svc := NewMockServerWithStats(ctrl)
+	svc.EXPECT().GetByUIDWithStats(gomock.Any(), order.OrderUID).
+		Return(order, service.LookupStats{Source: service.SourceCache}, nil)
+	s := New(svc, zaptest.NewLogger(t), observability.NewNoop())
+	require.NoError(t, s.EnableCompression(testCompression))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/"+order.OrderUID, nil)
+	req.Header.Set("Accept-Encoding", "zstd")
+	req.Header.Set("If-None-Match", `"3-zstd"`)
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusNotModified, w.Code)
+	require.Equal(t, `"3-zstd"`, w.Header().Get("ETag"))
+	require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	require.Empty(t, w.Header().Get("Content-Encoding"))
+	require.Empty(t, w.Body.Bytes())
+}
+
+func TestCompressWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		coding  string
+		vary    bool
+		etag    string
+	}{
+		{
+			name: "stream is compressed from the first flush",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = io.WriteString(w, "data: 1\n\n")
+				w.(http.Flusher).Flush()
+				_, _ = io.WriteString(w, "data: 2\n\n")
+			},
+			coding: "gzip", vary: true,
+		},
+		{
+			name: "sniffed type",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = io.WriteString(w, "<!DOCTYPE html>"+strings.Repeat("<p>order</p>", 100))
+			},
+			coding: "gzip", vary: true,
+		},
+		{
+			name: "foreign strong etag is weakened",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("ETag", `"abc"`)
+				_, _ = io.WriteString(w, strings.Repeat(`{"a":1}`, 100))
+			},
+			coding: "gzip", vary: true, etag: `W/"abc"`,
+		},
+		{
+			name: "declared short body",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", "2")
+				_, _ = io.WriteString(w, "{}")
+			},
+			vary: true,
+		},
+		{
+			name: "already encoded",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", "br")
+				_, _ = w.Write(bytes.Repeat([]byte{1}, 1000))
+			},
+			coding: "br",
+		},
+		{
+			name: "binary",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				_, _ = w.Write(bytes.Repeat([]byte{1}, 1000))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Server{}
+			require.NoError(t, c.EnableCompression(testCompression))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			c.compression.middleware(tt.handler).ServeHTTP(w, req)
+
+			require.Equal(t, tt.coding, w.Header().Get("Content-Encoding"))
+			require.Equal(t, tt.vary, w.Header().Get("Vary") == "Accept-Encoding")
+			require.Equal(t, tt.etag, w.Header().Get("ETag"))
+			if tt.coding == "gzip" {
+				require.NotEmpty(t, decode(t, "gzip", w.Body.Bytes()))
+			}
+		})
+	}
+}
+
+func TestCompressWriter_FlushSendsData(t *testing.T) {
+	s := &Server{}
+	require.NoError(t, s.EnableCompression(testCompression))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+
+	s.compression.middleware(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/event-stream")
+		_, _ = io.WriteString(rw, "data: 1\n\n")
+		require.NoError(t, http.NewResponseController(rw).Flush())
+
+		// What was flushed decodes before the stream ends.
+		require.True(t, w.Flushed)
+		zr, err := gzip.NewReader(bytes.NewReader(w.Body.Bytes()))
+		require.NoError(t, err)
+		got := make([]byte, len("data: 1\n\n"))
+		_, err = io.ReadFull(zr, got)
+		require.NoError(t, err)
+		require.Equal(t, "data: 1\n\n", string(got))
+	})).ServeHTTP(w, req)
+}
+
+func TestEnableCompression(t *testing.T) {
+	s := &Server{}
+	require.ErrorContains(t, s.EnableCompression(config.Compression{Enabled: true, Encodings: []string{"br"}}), `unsupported coding "br"`)
+	require.NoError(t, s.EnableCompression(config.Compression{}))
+	require.Nil(t, s.compression)
+}
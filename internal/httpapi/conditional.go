@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+// EnableCacheControl sets the Cache-Control header of successful responses by
+// route pattern (see config.HTTP.CacheControl). Must be called before the
+// server starts.
+func (s *Server) EnableCacheControl(byRoute map[string]string) {
+	s.cacheControl = byRoute
+}
+
+func (s *Server) setCacheControl(w http.ResponseWriter, r *http.Request) {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil {
+		return
+	}
+	if cc := s.cacheControl[rctx.RoutePattern()]; cc != "" {
+		w.Header().Set("Cache-Control", cc)
+	}
+}
+
+// etag is the strong entity tag of an order version, told apart by the content
+// coding of the body since encoded bodies differ byte for byte. Orders without
+// a version (not yet flushed by write-behind) have none.
+func etag(version int64, coding string) string {
+	if version <= 0 {
+		return ""
+	}
+	tag := `"` + strconv.FormatInt(version, 10)
+	if coding != "" {
+		tag += "-" + coding
+	}
+	return tag + `"`
+}
+
+// writeValidators sets the ETag, Last-Modified and Cache-Control headers of an
+// order response. If the request's preconditions show the client already has
+// this version, it answers 304 and returns true.
+func (s *Server) writeValidators(w http.ResponseWriter, r *http.Request, version int64, updated *time.Time, coding string) bool {
+	s.setCacheControl(w, r)
+	tag := etag(version, coding)
+	if tag != "" {
+		w.Header().Set("ETag", tag)
+	}
+	if updated != nil {
+		w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat))
+	}
+	if !notModified(r, tag, updated) {
+		return false
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when it is absent,
+// as RFC 9110 section 13.2.2 orders them.
+func notModified(r *http.Request, tag string, updated *time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return tag != "" && matchETag(inm, tag, false)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || updated == nil {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// Last-Modified has a resolution of one second.
+	return !updated.Truncate(time.Second).After(since)
+}
+
+// matchETag reports whether a list of entity tags from If-Match or
+// If-None-Match contains tag or "*". The strong comparison If-Match needs
+// ignores weak tags; the weak one If-None-Match uses ignores the W/ prefix.
+func matchETag(list, tag string, strong bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak, ok := strings.CutPrefix(candidate, "W/"); ok {
+			if strong {
+				continue
+			}
+			candidate = weak
+		}
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// tagCodings are the content codings entity tags are told apart by.
+var tagCodings = []string{"", codingGzip, codingDeflate, codingZstd}
+
+// precondition turns an If-Match header into the versions an upsert may
+// replace. The strong tags of a version in any coding name it; weak tags never
+// match, and neither does anything that is not one of our tags.
+func precondition(header string) domain.Precondition {
+	var p domain.Precondition
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			p.Any = true
+			continue
+		}
+		tag, ok := strings.CutPrefix(candidate, `"`)
+		if !ok {
+			continue
+		}
+		tag, ok = strings.CutSuffix(tag, `"`)
+		if !ok {
+			continue
+		}
+		version, coding, _ := strings.Cut(tag, "-")
+		n, err := strconv.ParseInt(version, 10, 64)
+		if err != nil || etag(n, coding) != candidate || !slices.Contains(tagCodings, coding) {
+			continue
+		}
+		p.Versions = append(p.Versions, n)
+	}
+	return p
+}
@@ -0,0 +1,164 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+func conditionalServer(t *testing.T, svc ServerWithStats) *Server {
+	t.Helper()
+	s := New(svc, zaptest.NewLogger(t), observability.NewNoop())
+	s.EnableCacheControl(map[string]string{"/api/v1/orders/{uid}": "private, no-cache"})
+	require.NoError(t, s.EnableContractValidation(func(r *http.Request, err error) {
+		t.Errorf("%s %s: %v", r.Method, r.URL, err)
+	}))
+	return s
+}
+
+func TestServer_ConditionalGet(t *testing.T) {
+	updated := time.Date(2025, time.March, 1, 12, 0, 0, 500_000_000, time.UTC)
+	lastModified := "Sat, 01 Mar 2025 12:00:00 GMT"
+
+	tests := []struct {
+		name    string
+		encoded bool
+		gzip    bool
+		header  http.Header
+		status  int
+		etag    string
+	}{
+		{name: "no preconditions", encoded: true, status: http.StatusOK, etag: `"3"`},
+		{name: "etag matches", encoded: true, header: http.Header{"If-None-Match": {`"3"`}}, status: http.StatusNotModified, etag: `"3"`},
+		{name: "weak etag matches", encoded: true, header: http.Header{"If-None-Match": {`"1", W/"3"`}}, status: http.StatusNotModified, etag: `"3"`},
+		{name: "any etag", encoded: true, header: http.Header{"If-None-Match": {"*"}}, status: http.StatusNotModified, etag: `"3"`},
+		{name: "older etag", encoded: true, header: http.Header{"If-None-Match": {`"2"`}}, status: http.StatusOK, etag: `"3"`},
+		{
+			name: "gzip has its own etag", encoded: true, gzip: true,
+			header: http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {`"3"`}}, status: http.StatusOK, etag: `"3-gzip"`,
+		},
+		{
+			name: "gzip etag matches", encoded: true, gzip: true,
+			header: http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {`"3-gzip"`}}, status: http.StatusNotModified, etag: `"3-gzip"`,
+		},
+		{name: "not modified since", encoded: true, header: http.Header{"If-Modified-Since": {lastModified}}, status: http.StatusNotModified, etag: `"3"`},
+		{
+			name: "modified since", encoded: true, header: http.Header{"If-Modified-Since": {"Sat, 01 Mar 2025 11:59:59 GMT"}},
+			status: http.StatusOK, etag: `"3"`,
+		},
+		{
+			name: "if-none-match takes precedence", encoded: true,
+			header: http.Header{"If-None-Match": {`"2"`}, "If-Modified-Since": {lastModified}}, status: http.StatusOK, etag: `"3"`,
+		},
+		{name: "decoded order", header: http.Header{"If-None-Match": {`"3"`}}, status: http.StatusNotModified, etag: `"3"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			st := service.LookupStats{Source: service.SourceCache}
+			s := conditionalServer(t, svc)
+			if tt.encoded {
+				body := []byte("{\n  \"order_uid\": \"a\"\n}\n")
+				if tt.gzip {
+					var buf bytes.Buffer
+					zw := gzip.NewWriter(&buf)
+					_, _ = zw.Write(body)
+					require.NoError(t, zw.Close())
+					body = buf.Bytes()
+				}
+				reader := NewMockEncodedReader(ctrl)
+				reader.EXPECT().GetEncodedWithStats("a", tt.gzip).
+					Return(service.EncodedOrder{Body: body, Gzip: tt.gzip, Version: 3, UpdatedAt: &updated}, st, true)
+				s.EnableEncodedReads(reader)
+			} else {
+				svc.EXPECT().GetByUIDWithStats(gomock.Any(), "a").
+					Return(&domain.Order{OrderUID: "a", Version: 3, UpdatedAt: &updated}, st, nil)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/a", nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, tt.etag, w.Header().Get("ETag"))
+			require.Equal(t, lastModified, w.Header().Get("Last-Modified"))
+			require.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+			if tt.status == http.StatusNotModified {
+				require.Empty(t, w.Body.String())
+			} else {
+				require.NotEmpty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_UpsertIfMatch(t *testing.T) {
+	updated := time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    domain.Precondition // passed to storage
+		upsert  error
+		status  int
+	}{
+		{name: "current version", ifMatch: `"3"`, want: domain.Precondition{Versions: []int64{3}}, status: http.StatusOK},
+		{name: "gzip tag of a version", ifMatch: `"2", "3-gzip"`, want: domain.Precondition{Versions: []int64{2, 3}}, status: http.StatusOK},
+		{name: "any existing order", ifMatch: "*", want: domain.Precondition{Any: true}, status: http.StatusOK},
+		{name: "lost update", ifMatch: `"2"`, want: domain.Precondition{Versions: []int64{2}},
+			upsert: domain.ErrVersionMismatch, status: http.StatusPreconditionFailed},
+		{name: "weak and foreign tags never match", ifMatch: `W/"3", "x", "3-br", "03"`,
+			upsert: domain.ErrVersionMismatch, status: http.StatusPreconditionFailed},
+		{name: "storage error", ifMatch: `"3"`, want: domain.Precondition{Versions: []int64{3}},
+			upsert: errors.New("boom"), status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			svc.EXPECT().UpsertIfWithStats(gomock.Any(), gomock.Any(), tt.want).DoAndReturn(
+				func(_ context.Context, o *domain.Order, _ domain.Precondition) (service.UpsertStats, error) {
+					if tt.upsert != nil {
+						return service.UpsertStats{}, tt.upsert
+					}
+					o.Version, o.UpdatedAt = 4, &updated
+					return service.UpsertStats{}, nil
+				})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(`{"order_uid":"a"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("If-Match", tt.ifMatch)
+			w := httptest.NewRecorder()
+			conditionalServer(t, svc).Handler().ServeHTTP(w, req)
+
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			switch tt.status {
+			case http.StatusOK:
+				require.Equal(t, `"4"`, w.Header().Get("ETag"))
+				require.Equal(t, "Sat, 01 Mar 2025 12:00:00 GMT", w.Header().Get("Last-Modified"))
+			case http.StatusPreconditionFailed:
+				require.Contains(t, w.Body.String(), `"code": "precondition_failed"`)
+			}
+		})
+	}
+}
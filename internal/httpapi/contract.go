@@ -0,0 +1,358 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// EnableContractValidation checks every request and response of the server
+// against the OpenAPI document and passes each mismatch to report; requests are
+// served as usual either way. It is meant for tests: bodies are buffered in full.
+// Must be called before the server starts.
+func (s *Server) EnableContractValidation(report func(r *http.Request, err error)) error {
+	spec, err := LoadSpec()
+	if err != nil {
+		return err
+	}
+	s.contract = ValidateContract(spec, report)
+	return nil
+}
+
+// ValidateContract is middleware validating requests and responses against spec.
+func ValidateContract(spec *Spec, report func(r *http.Request, err error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "can't read body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			if err := spec.ValidateRequest(r, body); err != nil {
+				report(r, fmt.Errorf("request: %w", err))
+			}
+
+			rec := &contractRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			if err := spec.ValidateResponse(r, rec.status, w.Header(), rec.body.Bytes()); err != nil {
+				report(r, fmt.Errorf("response %d: %w", rec.status, err))
+			}
+		})
+	}
+}
+
+// contractRecorder keeps a copy of the response it passes on.
+type contractRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *contractRecorder) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *contractRecorder) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	c.body.Write(p)
+	return c.ResponseWriter.Write(p)
+}
+
+func (c *contractRecorder) Flush() {
+	if f, ok := c.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (c *contractRecorder) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// ValidateRequest checks the parameters and body of r against its operation.
+func (sp *Spec) ValidateRequest(r *http.Request, body []byte) error {
+	op, pathParams, err := sp.find(r.Method, r.URL.Path)
+	if err != nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		p, err := sp.parameter(p)
+		if err != nil {
+			return err
+		}
+		var (
+			value string
+			ok    bool
+		)
+		switch p.In {
+		case "path":
+			value, ok = pathParams[p.Name]
+		case "query":
+			ok = query.Has(p.Name)
+			value = query.Get(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			ok = value != ""
+		}
+		if !ok {
+			if p.Required {
+				return fmt.Errorf("%s parameter %q is required", p.In, p.Name)
+			}
+			continue
+		}
+		if err := sp.validateParam(p, value); err != nil {
+			return err
+		}
+	}
+
+	if op.RequestBody == nil {
+		return nil
+	}
+	if len(body) == 0 {
+		if op.RequestBody.Required {
+			return fmt.Errorf("body is required")
+		}
+		return nil
+	}
+	return sp.validateContent(op.RequestBody.Content, r.Header, body)
+}
+
+// ValidateResponse checks that status is documented for the operation serving r
+// and that the body matches its content type and schema.
+func (sp *Spec) ValidateResponse(r *http.Request, status int, h http.Header, body []byte) error {
+	op, _, err := sp.find(r.Method, r.URL.Path)
+	if err != nil {
+		return err
+	}
+	code := strconv.Itoa(status)
+	resp := op.Responses[code]
+	if resp == nil {
+		resp = op.Responses[code[:1]+"XX"]
+	}
+	if resp == nil {
+		resp = op.Responses["default"]
+	}
+	if resp == nil {
+		return fmt.Errorf("status is not documented")
+	}
+	if resp, err = sp.response(resp); err != nil {
+		return err
+	}
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("body is not documented")
+		}
+		return nil
+	}
+
+	if h.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("gzip body: %w", err)
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return fmt.Errorf("gzip body: %w", err)
+		}
+	}
+	return sp.validateContent(resp.Content, h, body)
+}
+
+// validateContent checks the body against the schema of its Content-Type. Only
+// JSON bodies are checked against a schema.
+func (sp *Spec) validateContent(content map[string]*mediaType, h http.Header, body []byte) error {
+	ct, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("content type %q: %w", h.Get("Content-Type"), err)
+	}
+	mt, ok := content[ct]
+	if !ok {
+		return fmt.Errorf("content type %q is not documented", ct)
+	}
+	if mt == nil || mt.Schema == nil || ct != "application/json" {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+	if err := sp.validate(mt.Schema, v, "body"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateParam converts a parameter to the type of its schema and validates it.
+func (sp *Spec) validateParam(p *parameter, value string) error {
+	at := p.In + " parameter " + strconv.Quote(p.Name)
+	s, err := sp.schema(p.Schema)
+	if err != nil || s == nil {
+		return err
+	}
+	var v any = value
+	switch s.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s: %q is not a number", at, value)
+		}
+		v = json.Number(value)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a boolean", at, value)
+		}
+		v = b
+	}
+	return sp.validate(s, v, at)
+}
+
+// validate checks a decoded JSON value, with numbers as json.Number, against s.
+func (sp *Spec) validate(s *schema, v any, at string) error {
+	s, err := sp.schema(s)
+	if err != nil || s == nil {
+		return err
+	}
+	if v == nil {
+		if s.Nullable || (s.Type == "" && len(s.OneOf) == 0) {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", at)
+	}
+
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, alt := range s.OneOf {
+			if sp.validate(alt, v, at) == nil {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Errorf("%s: matches %d of the oneOf schemas, want 1", at, matched)
+		}
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(v) }) {
+		return fmt.Errorf("%s: %v is not one of %v", at, v, s.Enum)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "object":
+		return sp.validateObject(s, v, at)
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: want array, got %T", at, v)
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			return fmt.Errorf("%s: %d items, want at least %d", at, len(items), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			return fmt.Errorf("%s: %d items, want at most %d", at, len(items), *s.MaxItems)
+		}
+		for i, item := range items {
+			if err := sp.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s: want string, got %T", at, v)
+		}
+		if s.MinLength != nil && utf8.RuneCountInString(str) < *s.MinLength {
+			return fmt.Errorf("%s: shorter than %d", at, *s.MinLength)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return fmt.Errorf("%s: %q is not a date-time", at, str)
+			}
+		}
+		return nil
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: want %s, got %T", at, s.Type, v)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: %w", at, err)
+		}
+		if s.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return fmt.Errorf("%s: %s is not an integer", at, n)
+			}
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s: %s is less than %v", at, n, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: %s is greater than %v", at, n, *s.Maximum)
+		}
+		return nil
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: want boolean, got %T", at, v)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%s: unsupported schema type %q", at, s.Type)
+	}
+}
+
+func (sp *Spec) validateObject(s *schema, v any, at string) error {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return fmt.Errorf("%s: want object, got %T", at, v)
+	}
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: property %q is required", at, name)
+		}
+	}
+
+	var extra *schema
+	closed := string(s.AdditionalProperties) == "false"
+	if len(s.AdditionalProperties) > 0 && !closed {
+		if err := json.Unmarshal(s.AdditionalProperties, &extra); err != nil {
+			return fmt.Errorf("%s: additionalProperties: %w", at, err)
+		}
+	}
+	for name, pv := range obj {
+		ps, ok := s.Properties[name]
+		switch {
+		case ok:
+		case closed:
+			return fmt.Errorf("%s: property %q is not allowed", at, name)
+		case extra != nil:
+			ps = extra
+		default:
+			continue
+		}
+		if err := sp.validate(ps, pv, at+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
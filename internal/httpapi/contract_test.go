@@ -0,0 +1,242 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/cache"
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"github.com/TemirB/wb-tech-L0/internal/stream"
+)
+
+// TestSpecCoversRoutes fails for a route registered without an openapi.json
+// entry, and for an entry without a route.
+func TestSpecCoversRoutes(t *testing.T) {
+	spec, err := LoadSpec()
+	require.NoError(t, err)
+
+	ctrl := gomock.NewController(t)
+	s := New(NewMockServerWithStats(ctrl), zaptest.NewLogger(t), observability.NewNoop())
+	s.EnableEncodedReads(NewMockEncodedReader(ctrl))
+	s.EnableAdmin(NewMockCacheAdmin(ctrl), testAdminToken)
+	s.EnableOrderStream(stream.New(config.Stream{}), 0)
+
+	routes := make(map[string]bool)
+	require.NoError(t, chi.Walk(s.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes[method+" "+route] = true
+		require.True(t, spec.HasOperation(method, route), "route %s %s is missing from openapi.json", method, route)
+		return nil
+	}))
+	require.NotEmpty(t, routes)
+
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			require.True(t, routes[strings.ToUpper(method)+" "+path], "openapi.json documents %s %s, which has no route", method, path)
+		}
+	}
+}
+
+func TestContract(t *testing.T) {
+	order := &domain.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Items:       []domain.Item{{ChrtID: 9934930, Price: 453, Name: "Mascaras"}},
+		DateCreated: time.Date(2021, time.November, 26, 6, 22, 19, 0, time.UTC),
+		Version:     2,
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		token  string
+		setup  func(svc *MockServerWithStats, admin *MockCacheAdmin)
+		status int
+	}{
+		{
+			name: "get order", method: http.MethodGet, path: "/api/v1/orders/" + order.OrderUID,
+			setup: func(svc *MockServerWithStats, _ *MockCacheAdmin) {
+				svc.EXPECT().GetByUIDWithStats(gomock.Any(), order.OrderUID).
+					Return(order, service.LookupStats{Source: service.SourceDB}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "order not found", method: http.MethodGet, path: "/api/v1/orders/missing",
+			setup: func(svc *MockServerWithStats, _ *MockCacheAdmin) {
+				svc.EXPECT().GetByUIDWithStats(gomock.Any(), "missing").
+					Return(nil, service.LookupStats{}, domain.ErrNotFound)
+			},
+			status: http.StatusNotFound,
+		},
+		{
+			name: "upsert order", method: http.MethodPost, path: "/api/v1/orders",
+			body: `{"order_uid":"b563feb7b2b84b6test","items":[{"chrt_id":1,"price":453}],"date_created":"2021-11-26T06:22:19Z"}`,
+			setup: func(svc *MockServerWithStats, _ *MockCacheAdmin) {
+				svc.EXPECT().UpsertWithStats(gomock.Any(), gomock.Any()).Return(service.UpsertStats{}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "find by track number", method: http.MethodGet, path: "/api/v1/orders?track_number=WBILMTESTTRACK",
+			setup: func(svc *MockServerWithStats, _ *MockCacheAdmin) {
+				svc.EXPECT().GetByTrackNumberWithStats(gomock.Any(), "WBILMTESTTRACK").
+					Return(order, service.LookupStats{Source: service.SourceCache}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "list by customer", method: http.MethodGet, path: "/api/v1/orders?customer_id=test&limit=10",
+			setup: func(svc *MockServerWithStats, _ *MockCacheAdmin) {
+				svc.EXPECT().ListByCustomerWithStats(gomock.Any(), "test", 10).
+					Return([]*domain.Order{order}, service.ListStats{}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "legacy get order", method: http.MethodGet, path: "/order/" + order.OrderUID,
+			setup: func(svc *MockServerWithStats, _ *MockCacheAdmin) {
+				svc.EXPECT().GetByUIDWithStats(gomock.Any(), order.OrderUID).
+					Return(order, service.LookupStats{Source: service.SourceCache}, nil)
+			},
+			status: http.StatusOK,
+		},
+		{name: "readyz", method: http.MethodGet, path: "/readyz", status: http.StatusOK},
+		{name: "openapi", method: http.MethodGet, path: "/openapi.json", status: http.StatusOK},
+		{name: "docs", method: http.MethodGet, path: "/docs", status: http.StatusOK},
+		{
+			name: "admin stats", method: http.MethodGet, path: "/admin/cache", token: testAdminToken,
+			setup: func(_ *MockServerWithStats, admin *MockCacheAdmin) {
+				admin.EXPECT().Stats().Return(observability.CacheStats{Entries: 1, Evictions: map[string]int64{"capacity": 2}})
+			},
+			status: http.StatusOK,
+		},
+		{name: "admin unauthorized", method: http.MethodGet, path: "/admin/cache", status: http.StatusUnauthorized},
+		{
+			name: "admin inspect", method: http.MethodGet, path: "/admin/cache/a", token: testAdminToken,
+			setup: func(_ *MockServerWithStats, admin *MockCacheAdmin) {
+				admin.EXPECT().Inspect("a").Return(cache.EntryInfo{UID: "a", Version: 1}, true)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "admin resize", method: http.MethodPut, path: "/admin/cache/capacity", token: testAdminToken,
+			body: `{"capacity":10}`,
+			setup: func(_ *MockServerWithStats, admin *MockCacheAdmin) {
+				admin.EXPECT().Resize(10).Return(3, nil)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "admin rewarm", method: http.MethodPost, path: "/admin/cache/warm", token: testAdminToken,
+			setup: func(_ *MockServerWithStats, admin *MockCacheAdmin) {
+				admin.EXPECT().Rewarm().Return(true)
+			},
+			status: http.StatusAccepted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			admin := NewMockCacheAdmin(ctrl)
+			if tt.setup != nil {
+				tt.setup(svc, admin)
+			}
+
+			s := New(svc, zaptest.NewLogger(t), observability.NewNoop())
+			s.EnableAdmin(admin, testAdminToken)
+			require.NoError(t, s.EnableContractValidation(func(r *http.Request, err error) {
+				t.Errorf("%s %s: %v", r.Method, r.URL, err)
+			}))
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+		})
+	}
+}
+
+func TestContractViolations(t *testing.T) {
+	spec, err := LoadSpec()
+	require.NoError(t, err)
+	jsonHeader := http.Header{"Content-Type": {"application/json"}}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		status  int // 0 validates the request, otherwise the response
+		header  http.Header
+		body    string
+		wantErr string
+	}{
+		{name: "unknown route", method: http.MethodGet, path: "/nope", wantErr: "no operation"},
+		{name: "limit out of range", method: http.MethodGet, path: "/api/v1/orders?customer_id=c&limit=0", wantErr: "less than 1"},
+		{name: "limit not a number", method: http.MethodGet, path: "/api/v1/orders?customer_id=c&limit=x", wantErr: "not a number"},
+		{name: "missing body", method: http.MethodPost, path: "/api/v1/orders", wantErr: "body is required"},
+		{
+			name: "unknown field", method: http.MethodPost, path: "/api/v1/orders", header: jsonHeader,
+			body: `{"order_uid":"a","colour":"red"}`, wantErr: `property "colour" is not allowed`,
+		},
+		{
+			name: "wrong type", method: http.MethodPost, path: "/api/v1/orders", header: jsonHeader,
+			body: `{"order_uid":"a","items":[{"price":"1"}]}`, wantErr: "body.items[0].price: want integer",
+		},
+		{
+			name: "undocumented status", method: http.MethodGet, path: "/api/v1/orders/a", status: http.StatusTeapot,
+			wantErr: "status is not documented",
+		},
+		{
+			name: "undocumented content type", method: http.MethodGet, path: "/api/v1/orders/a", status: http.StatusOK,
+			header: http.Header{"Content-Type": {"text/plain"}}, body: "a", wantErr: "not documented",
+		},
+		{
+			name: "missing required property", method: http.MethodGet, path: "/api/v1/orders/a", status: http.StatusOK,
+			header: jsonHeader, body: `{"track_number":"T"}`, wantErr: `property "order_uid" is required`,
+		},
+		{
+			name: "bad date-time", method: http.MethodGet, path: "/api/v1/orders/a", status: http.StatusOK,
+			header: jsonHeader, body: `{"order_uid":"a","date_created":"yesterday"}`, wantErr: "not a date-time",
+		},
+		{
+			name: "neither order nor list", method: http.MethodGet, path: "/api/v1/orders?track_number=T", status: http.StatusOK,
+			header: jsonHeader, body: `"T"`, wantErr: "matches 0 of the oneOf schemas",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.status == 0 {
+				if tt.header != nil {
+					req.Header = tt.header
+				}
+				err = spec.ValidateRequest(req, []byte(tt.body))
+			} else {
+				err = spec.ValidateResponse(req, tt.status, tt.header, []byte(tt.body))
+			}
+			require.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
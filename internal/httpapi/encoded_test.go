@@ -0,0 +1,207 @@
+package httpapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/cache"
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"github.com/TemirB/wb-tech-L0/internal/storage"
+)
+
+func TestServer_GetOrderEncoded(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		wantGzip       bool
+		enc            service.EncodedOrder
+		ok             bool
+		wantEncoding   string
+	}{
+		{name: "json", enc: service.EncodedOrder{Body: []byte("{}\n")}, ok: true},
+		{name: "gzip", acceptEncoding: "br, gzip;q=0.8", wantGzip: true,
+			enc: service.EncodedOrder{Body: []byte{0x1f, 0x8b}, Gzip: true}, ok: true, wantEncoding: "gzip"},
+		{name: "gzip wanted, json kept", acceptEncoding: "gzip", wantGzip: true,
+			enc: service.EncodedOrder{Body: []byte("{}\n")}, ok: true},
+		{name: "falls back on a miss", acceptEncoding: "*", wantGzip: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			reader := NewMockEncodedReader(ctrl)
+			st := service.LookupStats{Source: service.SourceCache, CacheMs: 1}
+			reader.EXPECT().GetEncodedWithStats("a", tt.wantGzip).Return(tt.enc, st, tt.ok)
+			if !tt.ok {
+				svc.EXPECT().GetByUIDWithStats(gomock.Any(), "a").
+					Return(&domain.Order{OrderUID: "a"}, service.LookupStats{Source: service.SourceDB}, nil)
+			}
+
+			srv := New(svc, zap.NewNop(), observability.NewNoop())
+			srv.EnableEncodedReads(reader)
+			req := httptest.NewRequest(http.MethodGet, "/order/a", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+			require.Equal(t, tt.wantEncoding, w.Header().Get("Content-Encoding"))
+			if tt.ok {
+				require.Equal(t, "cache", w.Header().Get("X-Source"))
+				require.Equal(t, tt.enc.Body, w.Body.Bytes())
+			} else {
+				require.Equal(t, "db", w.Header().Get("X-Source"))
+				require.Contains(t, w.Body.String(), `"order_uid": "a"`)
+			}
+		})
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"GZIP", true},
+		{"deflate, gzip;q=1.0, *;q=0.5", true},
+		{"gzip;q=0", false},
+		{"*", true},
+		{"*;q=0", false},
+		{"identity, *;q=0.1", true},
+		{"gzip;q=0, *", false},
+		{"br", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", tt.header)
+			require.Equal(t, tt.want, acceptsGzip(r))
+		})
+	}
+}
+
+// newBenchServer serves one cached order through the real service and cache.
+func newBenchServer(tb testing.TB, encode bool) (*Server, *domain.Order) {
+	c, err := cache.New(config.Cache{Cap: 10, Encode: encode, EncodeGzip: encode})
+	require.NoError(tb, err)
+	order := benchOrder()
+	c.Set(order)
+	svc := service.NewService(c, nil, storage.NewMemory(), zap.NewNop(), observability.NewNoop())
+	srv := New(svc, zap.NewNop(), observability.NewNoop())
+	if encode {
+		srv.EnableEncodedReads(svc)
+	}
+	return srv, order
+}
+
+func benchOrder() *domain.Order {
+	order := &domain.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: domain.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809",
+			City: "Kiryat Mozkin", Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: domain.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay",
+			Amount: 1817, PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, time.November, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+	for i := 0; i < 5; i++ {
+		order.Items = append(order.Items, domain.Item{
+			ChrtID: 9934930 + i, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		})
+	}
+	return order
+}
+
+func TestEncodedReadsMatchPlainReads(t *testing.T) {
+	for _, acceptEncoding := range []string{"", "gzip"} {
+		plain, order := newBenchServer(t, false)
+		encoded, _ := newBenchServer(t, true)
+
+		get := func(srv *Server) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, "/order/"+order.OrderUID, nil)
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+			return w
+		}
+		want := get(plain).Body.Bytes()
+		for i := 0; i < 2; i++ { // encoded, then kept
+			w := get(encoded)
+			body := w.Body.Bytes()
+			if acceptEncoding == "gzip" {
+				require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				require.NoError(t, err)
+				body, err = io.ReadAll(zr)
+				require.NoError(t, err)
+			}
+			require.Equal(t, string(want), string(body))
+		}
+	}
+}
+
+// BenchmarkGetOrderHit compares cache hits encoded per request with hits served
+// from the stored JSON and gzip bodies:
+//
+//	go test ./internal/httpapi -run '^$' -bench GetOrderHit -benchmem
+func BenchmarkGetOrderHit(b *testing.B) {
+	for _, bc := range []struct {
+		name           string
+		encode         bool
+		acceptEncoding string
+	}{
+		{name: "encode-per-request", encode: false},
+		{name: "stored-json", encode: true},
+		{name: "stored-gzip", encode: true, acceptEncoding: "gzip"},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			srv, order := newBenchServer(b, bc.encode)
+			handler := srv.Handler()
+			req := httptest.NewRequest(http.MethodGet, "/order/"+order.OrderUID, nil).WithContext(context.Background())
+			if bc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", bc.acceptEncoding)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					b.Fatalf("status %d", w.Code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package httpapi
+
+import "net/http"
+
+// errorResponse is the body of structured errors, documented as ErrorBody in
+// openapi.json.
+type errorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// Error codes of structured errors.
+const (
+	codeUnauthenticated    = "unauthenticated"
+	codeForbidden          = "forbidden"
+	codeRateLimited        = "rate_limited"
+	codeBodyTooLarge       = "body_too_large"
+	codePreconditionFailed = "precondition_failed"
+)
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	writeJSON(w, errorResponse{Error: msg, Code: code})
+}
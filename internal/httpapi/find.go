@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+// findOrders serves GET /orders?track_number=... (one order) and
+// GET /orders?customer_id=...&limit=... (the customer's orders, newest first).
+func (s *Server) findOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	track, customer := q.Get("track_number"), q.Get("customer_id")
+	switch {
+	case track != "" && customer == "":
+		s.getByTrackNumber(w, r, track)
+	case customer != "" && track == "":
+		s.listByCustomer(w, r, customer)
+	default:
+		http.Error(w, "exactly one of track_number or customer_id required", http.StatusBadRequest)
+	}
+}
+
+func (s *Server) getByTrackNumber(w http.ResponseWriter, r *http.Request, track string) {
+	order, st, err := s.service.GetByTrackNumberWithStats(r.Context(), track)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		http.Error(w, "no order with this track number", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, "Service error", http.StatusInternalServerError)
+		return
+	}
+
+	observability.AppendServerTiming(w, "cache", st.CacheMs, "")
+	observability.AppendServerTiming(w, "db", st.DBMs, "")
+	w.Header().Set("X-Source", string(st.Source))
+	s.setCacheControl(w, r)
+	writeJSON(w, order)
+}
+
+func (s *Server) listByCustomer(w http.ResponseWriter, r *http.Request, customer string) {
+	limit := defaultListLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxListLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxListLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	orders, st, err := s.service.ListByCustomerWithStats(r.Context(), customer, limit)
+	if err != nil {
+		http.Error(w, "Service error", http.StatusInternalServerError)
+		return
+	}
+
+	observability.AppendServerTiming(w, "cache", st.CacheMs, "")
+	observability.AppendServerTiming(w, "db", st.DBMs, "")
+	if st.Partial {
+		// Storage is unavailable; the list holds only what this instance has cached.
+		w.Header().Set("X-Partial", "true")
+	}
+	s.setCacheControl(w, r)
+	writeJSON(w, orders)
+}
@@ -0,0 +1,121 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+func TestServer_FindOrders(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		setup          func(svc *MockServerWithStats)
+		expectedStatus int
+		expectedBody   string
+		checkHeaders   func(t *testing.T, w *httptest.ResponseRecorder)
+	}{
+		{
+			name: "by track number",
+			path: "/orders?track_number=WB1",
+			setup: func(svc *MockServerWithStats) {
+				svc.EXPECT().GetByTrackNumberWithStats(gomock.Any(), "WB1").
+					Return(&domain.Order{OrderUID: "a", TrackNumber: "WB1"}, service.LookupStats{Source: service.SourceCache}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"order_uid": "a"`,
+			checkHeaders: func(t *testing.T, w *httptest.ResponseRecorder) {
+				require.Equal(t, "cache", w.Header().Get("X-Source"))
+			},
+		},
+		{
+			name: "track number not found",
+			path: "/orders?track_number=WB1",
+			setup: func(svc *MockServerWithStats) {
+				svc.EXPECT().GetByTrackNumberWithStats(gomock.Any(), "WB1").
+					Return(nil, service.LookupStats{}, domain.ErrNotFound)
+			},
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "no order with this track number",
+		},
+		{
+			name: "by customer",
+			path: "/orders?customer_id=alice&limit=5",
+			setup: func(svc *MockServerWithStats) {
+				svc.EXPECT().ListByCustomerWithStats(gomock.Any(), "alice", 5).
+					Return([]*domain.Order{{OrderUID: "a"}, {OrderUID: "b"}}, service.ListStats{CacheHits: 2}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"order_uid": "b"`,
+			checkHeaders: func(t *testing.T, w *httptest.ResponseRecorder) {
+				require.Empty(t, w.Header().Get("X-Partial"))
+			},
+		},
+		{
+			name: "by customer, storage down",
+			path: "/orders?customer_id=alice",
+			setup: func(svc *MockServerWithStats) {
+				svc.EXPECT().ListByCustomerWithStats(gomock.Any(), "alice", defaultListLimit).
+					Return([]*domain.Order{{OrderUID: "a"}}, service.ListStats{CacheHits: 1, Partial: true}, nil)
+			},
+			expectedStatus: http.StatusOK,
+			checkHeaders: func(t *testing.T, w *httptest.ResponseRecorder) {
+				require.Equal(t, "true", w.Header().Get("X-Partial"))
+			},
+		},
+		{
+			name: "by customer, service error",
+			path: "/orders?customer_id=alice",
+			setup: func(svc *MockServerWithStats) {
+				svc.EXPECT().ListByCustomerWithStats(gomock.Any(), "alice", defaultListLimit).
+					Return(nil, service.ListStats{}, errors.New("db down"))
+			},
+			expectedStatus: http.StatusInternalServerError,
+		},
+		{
+			name:           "bad limit",
+			path:           "/orders?customer_id=alice&limit=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "limit must be between 1 and 1000",
+		},
+		{
+			name:           "no filter",
+			path:           "/orders",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "both filters",
+			path:           "/orders?customer_id=alice&track_number=WB1",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			if tt.setup != nil {
+				tt.setup(svc)
+			}
+			server := New(svc, zaptest.NewLogger(t), observability.NewNoop())
+
+			w := httptest.NewRecorder()
+			server.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Contains(t, w.Body.String(), tt.expectedBody)
+			if tt.checkHeaders != nil {
+				tt.checkHeaders(t, w)
+			}
+		})
+	}
+}
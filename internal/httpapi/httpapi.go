@@ -7,11 +7,18 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/auth"
+	"github.com/TemirB/wb-tech-L0/internal/config"
 	"github.com/TemirB/wb-tech-L0/internal/domain"
 	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"github.com/TemirB/wb-tech-L0/internal/pkg/ratelimit"
+	"github.com/TemirB/wb-tech-L0/internal/stream"
+	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
@@ -20,30 +27,84 @@ import (
 type ServerWithStats interface {
 	GetByUIDWithStats(ctx context.Context, uid string) (*domain.Order, service.LookupStats, error)
 	UpsertWithStats(ctx context.Context, order *domain.Order) (service.UpsertStats, error)
+	UpsertIfWithStats(ctx context.Context, order *domain.Order, p domain.Precondition) (service.UpsertStats, error)
+	GetByTrackNumberWithStats(ctx context.Context, track string) (*domain.Order, service.LookupStats, error)
+	ListByCustomerWithStats(ctx context.Context, customerID string, limit int) ([]*domain.Order, service.ListStats, error)
+	GetByUIDsWithStats(ctx context.Context, uids []string) ([]service.BatchItem, []string, service.BatchStats, error)
+}
+
+// EncodedReader serves cache hits as stored response bodies (see
+// service.Service.GetEncodedWithStats).
+type EncodedReader interface {
+	GetEncodedWithStats(uid string, acceptGzip bool) (service.EncodedOrder, service.LookupStats, bool)
 }
 
 type Server struct {
 	service ServerWithStats
-	mux     *http.ServeMux
+	router  *chi.Mux
 	logger  *zap.Logger
 	metrics observability.Metrics
+	checks  map[string]ReadinessCheck
+	admin   CacheAdmin
+	encoded EncodedReader
+	auth    auth.Authenticator
+
+	limits       config.HTTP                      // see EnableLimits
+	limiters     map[auth.Role]*ratelimit.Limiter // per route class, nil = unlimited
+	cacheControl map[string]string                // by route pattern, see EnableCacheControl
+	compression  *compressor                      // see EnableCompression
+
+	hub        *stream.Hub // see EnableOrderStream
+	heartbeat  time.Duration
+	streamStop chan struct{} // closed on shutdown to end the streams
+
+	contract func(http.Handler) http.Handler // see EnableContractValidation
 }
 
 func New(service ServerWithStats, logger *zap.Logger, metrics observability.Metrics) *Server {
 	s := &Server{
 		service: service,
 		logger:  logger,
-		mux:     http.NewServeMux(),
+		router:  chi.NewRouter(),
 		metrics: metrics,
+		checks:  make(map[string]ReadinessCheck),
 	}
 	s.routes()
 	return s
 }
 
+// routes registers the API under /api/v1, with the paths that predate it as
+// deprecated aliases. Every route must be documented in openapi.json. GET and
+// HEAD requests matching no route are served the static UI. Order routes name
+// the role they need once EnableAuth is called.
 func (s *Server) routes() {
-	s.mux.HandleFunc("GET /order/", s.getOrder)
-	s.mux.HandleFunc("POST /order/", s.upsertOrder)
-	s.mux.Handle("/", http.FileServer(http.Dir(s.staticDir())))
+	s.router.Get("/api/v1/orders/{uid}", s.allow(auth.RoleReader, s.getOrder))
+	s.router.Post("/api/v1/orders", s.allow(auth.RoleWriter, s.upsertOrder))
+	s.router.Get("/api/v1/orders", s.allow(auth.RoleReader, s.findOrders))
+	s.router.Post("/api/v1/orders:batchGet", s.allow(auth.RoleReader, s.batchGetOrders))
+
+	s.router.With(deprecated(orderSuccessor)).Get("/order/{uid}", s.allow(auth.RoleReader, s.getOrder))
+	s.router.With(deprecated(fixedSuccessor("/api/v1/orders"))).Post("/order/", s.allow(auth.RoleWriter, s.upsertOrder))
+	s.router.With(deprecated(fixedSuccessor("/api/v1/orders"))).Get("/orders", s.allow(auth.RoleReader, s.findOrders))
+
+	s.router.Get("/readyz", s.readyz)
+	s.router.Get("/openapi.json", s.openapi)
+	s.router.Get("/docs", s.docs)
+
+	static := http.FileServer(http.Dir(s.staticDir()))
+	s.router.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.NotFound(w, r)
+			return
+		}
+		static.ServeHTTP(w, r)
+	})
+}
+
+// EnableEncodedReads makes GET /order/{uid} write cache hits from their stored
+// JSON, or gzip for clients that accept it, instead of encoding them per request.
+func (s *Server) EnableEncodedReads(r EncodedReader) {
+	s.encoded = r
 }
 
 func (s *Server) staticDir() string {
@@ -52,27 +113,68 @@ func (s *Server) staticDir() string {
 }
 
 func (s *Server) getOrder(w http.ResponseWriter, r *http.Request) {
-	uid := strings.TrimPrefix(r.URL.Path, "/order/")
+	uid := chi.URLParam(r, "uid")
 	if uid == "" {
 		http.Error(w, "order id required", http.StatusBadRequest)
 		return
 	}
 
+	if s.encoded != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if enc, st, ok := s.encoded.GetEncodedWithStats(uid, acceptsGzip(r)); ok {
+			writeLookupHeaders(w, st)
+			coding := responseCoding(r)
+			if enc.Gzip {
+				coding = codingGzip
+			}
+			if s.writeValidators(w, r, enc.Version, enc.UpdatedAt, coding) {
+				return
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			if enc.Gzip {
+				w.Header().Set("Content-Encoding", "gzip")
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(enc.Body)))
+			_, _ = w.Write(enc.Body)
+			return
+		}
+	}
+
 	order, st, err := s.service.GetByUIDWithStats(r.Context(), uid)
+	if errors.Is(err, service.ErrStorageUnavailable) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "storage unavailable", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		// Можно дополнительно различать 404 и 500 по типу ошибки, если хранилище отдаёт ErrNotFound.
 		http.Error(w, "no order with this id", http.StatusNotFound)
 		return
 	}
 
+	writeLookupHeaders(w, st)
+	if s.writeValidators(w, r, order.Version, order.UpdatedAt, responseCoding(r)) {
+		return
+	}
+	writeJSON(w, order)
+}
+
+func writeLookupHeaders(w http.ResponseWriter, st service.LookupStats) {
 	observability.AppendServerTiming(w, "cache", st.CacheMs, "")
 	observability.AppendServerTiming(w, "db", st.DBMs, "")
 	observability.AppendServerTiming(w, "source", 0, string(st.Source))
 	w.Header().Set("X-Source", string(st.Source))
+	if st.Source == service.SourceStale {
+		w.Header().Set("Warning", `110 - "Response is Stale"`)
+	}
 	observability.SetIfPos(w, "X-Cache-Time", st.CacheMs)
 	observability.SetIfPos(w, "X-DB-Time", st.DBMs)
+}
 
-	writeJSON(w, order)
+// acceptsGzip reports whether the Accept-Encoding header allows gzip, directly
+// or through "*", with a non-zero quality.
+func acceptsGzip(r *http.Request) bool {
+	return quality(acceptEncoding(r.Header.Get("Accept-Encoding")), codingGzip) > 0
 }
 
 func (s *Server) upsertOrder(w http.ResponseWriter, r *http.Request) {
@@ -87,7 +189,10 @@ func (s *Server) upsertOrder(w http.ResponseWriter, r *http.Request) {
 	decoder.DisallowUnknownFields()
 
 	if err := decoder.Decode(&order); err != nil {
-		s.logger.Error(
+		if bodyTooLarge(w, err) {
+			return
+		}
+		s.log(r).Error(
 			"Error while decoding JSON",
 			zap.Error(err),
 		)
@@ -100,23 +205,40 @@ func (s *Server) upsertOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	st, err := s.service.UpsertWithStats(r.Context(), &order)
-	if err != nil {
+	// If-Match guards against lost updates: the stored order must still be the
+	// version the client read, checked by storage as part of the write.
+	var (
+		st  service.UpsertStats
+		err error
+	)
+	if im := r.Header.Get("If-Match"); im != "" {
+		st, err = s.service.UpsertIfWithStats(r.Context(), &order, precondition(im))
+	} else {
+		st, err = s.service.UpsertWithStats(r.Context(), &order)
+	}
+	switch {
+	case errors.Is(err, domain.ErrVersionMismatch):
+		writeError(w, http.StatusPreconditionFailed, codePreconditionFailed,
+			"the order does not match If-Match, it was changed or does not exist")
+		return
+	case err != nil:
 		http.Error(w, "Service error", http.StatusInternalServerError)
 		return
 	}
 
 	observability.AppendServerTiming(w, "db_write", st.DBWriteMs, "")
 
+	if tag := etag(order.Version, responseCoding(r)); tag != "" {
+		w.Header().Set("ETag", tag)
+	}
+	if order.UpdatedAt != nil {
+		w.Header().Set("Last-Modified", order.UpdatedAt.UTC().Format(http.TimeFormat))
+	}
 	writeJSON(w, order)
 }
 
 func validateOrder(order domain.Order) error {
-	if order.OrderUID == "" {
-		return errors.New("order_uid is required")
-	}
-	// ... some new validate params
-	return nil
+	return order.Validate()
 }
 
 func writeJSON(w http.ResponseWriter, v any) {
@@ -128,18 +250,37 @@ func writeJSON(w http.ResponseWriter, v any) {
 
 func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
 	// Connect middleware
-	handler := ServerTimingApp(s.metrics)(s.mux)
+	handler := ServerTimingApp(s.metrics)(s.Handler())
 
 	srv := &http.Server{
-		Addr:    addr,
-		Handler: handler,
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: s.limits.ReadHeaderTimeout,
+		ReadTimeout:       s.limits.ReadTimeout,
+		WriteTimeout:      s.limits.WriteTimeout,
+		IdleTimeout:       s.limits.IdleTimeout,
 	}
 
 	go func() {
 		<-ctx.Done()
+		// Shutdown waits for handlers, and streams never finish on their own.
+		if s.streamStop != nil {
+			close(s.streamStop)
+		}
 		_ = srv.Shutdown(context.Background())
 	}()
 	return srv.ListenAndServe()
 }
 
-func (s *Server) Handler() http.Handler { return s.mux }
+// Handler is the router behind the optional contract validation and, outside
+// it, compression.
+func (s *Server) Handler() http.Handler {
+	var h http.Handler = s.router
+	if s.contract != nil {
+		h = s.contract(h)
+	}
+	if s.compression != nil {
+		h = s.compression.middleware(h)
+	}
+	return h
+}
@@ -36,6 +36,22 @@ func (m *MockServerWithStats) EXPECT() *MockServerWithStatsMockRecorder {
 	return m.recorder
 }
 
+// GetByTrackNumberWithStats mocks base method.
+func (m *MockServerWithStats) GetByTrackNumberWithStats(ctx context.Context, track string) (*domain.Order, service.LookupStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByTrackNumberWithStats", ctx, track)
+	ret0, _ := ret[0].(*domain.Order)
+	ret1, _ := ret[1].(service.LookupStats)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetByTrackNumberWithStats indicates an expected call of GetByTrackNumberWithStats.
+func (mr *MockServerWithStatsMockRecorder) GetByTrackNumberWithStats(ctx, track interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByTrackNumberWithStats", reflect.TypeOf((*MockServerWithStats)(nil).GetByTrackNumberWithStats), ctx, track)
+}
+
 // GetByUIDWithStats mocks base method.
 func (m *MockServerWithStats) GetByUIDWithStats(ctx context.Context, uid string) (*domain.Order, service.LookupStats, error) {
 	m.ctrl.T.Helper()
@@ -52,6 +68,39 @@ func (mr *MockServerWithStatsMockRecorder) GetByUIDWithStats(ctx, uid interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUIDWithStats", reflect.TypeOf((*MockServerWithStats)(nil).GetByUIDWithStats), ctx, uid)
 }
 
+// GetByUIDsWithStats mocks base method.
+func (m *MockServerWithStats) GetByUIDsWithStats(ctx context.Context, uids []string) ([]service.BatchItem, []string, service.BatchStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByUIDsWithStats", ctx, uids)
+	ret0, _ := ret[0].([]service.BatchItem)
+	ret1, _ := ret[1].([]string)
+	ret2, _ := ret[2].(service.BatchStats)
+	ret3, _ := ret[3].(error)
+	return ret0, ret1, ret2, ret3
+}
+
+// GetByUIDsWithStats indicates an expected call of GetByUIDsWithStats.
+func (mr *MockServerWithStatsMockRecorder) GetByUIDsWithStats(ctx, uids interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByUIDsWithStats", reflect.TypeOf((*MockServerWithStats)(nil).GetByUIDsWithStats), ctx, uids)
+}
+
+// ListByCustomerWithStats mocks base method.
+func (m *MockServerWithStats) ListByCustomerWithStats(ctx context.Context, customerID string, limit int) ([]*domain.Order, service.ListStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListByCustomerWithStats", ctx, customerID, limit)
+	ret0, _ := ret[0].([]*domain.Order)
+	ret1, _ := ret[1].(service.ListStats)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ListByCustomerWithStats indicates an expected call of ListByCustomerWithStats.
+func (mr *MockServerWithStatsMockRecorder) ListByCustomerWithStats(ctx, customerID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByCustomerWithStats", reflect.TypeOf((*MockServerWithStats)(nil).ListByCustomerWithStats), ctx, customerID, limit)
+}
+
 // UpsertWithStats mocks base method.
 func (m *MockServerWithStats) UpsertWithStats(ctx context.Context, order *domain.Order) (service.UpsertStats, error) {
 	m.ctrl.T.Helper()
@@ -61,8 +110,62 @@ func (m *MockServerWithStats) UpsertWithStats(ctx context.Context, order *domain
 	return ret0, ret1
 }
 
+// UpsertIfWithStats mocks base method.
+func (m *MockServerWithStats) UpsertIfWithStats(ctx context.Context, order *domain.Order, p domain.Precondition) (service.UpsertStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertIfWithStats", ctx, order, p)
+	ret0, _ := ret[0].(service.UpsertStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertIfWithStats indicates an expected call of UpsertIfWithStats.
+func (mr *MockServerWithStatsMockRecorder) UpsertIfWithStats(ctx, order, p interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertIfWithStats", reflect.TypeOf((*MockServerWithStats)(nil).UpsertIfWithStats), ctx, order, p)
+}
+
 // UpsertWithStats indicates an expected call of UpsertWithStats.
 func (mr *MockServerWithStatsMockRecorder) UpsertWithStats(ctx, order interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertWithStats", reflect.TypeOf((*MockServerWithStats)(nil).UpsertWithStats), ctx, order)
 }
+
+// MockEncodedReader is a mock of EncodedReader interface.
+type MockEncodedReader struct {
+	ctrl     *gomock.Controller
+	recorder *MockEncodedReaderMockRecorder
+}
+
+// MockEncodedReaderMockRecorder is the mock recorder for MockEncodedReader.
+type MockEncodedReaderMockRecorder struct {
+	mock *MockEncodedReader
+}
+
+// NewMockEncodedReader creates a new mock instance.
+func NewMockEncodedReader(ctrl *gomock.Controller) *MockEncodedReader {
+	mock := &MockEncodedReader{ctrl: ctrl}
+	mock.recorder = &MockEncodedReaderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEncodedReader) EXPECT() *MockEncodedReaderMockRecorder {
+	return m.recorder
+}
+
+// GetEncodedWithStats mocks base method.
+func (m *MockEncodedReader) GetEncodedWithStats(uid string, acceptGzip bool) (service.EncodedOrder, service.LookupStats, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetEncodedWithStats", uid, acceptGzip)
+	ret0, _ := ret[0].(service.EncodedOrder)
+	ret1, _ := ret[1].(service.LookupStats)
+	ret2, _ := ret[2].(bool)
+	return ret0, ret1, ret2
+}
+
+// GetEncodedWithStats indicates an expected call of GetEncodedWithStats.
+func (mr *MockEncodedReaderMockRecorder) GetEncodedWithStats(uid, acceptGzip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetEncodedWithStats", reflect.TypeOf((*MockEncodedReader)(nil).GetEncodedWithStats), uid, acceptGzip)
+}
@@ -61,8 +61,10 @@ func TestServer_GetOrder(t *testing.T) {
 			name:           "missing order id",
 			path:           "/order/",
 			serviceResp:    serviceResponse{},
-			expectedStatus: http.StatusBadRequest,
-			expectedBody:   "order id required",
+			expectedStatus: http.StatusMethodNotAllowed,
+			checkHeaders: func(t *testing.T, w *httptest.ResponseRecorder) {
+				require.Equal(t, "POST", w.Header().Get("Allow"))
+			},
 		},
 		{
 			name: "order not found",
@@ -84,6 +86,33 @@ func TestServer_GetOrder(t *testing.T) {
 			expectedStatus: http.StatusNotFound,
 			expectedBody:   "no order with this id",
 		},
+		{
+			name: "storage unavailable",
+			path: "/order/down-uid",
+			serviceResp: serviceResponse{
+				err: service.ErrStorageUnavailable,
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "storage unavailable",
+		},
+		{
+			name: "stale copy while storage is unavailable",
+			path: "/order/stale-uid",
+			serviceResp: serviceResponse{
+				order: &domain.Order{
+					OrderUID: "stale-uid",
+				},
+				stats: service.LookupStats{
+					Source: service.SourceStale,
+				},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"order_uid": "stale-uid"`,
+			checkHeaders: func(t *testing.T, w *httptest.ResponseRecorder) {
+				require.Equal(t, "stale", w.Header().Get("X-Source"))
+				require.Equal(t, `110 - "Response is Stale"`, w.Header().Get("Warning"))
+			},
+		},
 		{
 			name: "successful get from db",
 			path: "/order/db-uid",
@@ -383,3 +412,51 @@ func TestWriteJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_Readyz(t *testing.T) {
+	tests := []struct {
+		name           string
+		checks         map[string]ReadinessCheck
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "no checks",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"ready": true`,
+		},
+		{
+			name: "all ready",
+			checks: map[string]ReadinessCheck{
+				"cache_warmup": func() (any, bool) { return map[string]string{"state": "running"}, true },
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"state": "running"`,
+		},
+		{
+			name: "one not ready",
+			checks: map[string]ReadinessCheck{
+				"ok":      func() (any, bool) { return "ok", true },
+				"warming": func() (any, bool) { return "running", false },
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `"ready": false`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := New(nil, zap.NewNop(), observability.NewNoop())
+			for name, check := range tt.checks {
+				srv.AddReadinessCheck(name, check)
+			}
+
+			w := httptest.NewRecorder()
+			srv.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Contains(t, w.Body.String(), tt.expectedBody)
+			require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package httpapi
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/auth"
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/pkg/ratelimit"
+)
+
+// EnableLimits applies cfg: server timeouts, the request body limit and, if
+// enabled, rate limits per client and route class. The classes follow the roles
+// routes require: reads, writes and /admin. Must be called before the server
+// starts.
+func (s *Server) EnableLimits(cfg config.HTTP) {
+	s.limits = cfg
+	s.limiters = nil
+	if cfg.RateLimit.Enabled {
+		s.limiters = map[auth.Role]*ratelimit.Limiter{
+			auth.RoleReader: ratelimit.New(cfg.RateLimit.Read),
+			auth.RoleWriter: ratelimit.New(cfg.RateLimit.Write),
+			auth.RoleAdmin:  ratelimit.New(cfg.RateLimit.Admin),
+		}
+	}
+}
+
+// rateLimit takes a token for the client of r from the bucket of class and
+// reports whether the request may go on; if not, it has answered 429.
+func (s *Server) rateLimit(w http.ResponseWriter, r *http.Request, class auth.Role) bool {
+	l := s.limiters[class]
+	if l == nil {
+		return true
+	}
+	d := l.Allow(s.client(r))
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+	if d.Allowed {
+		return true
+	}
+	s.log(r).Debug("request rate limited",
+		zap.String("class", class.String()),
+		zap.String("client", s.client(r)),
+		zap.String("path", r.URL.Path),
+	)
+	h.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(d.RetryAfter))))
+	writeError(w, http.StatusTooManyRequests, codeRateLimited, "too many requests, retry later")
+	return false
+}
+
+// client identifies whom a request counts against: the authenticated caller,
+// or else the client IP.
+func (s *Server) client(r *http.Request) string {
+	if id, ok := auth.FromContext(r.Context()); ok {
+		return id.Method + ":" + id.Subject
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+func (s *Server) clientIP(r *http.Request) string {
+	if s.limits.RateLimit.TrustProxy {
+		if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+			hops := strings.Split(xff[len(xff)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitBody caps the body of r at the configured size; reads beyond it fail
+// with *http.MaxBytesError.
+func (s *Server) limitBody(w http.ResponseWriter, r *http.Request) {
+	if s.limits.MaxBodyBytes > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, s.limits.MaxBodyBytes)
+	}
+}
+
+// bodyTooLarge answers 413 and reports true if err comes from limitBody.
+func bodyTooLarge(w http.ResponseWriter, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	writeError(w, http.StatusRequestEntityTooLarge, codeBodyTooLarge,
+		"request body exceeds "+strconv.FormatInt(tooLarge.Limit, 10)+" bytes")
+	return true
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,221 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/auth"
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+// limitedServer allows two reads per client and refills too slowly for a test
+// to notice.
+func limitedServer(t *testing.T, svc ServerWithStats, cfg config.HTTP) *Server {
+	t.Helper()
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Read = config.Limit{Rate: 0.01, Burst: 2}
+	s := New(svc, zaptest.NewLogger(t), observability.NewNoop())
+	s.EnableLimits(cfg)
+	require.NoError(t, s.EnableContractValidation(func(r *http.Request, err error) {
+		t.Errorf("%s %s: %v", r.Method, r.URL, err)
+	}))
+	return s
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	svc := NewMockServerWithStats(ctrl)
+	svc.EXPECT().GetByUIDWithStats(gomock.Any(), "a").
+		Return(&domain.Order{OrderUID: "a"}, service.LookupStats{Source: service.SourceCache}, nil).AnyTimes()
+	s := limitedServer(t, svc, config.HTTP{})
+
+	get := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/a", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("10.0.0.1:1000")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "100", w.Header().Get("RateLimit-Reset"))
+
+	// The port does not matter, the address does.
+	require.Equal(t, http.StatusOK, get("10.0.0.1:2000").Code)
+	w = get("10.0.0.1:3000")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "100", w.Header().Get("Retry-After"))
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	require.Contains(t, w.Body.String(), `"code": "rate_limited"`)
+
+	require.Equal(t, http.StatusOK, get("10.0.0.2:1000").Code)
+
+	// Writes are another class, unlimited here.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders", strings.NewReader(`{"order_uid":"a"}`))
+	req.RemoteAddr = "10.0.0.1:1000"
+	req.Header.Set("Content-Type", "application/json")
+	svc.EXPECT().UpsertWithStats(gomock.Any(), gomock.Any()).Return(service.UpsertStats{}, nil)
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("RateLimit-Limit"))
+}
+
+func TestServer_RateLimitClient(t *testing.T) {
+	authenticator, err := auth.New(config.Auth{
+		APIKeys: []config.APIKey{{Name: "dashboard", Role: config.RoleReader, Hash: auth.HashAPIKey("k")}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		auth   bool
+		proxy  bool
+		first  func(r *http.Request)
+		second func(r *http.Request)
+		shared bool // whether both requests draw from one bucket
+	}{
+		{
+			name: "api key across addresses", auth: true, shared: true,
+			first:  func(r *http.Request) { r.RemoteAddr = "10.0.0.1:1" },
+			second: func(r *http.Request) { r.RemoteAddr = "10.0.0.2:1" },
+		},
+		{
+			name: "forwarded for is ignored by default", shared: true,
+			first:  func(r *http.Request) { r.Header.Set("X-Forwarded-For", "1.1.1.1") },
+			second: func(r *http.Request) { r.Header.Set("X-Forwarded-For", "2.2.2.2") },
+		},
+		{
+			name: "trusted proxy", proxy: true,
+			first:  func(r *http.Request) { r.Header.Set("X-Forwarded-For", "9.9.9.9, 1.1.1.1") },
+			second: func(r *http.Request) { r.Header.Set("X-Forwarded-For", "9.9.9.9, 2.2.2.2") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			svc.EXPECT().GetByUIDWithStats(gomock.Any(), "a").
+				Return(&domain.Order{OrderUID: "a"}, service.LookupStats{Source: service.SourceCache}, nil).AnyTimes()
+			s := limitedServer(t, svc, config.HTTP{RateLimit: config.RateLimit{TrustProxy: tt.proxy}})
+			if tt.auth {
+				s.EnableAuth(authenticator)
+			}
+
+			remaining := func(setup func(r *http.Request)) string {
+				req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/a", nil)
+				req.Header.Set(auth.APIKeyHeader, "k")
+				setup(req)
+				w := httptest.NewRecorder()
+				s.Handler().ServeHTTP(w, req)
+				require.Equal(t, http.StatusOK, w.Code)
+				return w.Header().Get("RateLimit-Remaining")
+			}
+			require.Equal(t, "1", remaining(tt.first))
+			if tt.shared {
+				require.Equal(t, "0", remaining(tt.second))
+			} else {
+				require.Equal(t, "1", remaining(tt.second))
+			}
+		})
+	}
+}
+
+func TestServer_RateLimitFailedAuth(t *testing.T) {
+	authenticator, err := auth.New(config.Auth{
+		APIKeys: []config.APIKey{{Name: "dashboard", Role: config.RoleReader, Hash: auth.HashAPIKey("k")}},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		setup  func(s *Server)
+		path   string
+		header func(r *http.Request)
+	}{
+		{
+			name:   "api key guessing",
+			setup:  func(s *Server) { s.EnableAuth(authenticator) },
+			path:   "/api/v1/orders/a",
+			header: func(r *http.Request) { r.Header.Set(auth.APIKeyHeader, "guess") },
+		},
+		{
+			name:   "no credentials",
+			setup:  func(s *Server) { s.EnableAuth(authenticator) },
+			path:   "/api/v1/orders/a",
+			header: func(r *http.Request) {},
+		},
+		{
+			name:   "admin token guessing",
+			setup:  func(s *Server) { s.EnableAdmin(NewMockCacheAdmin(gomock.NewController(t)), testAdminToken) },
+			path:   "/admin/cache",
+			header: func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			svc.EXPECT().GetByUIDWithStats(gomock.Any(), "a").
+				Return(&domain.Order{OrderUID: "a"}, service.LookupStats{Source: service.SourceCache}, nil).AnyTimes()
+			s := limitedServer(t, svc, config.HTTP{})
+			tt.setup(s)
+
+			var codes []int
+			for range 3 {
+				req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+				tt.header(req)
+				w := httptest.NewRecorder()
+				s.Handler().ServeHTTP(w, req)
+				codes = append(codes, w.Code)
+			}
+			require.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}, codes)
+		})
+	}
+
+	// Failures from an address do not use up the buckets of callers there.
+	ctrl := gomock.NewController(t)
+	svc := NewMockServerWithStats(ctrl)
+	svc.EXPECT().GetByUIDWithStats(gomock.Any(), "a").
+		Return(&domain.Order{OrderUID: "a"}, service.LookupStats{Source: service.SourceCache}, nil)
+	s := limitedServer(t, svc, config.HTTP{})
+	s.EnableAuth(authenticator)
+	for _, key := range []string{"guess", "guess", "guess", "k"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/a", nil)
+		req.Header.Set(auth.APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, req)
+		if key == "k" {
+			require.Equal(t, http.StatusOK, w.Code)
+		}
+	}
+}
+
+func TestServer_BodyLimit(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	s := limitedServer(t, NewMockServerWithStats(ctrl), config.HTTP{MaxBodyBytes: 32})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/orders",
+		strings.NewReader(`{"order_uid":"`+strings.Repeat("a", 64)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	require.Contains(t, w.Body.String(), `"code": "body_too_large"`)
+	require.Contains(t, w.Body.String(), "exceeds 32 bytes")
+}
@@ -1,16 +1,22 @@
 package httpapi
 
 import (
+	"context"
 	"net/http"
 	"time"
 
 	"github.com/TemirB/wb-tech-L0/internal/observability"
 
-	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/chi/v5"
 )
 
 // ServerTimingApp — middleware that measures the total request processing time and
 // writes app;dur=... to Server-Timing + sends an event to Metrics.ObserveHTTP.
+// The header entry is added just before the header is sent, so it covers the
+// time to the first byte and survives handlers and middleware (compression)
+// that write late; the metric covers the whole request.
+// The route reported is the matched template, such as /api/v1/orders/{uid}, so
+// the set of routes stays bounded; requests matching no route report "unmatched".
 func ServerTimingApp(m observability.Metrics) func(http.Handler) http.Handler {
 	if m == nil {
 		m = observability.Noop{}
@@ -18,12 +24,77 @@ func ServerTimingApp(m observability.Metrics) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
-			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
-			next.ServeHTTP(ww, r)
-			dur := float64(time.Since(start).Microseconds()) / 1000.0
-			observability.AppendServerTiming(w, "app", dur, "")
-			route := r.URL.Path
-			m.ObserveHTTP(r.Method, route, ww.Status(), dur)
+			// The router fills in a route context it finds in the request.
+			rctx := chi.NewRouteContext()
+			r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+			tw := &timingWriter{ResponseWriter: w, start: start}
+			next.ServeHTTP(tw, r)
+			if tw.status == 0 {
+				// Nothing written: net/http sends 200 after we return.
+				tw.WriteHeader(http.StatusOK)
+			}
+			route := rctx.RoutePattern()
+			if route == "" {
+				route = "unmatched"
+			}
+			m.ObserveHTTP(r.Method, route, tw.status, sinceMs(start))
 		})
 	}
 }
+
+// timingWriter adds the app Server-Timing entry as the header goes out.
+type timingWriter struct {
+	http.ResponseWriter
+	start  time.Time
+	status int
+}
+
+func (w *timingWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+		observability.AppendServerTiming(w.ResponseWriter, "app", sinceMs(w.start), "")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timingWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *timingWriter) Flush() {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *timingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func sinceMs(start time.Time) float64 {
+	return float64(time.Since(start).Microseconds()) / 1000.0
+}
+
+// deprecated marks responses of a route superseded by the one successor returns
+// for the request.
+func deprecated(successor func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+successor(r)+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func fixedSuccessor(path string) func(*http.Request) string {
+	return func(*http.Request) string { return path }
+}
+
+func orderSuccessor(r *http.Request) string {
+	return "/api/v1/orders/" + chi.URLParam(r, "uid")
+}
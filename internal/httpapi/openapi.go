@@ -0,0 +1,203 @@
+package httpapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// openapiJSON describes every route of the server. TestSpecCoversRoutes fails
+// for a route without an entry, and EnableContractValidation checks traffic
+// against it.
+//
+//go:embed openapi.json
+var openapiJSON []byte
+
+// docsHTML renders openapiJSON with Swagger UI from a CDN.
+const docsHTML = `<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="utf-8"/>
+    <title>Orders API</title>
+    <meta name="viewport" content="width=device-width, initial-scale=1"/>
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css"/>
+  </head>
+  <body>
+    <div id="swagger-ui"></div>
+    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+    <script>
+      window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+    </script>
+  </body>
+</html>
+`
+
+func (s *Server) openapi(w http.ResponseWriter, r *http.Request) {
+	s.setCacheControl(w, r)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, _ = w.Write(openapiJSON)
+}
+
+func (s *Server) docs(w http.ResponseWriter, r *http.Request) {
+	s.setCacheControl(w, r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(docsHTML))
+}
+
+// Spec is the part of an OpenAPI 3 document that contract validation uses:
+// operations with their parameters, request bodies and responses, and JSON
+// schemas with type, format, enum, nullable, required, properties,
+// additionalProperties, items, oneOf and numeric, length and size bounds. $ref
+// is followed within components.
+type Spec struct {
+	Paths      map[string]map[string]*operation `json:"paths"` // path template, lower-case method
+	Components struct {
+		Schemas    map[string]*schema    `json:"schemas"`
+		Parameters map[string]*parameter `json:"parameters"`
+		Responses  map[string]*response  `json:"responses"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []*parameter         `json:"parameters"`
+	RequestBody *requestBody         `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"` // path, query or header
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Ref     string                `json:"$ref"`
+	Content map[string]*mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []any              `json:"enum"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"` // false or a schema
+	Items                *schema            `json:"items"`
+	OneOf                []*schema          `json:"oneOf"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+}
+
+// LoadSpec parses the server's OpenAPI document.
+func LoadSpec() (*Spec, error) {
+	var sp Spec
+	if err := json.Unmarshal(openapiJSON, &sp); err != nil {
+		return nil, fmt.Errorf("parse openapi.json: %w", err)
+	}
+	return &sp, nil
+}
+
+// HasOperation reports whether the spec documents method on the path template.
+func (sp *Spec) HasOperation(method, path string) bool {
+	_, ok := sp.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// find returns the operation serving method on the request path and the path
+// parameters. A path matching several templates goes to the one with the
+// fewest parameters, the most specific.
+func (sp *Spec) find(method, path string) (*operation, map[string]string, error) {
+	var (
+		best       *operation
+		bestParams map[string]string
+	)
+	for tmpl, ops := range sp.Paths {
+		op, ok := ops[strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+		params, ok := matchPath(tmpl, path)
+		if ok && (best == nil || len(params) < len(bestParams)) {
+			best, bestParams = op, params
+		}
+	}
+	if best == nil {
+		return nil, nil, fmt.Errorf("no operation for %s %s", method, path)
+	}
+	return best, bestParams, nil
+}
+
+// matchPath matches path against a template such as /order/{order_uid}, where
+// a parameter stands for one non-empty segment.
+func matchPath(tmpl, path string) (map[string]string, bool) {
+	ts, ps := strings.Split(tmpl, "/"), strings.Split(path, "/")
+	if len(ts) != len(ps) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, t := range ts {
+		if name, ok := strings.CutPrefix(t, "{"); ok && strings.HasSuffix(name, "}") {
+			if ps[i] == "" {
+				return nil, false
+			}
+			params[strings.TrimSuffix(name, "}")] = ps[i]
+			continue
+		}
+		if t != ps[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+func (sp *Spec) schema(s *schema) (*schema, error) {
+	for s != nil && s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		if !ok || sp.Components.Schemas[name] == nil {
+			return nil, fmt.Errorf("unresolved $ref %q", s.Ref)
+		}
+		s = sp.Components.Schemas[name]
+	}
+	return s, nil
+}
+
+func (sp *Spec) parameter(p *parameter) (*parameter, error) {
+	if p.Ref == "" {
+		return p, nil
+	}
+	name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+	if !ok || sp.Components.Parameters[name] == nil {
+		return nil, fmt.Errorf("unresolved $ref %q", p.Ref)
+	}
+	return sp.Components.Parameters[name], nil
+}
+
+func (sp *Spec) response(r *response) (*response, error) {
+	if r.Ref == "" {
+		return r, nil
+	}
+	name, ok := strings.CutPrefix(r.Ref, "#/components/responses/")
+	if !ok || sp.Components.Responses[name] == nil {
+		return nil, fmt.Errorf("unresolved $ref %q", r.Ref)
+	}
+	return sp.Components.Responses[name], nil
+}
@@ -0,0 +1,33 @@
+package httpapi
+
+import "net/http"
+
+// ReadinessCheck reports the state of one component for GET /readyz.
+// Any check returning ready=false makes the endpoint answer 503.
+type ReadinessCheck func() (state any, ready bool)
+
+type readiness struct {
+	Ready  bool           `json:"ready"`
+	Checks map[string]any `json:"checks"`
+}
+
+// AddReadinessCheck registers check under name. Checks must be added before the
+// server starts.
+func (s *Server) AddReadinessCheck(name string, check ReadinessCheck) {
+	s.checks[name] = check
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	resp := readiness{Ready: true, Checks: make(map[string]any, len(s.checks))}
+	for name, check := range s.checks {
+		state, ready := check()
+		resp.Checks[name] = state
+		resp.Ready = resp.Ready && ready
+	}
+
+	if !resp.Ready {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	writeJSON(w, resp)
+}
@@ -0,0 +1,92 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/TemirB/wb-tech-L0/internal/application/service"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+)
+
+// routeMetrics records the routes passed to ObserveHTTP.
+type routeMetrics struct {
+	observability.Noop
+	mu     sync.Mutex
+	routes []string
+}
+
+func (m *routeMetrics) ObserveHTTP(method, route string, status int, durMs float64) {
+	m.mu.Lock()
+	m.routes = append(m.routes, method+" "+route)
+	m.mu.Unlock()
+}
+
+func TestRouting(t *testing.T) {
+	order := &domain.Order{OrderUID: "a"}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		lookup     string // expected GetByUIDWithStats uid
+		status     int
+		route      string
+		deprecated string // expected Link, empty for current routes
+		allow      string
+	}{
+		{
+			name: "v1 order", method: http.MethodGet, path: "/api/v1/orders/a", lookup: "a",
+			status: http.StatusOK, route: "GET /api/v1/orders/{uid}",
+		},
+		{
+			name: "legacy order", method: http.MethodGet, path: "/order/a", lookup: "a",
+			status: http.StatusOK, route: "GET /order/{uid}",
+			deprecated: `</api/v1/orders/a>; rel="successor-version"`,
+		},
+		{
+			name: "nested path is not a uid", method: http.MethodGet, path: "/order/a/b",
+			status: http.StatusNotFound, route: "GET unmatched",
+		},
+		{
+			name: "method not allowed", method: http.MethodDelete, path: "/api/v1/orders/a",
+			status: http.StatusMethodNotAllowed, route: "DELETE unmatched", allow: "GET",
+		},
+		{
+			name: "unknown path with another method", method: http.MethodPost, path: "/nope",
+			status: http.StatusNotFound, route: "POST unmatched",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			svc := NewMockServerWithStats(ctrl)
+			if tt.lookup != "" {
+				svc.EXPECT().GetByUIDWithStats(gomock.Any(), tt.lookup).
+					Return(order, service.LookupStats{Source: service.SourceCache}, nil)
+			}
+			metrics := &routeMetrics{}
+			s := New(svc, zaptest.NewLogger(t), metrics)
+
+			w := httptest.NewRecorder()
+			ServerTimingApp(metrics)(s.Handler()).ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			require.Equal(t, tt.status, w.Code)
+			require.Equal(t, []string{tt.route}, metrics.routes)
+			if tt.deprecated != "" {
+				require.Equal(t, "true", w.Header().Get("Deprecation"))
+				require.Equal(t, tt.deprecated, w.Header().Get("Link"))
+			} else {
+				require.Empty(t, w.Header().Get("Deprecation"))
+			}
+			require.Equal(t, tt.allow, w.Header().Get("Allow"))
+		})
+	}
+}
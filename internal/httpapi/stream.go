@@ -0,0 +1,131 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/auth"
+	"github.com/TemirB/wb-tech-L0/internal/stream"
+)
+
+const (
+	defaultHeartbeat = 15 * time.Second
+	// streamRetryMs is how long EventSource clients wait before reconnecting.
+	streamRetryMs = "2000"
+)
+
+// EnableOrderStream registers GET /api/v1/orders/stream, a Server-Sent Events
+// stream of the orders published to hub, with a comment every heartbeat to
+// keep idle connections open. Must be called before the server starts.
+func (s *Server) EnableOrderStream(hub *stream.Hub, heartbeat time.Duration) {
+	if heartbeat <= 0 {
+		heartbeat = defaultHeartbeat
+	}
+	s.hub = hub
+	s.heartbeat = heartbeat
+	s.streamStop = make(chan struct{})
+	s.router.Get("/api/v1/orders/stream", s.allow(auth.RoleReader, s.streamOrders))
+}
+
+// streamOrders sends an "order" event per published order matching the
+// customer_id, delivery_service and entry query parameters. A client resuming
+// with Last-Event-ID first gets the buffered events after it, preceded by a
+// "missed" event if some are gone. A client that falls behind gets a
+// "dropped" event and is disconnected, to reconnect and resume.
+func (s *Server) streamOrders(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := stream.Filter{
+		CustomerID:      q.Get("customer_id"),
+		DeliveryService: q.Get("delivery_service"),
+		Entry:           q.Get("entry"),
+	}
+	sub, err := s.hub.Subscribe(filter, r.Header.Get("Last-Event-ID"))
+	switch {
+	case errors.Is(err, stream.ErrBadEventID):
+		http.Error(w, "malformed Last-Event-ID", http.StatusBadRequest)
+		return
+	case errors.Is(err, stream.ErrTooManySubscribers):
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "too many stream subscribers", http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, "Service error", http.StatusInternalServerError)
+		return
+	}
+	defer sub.Close()
+
+	log := s.log(r).With(
+		zap.String("customer_id", filter.CustomerID),
+		zap.String("delivery_service", filter.DeliveryService),
+		zap.String("entry", filter.Entry),
+	)
+	log.Info("order stream opened", zap.Int("replay", len(sub.Replay)), zap.Bool("missed", sub.Missed))
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // keep nginx from holding events back
+	w.WriteHeader(http.StatusOK)
+
+	// The server's WriteTimeout would cut the stream off; each write gets it
+	// instead, so only a client that stops reading is disconnected.
+	rc := http.NewResponseController(w)
+	send := func(frame string) bool {
+		if s.limits.WriteTimeout > 0 {
+			_ = rc.SetWriteDeadline(time.Now().Add(s.limits.WriteTimeout))
+		}
+		if _, err := io.WriteString(w, frame); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !send("retry: " + streamRetryMs + "\n\n") {
+		return
+	}
+	if sub.Missed && !send("event: missed\ndata: {}\n\n") {
+		return
+	}
+	for _, ev := range sub.Replay {
+		if !send(orderEvent(ev)) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Info("order stream closed by the client")
+			return
+		case <-s.streamStop:
+			return
+		case ev, ok := <-sub.Events():
+			if !ok {
+				log.Warn("order stream dropped, the client fell behind")
+				send("event: dropped\ndata: {}\n\n")
+				return
+			}
+			if !send(orderEvent(ev)) {
+				log.Info("order stream write failed")
+				return
+			}
+		case <-heartbeat.C:
+			if !send(": heartbeat\n\n") {
+				return
+			}
+		}
+	}
+}
+
+func orderEvent(ev stream.Event) string {
+	// Compact JSON has no newlines, so it fits one data line.
+	data, _ := json.Marshal(ev.Order)
+	return "event: order\nid: " + ev.ID + "\ndata: " + string(data) + "\n\n"
+}
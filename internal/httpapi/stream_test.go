@@ -0,0 +1,165 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"github.com/TemirB/wb-tech-L0/internal/stream"
+)
+
+// sseClient reads the frames of an event stream, heartbeats included.
+type sseClient struct {
+	resp   *http.Response
+	frames chan string
+	cancel context.CancelFunc
+}
+
+func openStream(t *testing.T, url, lastEventID string) *sseClient {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	c := &sseClient{resp: resp, frames: make(chan string, 100), cancel: cancel}
+	go func() {
+		defer close(c.frames)
+		sc := bufio.NewScanner(resp.Body)
+		var frame []string
+		for sc.Scan() {
+			if sc.Text() != "" {
+				frame = append(frame, sc.Text())
+				continue
+			}
+			select {
+			case c.frames <- strings.Join(frame, "\n"):
+			case <-ctx.Done():
+				return
+			}
+			frame = nil
+		}
+	}()
+	t.Cleanup(func() {
+		cancel()
+		_ = resp.Body.Close()
+	})
+	return c
+}
+
+// next returns the next frame that is not a heartbeat, counting those skipped.
+func (c *sseClient) next(t *testing.T) (frame string, heartbeats int) {
+	t.Helper()
+	for {
+		select {
+		case f, ok := <-c.frames:
+			require.True(t, ok, "stream ended")
+			if f == ": heartbeat" {
+				heartbeats++
+				continue
+			}
+			return f, heartbeats
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+	}
+}
+
+func eventID(frame string) string {
+	for _, line := range strings.Split(frame, "\n") {
+		if id, ok := strings.CutPrefix(line, "id: "); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+func TestServer_OrderStream(t *testing.T) {
+	const writeTimeout = 200 * time.Millisecond
+	hub := stream.New(config.Stream{Buffer: 10, Queue: 10})
+	s := New(NewMockServerWithStats(gomock.NewController(t)), zaptest.NewLogger(t), observability.NewNoop())
+	s.EnableLimits(config.HTTP{WriteTimeout: writeTimeout})
+	require.NoError(t, s.EnableCompression(testCompression))
+	s.EnableOrderStream(hub, 20*time.Millisecond)
+	srv := httptest.NewUnstartedServer(ServerTimingApp(observability.NewNoop())(s.Handler()))
+	srv.Config.WriteTimeout = writeTimeout
+	srv.Start()
+	t.Cleanup(srv.Close) // after the clients' cleanups, which run first
+	url := srv.URL + "/api/v1/orders/stream?customer_id=alice"
+
+	c := openStream(t, url, "")
+	require.Equal(t, http.StatusOK, c.resp.StatusCode)
+	require.Equal(t, "text/event-stream", c.resp.Header.Get("Content-Type"))
+	require.True(t, c.resp.Uncompressed, "the stream is gzipped")
+	require.Contains(t, c.resp.Header.Get("Server-Timing"), "app;dur=")
+	frame, _ := c.next(t)
+	require.Equal(t, "retry: 2000", frame)
+	require.Eventually(t, func() bool { return hub.Subscribers() == 1 }, time.Second, time.Millisecond)
+
+	hub.Publish(&domain.Order{OrderUID: "b", CustomerID: "bob"})
+	hub.Publish(&domain.Order{OrderUID: "c", CustomerID: "alice"})
+	frame, _ = c.next(t)
+	require.Contains(t, frame, "event: order\n")
+	require.Contains(t, frame, `"order_uid":"c"`)
+	resumeFrom := eventID(frame)
+	require.NotEmpty(t, resumeFrom)
+
+	// Idle past the server's write timeout, kept open by heartbeats.
+	time.Sleep(2 * writeTimeout)
+	hub.Publish(&domain.Order{OrderUID: "d", CustomerID: "alice"})
+	frame, heartbeats := c.next(t)
+	require.Contains(t, frame, `"order_uid":"d"`)
+	require.Positive(t, heartbeats)
+
+	c.cancel()
+	require.Eventually(t, func() bool { return hub.Subscribers() == 0 }, time.Second, time.Millisecond)
+
+	// Resuming resends what came after the last event seen.
+	c = openStream(t, url, resumeFrom)
+	_, _ = c.next(t)
+	frame, _ = c.next(t)
+	require.Contains(t, frame, `"order_uid":"d"`)
+
+	// An id from before a restart cannot be resumed exactly.
+	c = openStream(t, url, "old-1")
+	_, _ = c.next(t)
+	frame, _ = c.next(t)
+	require.Equal(t, "event: missed\ndata: {}", frame)
+}
+
+func TestServer_OrderStreamRefused(t *testing.T) {
+	hub := stream.New(config.Stream{Buffer: 10, Queue: 10, MaxSubscribers: 1})
+	s := New(NewMockServerWithStats(gomock.NewController(t)), zaptest.NewLogger(t), observability.NewNoop())
+	s.EnableOrderStream(hub, 0)
+	require.NoError(t, s.EnableContractValidation(func(r *http.Request, err error) {
+		t.Errorf("%s %s: %v", r.Method, r.URL, err)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/orders/stream", nil)
+	req.Header.Set("Last-Event-ID", "nonsense")
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	sub, err := hub.Subscribe(stream.Filter{}, "")
+	require.NoError(t, err)
+	defer sub.Close()
+	w = httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orders/stream", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "5", w.Header().Get("Retry-After"))
+}
@@ -7,8 +7,12 @@ type Inmem struct {
 	last   []*observe
 	max    int
 	totals struct {
-		cacheHits, cacheMiss int
+		cacheHits, cacheMiss       int
+		negativeHits, negativeMiss int
 	}
+	pool        PoolStats
+	cache       CacheStats
+	writeBehind WriteBehindStats
 }
 
 type observe struct {
@@ -103,3 +107,32 @@ func (m *Inmem) IncCacheMiss() {
 	m.totals.cacheMiss++
 	m.mu.Unlock()
 }
+
+func (m *Inmem) IncNegativeCacheHit() {
+	m.mu.Lock()
+	m.totals.negativeHits++
+	m.mu.Unlock()
+}
+func (m *Inmem) IncNegativeCacheMiss() {
+	m.mu.Lock()
+	m.totals.negativeMiss++
+	m.mu.Unlock()
+}
+
+func (m *Inmem) ObserveDBPool(st PoolStats) {
+	m.mu.Lock()
+	m.pool = st
+	m.mu.Unlock()
+}
+
+func (m *Inmem) ObserveCache(st CacheStats) {
+	m.mu.Lock()
+	m.cache = st
+	m.mu.Unlock()
+}
+
+func (m *Inmem) ObserveWriteBehind(st WriteBehindStats) {
+	m.mu.Lock()
+	m.writeBehind = st
+	m.mu.Unlock()
+}
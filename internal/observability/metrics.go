@@ -7,6 +7,47 @@ type Metrics interface {
 	ObserveKafka(processMs float64, ok bool)
 	IncCacheHit()
 	IncCacheMiss()
+	IncNegativeCacheHit()
+	IncNegativeCacheMiss()
+	ObserveDBPool(st PoolStats)
+	ObserveCache(st CacheStats)
+	ObserveWriteBehind(st WriteBehindStats)
+}
+
+// PoolStats is a snapshot of the database connection pool.
+type PoolStats struct {
+	Acquired      int32
+	Idle          int32
+	Total         int32
+	Max           int32
+	Acquires      int64   // cumulative successful acquires
+	EmptyAcquires int64   // cumulative acquires that had to wait for a connection
+	AcquireWaitMs float64 // average acquire wait since the previous snapshot
+}
+
+// CacheStats is a snapshot of the order cache. Counters are cumulative.
+type CacheStats struct {
+	Entries     int
+	Capacity    int
+	Bytes       int64
+	MaxBytes    int64
+	Hits        int64
+	StaleHits   int64 // expired entries served while being refreshed
+	Misses      int64
+	Expirations int64
+	Evictions   map[string]int64 // by reason, expirations excluded
+}
+
+// WriteBehindStats is a snapshot of write-behind ingestion. Counters are cumulative.
+type WriteBehindStats struct {
+	Pending      int     `json:"pending"`       // orders in the log not yet in storage
+	LagMs        float64 `json:"lag_ms"`        // age of the oldest pending order
+	Flushed      int64   `json:"flushed"`       // orders written to storage
+	Flushes      int64   `json:"flushes"`       // successful flushes
+	FlushErrors  int64   `json:"flush_errors"`  // failed flushes, retried later
+	DeadLettered int64   `json:"dead_lettered"` // rejected by storage, moved to the dead-letter file
+	LastFlushMs  float64 `json:"last_flush_ms"`
+	LastError    string  `json:"last_error,omitempty"`
 }
 
 type Noop struct{}
@@ -20,3 +61,8 @@ func (Noop) ObserveHTTP(string, string, int, float64) {}
 func (Noop) ObserveKafka(float64, bool)               {}
 func (Noop) IncCacheHit()                             {}
 func (Noop) IncCacheMiss()                            {}
+func (Noop) IncNegativeCacheHit()                     {}
+func (Noop) IncNegativeCacheMiss()                    {}
+func (Noop) ObserveDBPool(PoolStats)                  {}
+func (Noop) ObserveCache(CacheStats)                  {}
+func (Noop) ObserveWriteBehind(WriteBehindStats)      {}
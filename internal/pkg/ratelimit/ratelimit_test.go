@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := New(config.Limit{Rate: 2, Burst: 3})
+	l.now = func() time.Time { return now }
+
+	for i := 2; i >= 0; i-- {
+		d := l.Allow("a")
+		require.True(t, d.Allowed)
+		require.Equal(t, 3, d.Limit)
+		require.Equal(t, i, d.Remaining)
+	}
+
+	d := l.Allow("a")
+	require.False(t, d.Allowed)
+	require.Equal(t, 0, d.Remaining)
+	require.Equal(t, 500*time.Millisecond, d.RetryAfter)
+	require.Equal(t, 1500*time.Millisecond, d.Reset)
+
+	// Another key has its own bucket.
+	require.True(t, l.Allow("b").Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	require.True(t, l.Allow("a").Allowed)
+	require.False(t, l.Allow("a").Allowed)
+
+	// Refilled buckets are dropped on the next sweep.
+	now = now.Add(sweepEvery)
+	l.Allow("c")
+	require.Equal(t, 1, l.Len())
+}
+
+func TestNewUnlimited(t *testing.T) {
+	require.Nil(t, New(config.Limit{}))
+	require.Equal(t, 1, New(config.Limit{Rate: 1}).Allow("a").Limit)
+}
@@ -0,0 +1,338 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"go.uber.org/zap"
+)
+
+// compactMinRecords and compactRatio decide when the log is rewritten: once it holds
+// more than compactMinRecords records and compactRatio times as many as live orders.
+const (
+	compactMinRecords = 1000
+	compactRatio      = 2
+)
+
+// File is an embedded, file-backed order store for laptop demos. Every write is
+// appended to an NDJSON log and fsynced before it becomes visible; reads are served
+// from an in-memory copy rebuilt by replaying the log on open. The log is compacted
+// when superseded records start to dominate it.
+type File struct {
+	mem    *Memory
+	logger *zap.Logger
+
+	mu      sync.Mutex // serialises writes to the log
+	path    string
+	f       *os.File
+	records int
+}
+
+// OpenFile opens (or creates) the store at path and replays its log.
+// A torn record at the end of the log, left by a crash mid-write, is discarded;
+// a corrupt record followed by others is an error.
+func OpenFile(path string, logger *zap.Logger) (*File, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &File{mem: NewMemory(), logger: logger, path: path, f: f}
+	if err := s.replay(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("replay %s: %w", path, err)
+	}
+	if s.needsCompaction() {
+		if err := s.compact(); err != nil {
+			s.f.Close()
+			return nil, fmt.Errorf("compact %s: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+func (s *File) replay() error {
+	r := bufio.NewReader(s.f)
+	var good int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Anything after the last newline is a torn write.
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var o domain.Order
+		if err := json.Unmarshal(bytes.TrimSpace(line), &o); err != nil {
+			// Only the last record can be torn; valid ones after a corrupt
+			// record must not be truncated away.
+			if _, perr := r.Peek(1); perr == nil {
+				return fmt.Errorf("corrupt record at offset %d: %w", good, err)
+			}
+			break
+		}
+		s.mem.mu.Lock()
+		s.mem.put(&o)
+		s.mem.mu.Unlock()
+		s.records++
+		good += int64(len(line))
+	}
+	return s.truncate(good)
+}
+
+func (s *File) Upsert(ctx context.Context, o *domain.Order) error {
+	return s.UpsertBatch(ctx, []*domain.Order{o})
+}
+
+func (s *File) UpsertBatch(ctx context.Context, orders []*domain.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return os.ErrClosed
+	}
+
+	if err := s.append(orders); err != nil {
+		return err
+	}
+	s.maybeCompact()
+	return nil
+}
+
+// UpsertIf upserts o if the stored order passes p, else it returns
+// domain.ErrVersionMismatch.
+func (s *File) UpsertIf(ctx context.Context, o *domain.Order, p domain.Precondition) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.f == nil {
+		return os.ErrClosed
+	}
+	s.mem.mu.RLock()
+	stored := s.mem.nextVersion(o.OrderUID) - 1
+	s.mem.mu.RUnlock()
+	if !p.Allows(stored) {
+		return domain.ErrVersionMismatch
+	}
+
+	if err := s.append([]*domain.Order{o}); err != nil {
+		return err
+	}
+	s.maybeCompact()
+	return nil
+}
+
+// append assigns versions and update times, writes the orders to the log and then makes them visible.
+// The caller holds s.mu, which makes File the only writer of s.mem, so the orders
+// are stored exactly as logged.
+func (s *File) append(orders []*domain.Order) error {
+	s.mem.mu.Lock()
+	defer s.mem.mu.Unlock()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	versions := make(map[string]int64, len(orders))
+	for _, o := range orders {
+		if v, ok := versions[o.OrderUID]; ok {
+			o.Version = v + 1
+		} else {
+			o.Version = s.mem.nextVersion(o.OrderUID)
+		}
+		now := time.Now().UTC()
+		o.UpdatedAt = &now
+		versions[o.OrderUID] = o.Version
+		if err := enc.Encode(o); err != nil {
+			return err
+		}
+	}
+
+	if err := s.write(buf.Bytes()); err != nil {
+		return err
+	}
+	s.records += len(orders)
+	for _, o := range orders {
+		s.mem.put(o)
+	}
+	return nil
+}
+
+// write appends b to the log and fsyncs it. If either fails, the log is
+// truncated back to its previous size so that no torn record is left for later
+// appends to follow; if even that fails, the store is closed.
+func (s *File) write(b []byte) error {
+	info, err := s.f.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = s.f.Write(b)
+	if err == nil {
+		err = s.f.Sync()
+	}
+	if err == nil {
+		return nil
+	}
+	if terr := s.truncate(info.Size()); terr != nil {
+		s.f.Close()
+		s.f = nil
+		return errors.Join(err, fmt.Errorf("truncate %s: %w", s.path, terr))
+	}
+	return err
+}
+
+// truncate cuts the log to size and moves the write offset there.
+func (s *File) truncate(size int64) error {
+	if err := s.f.Truncate(size); err != nil {
+		return err
+	}
+	if _, err := s.f.Seek(size, io.SeekStart); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+func (s *File) GetByUID(ctx context.Context, uid string) (*domain.Order, error) {
+	return s.mem.GetByUID(ctx, uid)
+}
+
+func (s *File) GetByUIDs(ctx context.Context, uids []string) (map[string]*domain.Order, error) {
+	return s.mem.GetByUIDs(ctx, uids)
+}
+
+func (s *File) GetByTrackNumber(ctx context.Context, track string) (*domain.Order, error) {
+	return s.mem.GetByTrackNumber(ctx, track)
+}
+
+func (s *File) CustomerOrderIDs(ctx context.Context, customerID string, limit int) ([]string, error) {
+	return s.mem.CustomerOrderIDs(ctx, customerID, limit)
+}
+
+func (s *File) RecentOrderIDs(ctx context.Context, limit int) ([]string, error) {
+	return s.mem.RecentOrderIDs(ctx, limit)
+}
+
+// AddReads keeps read counters in memory only; they are lost on restart.
+func (s *File) AddReads(ctx context.Context, reads map[string]int64) error {
+	return s.mem.AddReads(ctx, reads)
+}
+
+func (s *File) FrequentOrderIDs(ctx context.Context, limit int) ([]string, error) {
+	return s.mem.FrequentOrderIDs(ctx, limit)
+}
+
+func (s *File) Versions(ctx context.Context, uids []string) (map[string]int64, error) {
+	return s.mem.Versions(ctx, uids)
+}
+
+func (s *File) StreamOrders(ctx context.Context, f domain.OrderFilter, fn func(*domain.Order) error) error {
+	return s.mem.StreamOrders(ctx, f, fn)
+}
+
+// Close flushes and closes the log; the store must not be used afterwards.
+func (s *File) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
+
+func (s *File) needsCompaction() bool {
+	return s.records > compactMinRecords && s.records > compactRatio*s.mem.Len()
+}
+
+// maybeCompact compacts the log if it is due. The write that triggered it is
+// already durable and visible, so a failure is only logged; the next write
+// tries again.
+func (s *File) maybeCompact() {
+	if !s.needsCompaction() {
+		return
+	}
+	if err := s.compact(); err != nil {
+		s.logger.Error("failed to compact file storage", zap.String("path", s.path), zap.Error(err))
+	}
+}
+
+// compact rewrites the log with one record per live order and atomically
+// replaces the old one, then keeps appending through the handle it wrote with,
+// so there is no reopen to fail after the rename. If the rename cannot be made
+// durable, the store is closed rather than appending to a log a crash may lose.
+// The caller holds s.mu (or has exclusive access).
+func (s *File) compact() error {
+	tmp := s.path + ".compact"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	n := 0
+	err = s.mem.StreamOrders(context.Background(), domain.OrderFilter{}, func(o *domain.Order) error {
+		n++
+		return enc.Encode(o)
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp, s.path)
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	s.f.Close()
+	s.f = f
+	s.records = n
+	if err := syncDir(filepath.Dir(s.path)); err != nil {
+		s.f.Close()
+		s.f = nil
+		return fmt.Errorf("sync directory of %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// syncDir fsyncs a directory, making renames and creations in it durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/storage/storagetest"
+)
+
+func openTestFile(t *testing.T, path string) *File {
+	t.Helper()
+	s, err := OpenFile(path, zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestFileConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Repo {
+		return openTestFile(t, filepath.Join(t.TempDir(), "orders.db"))
+	})
+}
+
+func TestFileReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data", "orders.db")
+
+	s, err := OpenFile(path, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, s.Upsert(ctx, storagetest.NewOrder("a", 0)))
+	require.NoError(t, s.Upsert(ctx, storagetest.NewOrder("b", time.Hour)))
+	updated := storagetest.NewOrder("a", 2*time.Hour)
+	require.NoError(t, s.Upsert(ctx, updated))
+	require.NoError(t, s.Close())
+
+	s = openTestFile(t, path)
+	got, err := s.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, updated.DateCreated.Equal(got.DateCreated))
+
+	ids, err := s.RecentOrderIDs(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, ids)
+}
+
+func TestFileDiscardsTornRecord(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+
+	s, err := OpenFile(path, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, s.Upsert(ctx, storagetest.NewOrder("a", 0)))
+	require.NoError(t, s.Close())
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"order_uid":"torn","track`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	s = openTestFile(t, path)
+	_, err = s.GetByUID(ctx, "torn")
+	require.Error(t, err)
+	require.NoError(t, s.Upsert(ctx, storagetest.NewOrder("b", time.Hour)))
+	require.NoError(t, s.Close())
+
+	s = openTestFile(t, path)
+	ids, err := s.RecentOrderIDs(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"b", "a"}, ids)
+}
+
+func TestFileRefusesCorruptRecord(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+
+	s, err := OpenFile(path, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, s.Upsert(ctx, storagetest.NewOrder("a", 0)))
+	require.NoError(t, s.Close())
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("{\"order_uid\":\"torn\",\"track\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	s, err = OpenFile(path, zap.NewNop())
+	require.NoError(t, err, "a corrupt last record is a torn write")
+	require.NoError(t, s.Upsert(ctx, storagetest.NewOrder("b", 0)))
+	require.NoError(t, s.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "torn")
+	corrupt := strings.Replace(string(data), `"order_uid":"a"`, `"order_uid":"a`, 1)
+	require.NoError(t, os.WriteFile(path, []byte(corrupt), 0o644))
+	_, err = OpenFile(path, zap.NewNop())
+	require.ErrorContains(t, err, "corrupt record at offset 0", "later records are not dropped")
+}
+
+func TestFileCompaction(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+	s := openTestFile(t, path)
+
+	for i := 0; i <= compactMinRecords; i++ {
+		require.NoError(t, s.Upsert(ctx, storagetest.NewOrder(fmt.Sprintf("o%d", i%10), 0)))
+	}
+	require.Equal(t, 10, s.records)
+
+	require.NoError(t, s.Close())
+	s = openTestFile(t, path)
+	require.Equal(t, 10, s.mem.Len())
+}
+
+func TestFileCompactionKeepsAppending(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+	s := openTestFile(t, path)
+
+	for i := 0; i <= compactMinRecords; i++ {
+		require.NoError(t, s.Upsert(ctx, storagetest.NewOrder(fmt.Sprintf("o%d", i%10), 0)))
+	}
+	require.NoError(t, s.Upsert(ctx, storagetest.NewOrder("after", 0)))
+
+	require.NoError(t, s.Close())
+	s = openTestFile(t, path)
+	_, err := s.GetByUID(ctx, "after")
+	require.NoError(t, err, "a write after compaction goes to the new log")
+}
+
+func TestFileCompactionFailureKeepsWrite(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+	s := openTestFile(t, path)
+	// A directory in the way makes compaction fail.
+	require.NoError(t, os.Mkdir(path+".compact", 0o755))
+
+	for i := 0; i <= compactMinRecords; i++ {
+		require.NoError(t, s.Upsert(ctx, storagetest.NewOrder(fmt.Sprintf("o%d", i%10), 0)))
+	}
+	require.Equal(t, compactMinRecords+1, s.records, "not compacted")
+
+	require.NoError(t, s.Close())
+	require.NoError(t, os.Remove(path+".compact"))
+	s = openTestFile(t, path)
+	require.Equal(t, 10, s.mem.Len())
+	require.Equal(t, 10, s.records, "compacted on open")
+}
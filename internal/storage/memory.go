@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+// Memory is a thread-safe in-memory order store for tests and local runs.
+// Orders are copied on the way in and out, so callers never share state with it.
+// Like database.Repo, every upsert bumps the order version, stamps the update
+// time and writes both back into the caller's order.
+type Memory struct {
+	mu     sync.RWMutex
+	orders map[string]*domain.Order
+	reads  map[string]int64
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		orders: make(map[string]*domain.Order),
+		reads:  make(map[string]int64),
+	}
+}
+
+func (m *Memory) Upsert(ctx context.Context, o *domain.Order) error {
+	return m.UpsertBatch(ctx, []*domain.Order{o})
+}
+
+func (m *Memory) UpsertBatch(ctx context.Context, orders []*domain.Order) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range orders {
+		m.upsert(o)
+	}
+	return nil
+}
+
+// UpsertIf upserts o if the stored order passes p, else it returns
+// domain.ErrVersionMismatch.
+func (m *Memory) UpsertIf(ctx context.Context, o *domain.Order, p domain.Precondition) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !p.Allows(m.nextVersion(o.OrderUID) - 1) {
+		return domain.ErrVersionMismatch
+	}
+	m.upsert(o)
+	return nil
+}
+
+// upsert stamps o with the next version and the time and stores it. The
+// caller holds m.mu.
+func (m *Memory) upsert(o *domain.Order) {
+	now := time.Now().UTC()
+	o.Version = m.nextVersion(o.OrderUID)
+	o.UpdatedAt = &now
+	m.put(o)
+}
+
+// nextVersion returns the version the next upsert of uid gets. The caller holds m.mu.
+func (m *Memory) nextVersion(uid string) int64 {
+	if prev, ok := m.orders[uid]; ok {
+		return prev.Version + 1
+	}
+	return 1
+}
+
+// put stores a copy of o as is, version included. The caller holds m.mu.
+func (m *Memory) put(o *domain.Order) {
+	m.orders[o.OrderUID] = cloneOrder(o)
+}
+
+func (m *Memory) GetByUID(ctx context.Context, uid string) (*domain.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	o, ok := m.orders[uid]
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+	return cloneOrder(o), nil
+}
+
+// GetByUIDs returns copies of the orders in uids that exist, keyed by UID.
+func (m *Memory) GetByUIDs(ctx context.Context, uids []string) (map[string]*domain.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]*domain.Order, len(uids))
+	for _, uid := range uids {
+		if o, ok := m.orders[uid]; ok {
+			out[uid] = cloneOrder(o)
+		}
+	}
+	return out, nil
+}
+
+// GetByTrackNumber returns a copy of the newest order with the track number.
+func (m *Memory) GetByTrackNumber(ctx context.Context, track string) (*domain.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ids := m.newest(1, func(o *domain.Order) bool { return o.TrackNumber == track })
+	if len(ids) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return cloneOrder(m.orders[ids[0]]), nil
+}
+
+// CustomerOrderIDs returns the IDs of the customer's orders, newest first.
+func (m *Memory) CustomerOrderIDs(ctx context.Context, customerID string, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.newest(limit, func(o *domain.Order) bool { return o.CustomerID == customerID }), nil
+}
+
+// RecentOrderIDs mirrors the Postgres query: newest date_created first, at most limit IDs.
+func (m *Memory) RecentOrderIDs(ctx context.Context, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.newest(limit, func(*domain.Order) bool { return true }), nil
+}
+
+// newest returns the IDs of up to limit orders matching keep, newest date_created
+// first; a negative limit returns all of them. Ties are broken by order_uid to
+// keep the result deterministic. The caller holds m.mu.
+func (m *Memory) newest(limit int, keep func(*domain.Order) bool) []string {
+	var all []*domain.Order
+	for _, o := range m.orders {
+		if keep(o) {
+			all = append(all, o)
+		}
+	}
+
+	slices.SortFunc(all, func(a, b *domain.Order) int {
+		if c := b.DateCreated.Compare(a.DateCreated); c != 0 {
+			return c
+		}
+		return strings.Compare(a.OrderUID, b.OrderUID)
+	})
+	if limit >= 0 && len(all) > limit {
+		all = all[:limit]
+	}
+
+	ids := make([]string, len(all))
+	for i, o := range all {
+		ids[i] = o.OrderUID
+	}
+	return ids
+}
+
+// AddReads adds to the read counters FrequentOrderIDs ranks by.
+func (m *Memory) AddReads(ctx context.Context, reads map[string]int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for uid, n := range reads {
+		m.reads[uid] += n
+	}
+	return nil
+}
+
+// FrequentOrderIDs returns existing orders with recorded reads, most read first,
+// ties broken by order_uid.
+func (m *Memory) FrequentOrderIDs(ctx context.Context, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	ids := make([]string, 0, len(m.reads))
+	reads := make(map[string]int64, len(m.reads))
+	for uid, n := range m.reads {
+		if _, ok := m.orders[uid]; ok {
+			ids = append(ids, uid)
+			reads[uid] = n
+		}
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(ids, func(a, b string) int {
+		if c := cmp.Compare(reads[b], reads[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	if limit >= 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+// StreamOrders calls fn for every order matching f, oldest first, like database.Repo.
+func (m *Memory) StreamOrders(ctx context.Context, f domain.OrderFilter, fn func(*domain.Order) error) error {
+	m.mu.RLock()
+	var matched []*domain.Order
+	for _, o := range m.orders {
+		if matches(o, f) {
+			matched = append(matched, cloneOrder(o))
+		}
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(matched, func(a, b *domain.Order) int {
+		if c := a.DateCreated.Compare(b.DateCreated); c != 0 {
+			return c
+		}
+		return strings.Compare(a.OrderUID, b.OrderUID)
+	})
+	for _, o := range matched {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Versions returns the stored version of every order in uids that exists.
+func (m *Memory) Versions(ctx context.Context, uids []string) (map[string]int64, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make(map[string]int64, len(uids))
+	for _, uid := range uids {
+		if o, ok := m.orders[uid]; ok {
+			out[uid] = o.Version
+		}
+	}
+	return out, nil
+}
+
+// Len returns the number of stored orders.
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.orders)
+}
+
+func matches(o *domain.Order, f domain.OrderFilter) bool {
+	if !f.From.IsZero() && o.DateCreated.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !o.DateCreated.Before(f.To) {
+		return false
+	}
+	return f.CustomerID == "" || o.CustomerID == f.CustomerID
+}
+
+func cloneOrder(o *domain.Order) *domain.Order {
+	c := *o
+	c.Items = slices.Clone(o.Items)
+	return &c
+}
@@ -0,0 +1,13 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/TemirB/wb-tech-L0/internal/storage/storagetest"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storagetest.Repo {
+		return NewMemory()
+	})
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+// Repo is everything the application needs from an order store. database.Repo
+// (Postgres), Memory and File implement it and are checked against the same
+// conformance suite in storagetest.
+type Repo interface {
+	Upsert(ctx context.Context, o *domain.Order) error
+	UpsertBatch(ctx context.Context, orders []*domain.Order) error
+	UpsertIf(ctx context.Context, o *domain.Order, p domain.Precondition) error
+	GetByUID(ctx context.Context, uid string) (*domain.Order, error)
+	GetByUIDs(ctx context.Context, uids []string) (map[string]*domain.Order, error)
+	GetByTrackNumber(ctx context.Context, track string) (*domain.Order, error)
+	CustomerOrderIDs(ctx context.Context, customerID string, limit int) ([]string, error)
+	RecentOrderIDs(ctx context.Context, limit int) ([]string, error)
+	FrequentOrderIDs(ctx context.Context, limit int) ([]string, error)
+	AddReads(ctx context.Context, reads map[string]int64) error
+	Versions(ctx context.Context, uids []string) (map[string]int64, error)
+	StreamOrders(ctx context.Context, f domain.OrderFilter, fn func(*domain.Order) error) error
+}
@@ -0,0 +1,393 @@
+// Package storagetest is a conformance suite every order store must pass, so the
+// in-memory, file and Postgres backends stay interchangeable.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+// Repo mirrors storage.Repo; it is redeclared here so backends can run the suite
+// from their own package without an import cycle.
+type Repo interface {
+	Upsert(ctx context.Context, o *domain.Order) error
+	UpsertBatch(ctx context.Context, orders []*domain.Order) error
+	UpsertIf(ctx context.Context, o *domain.Order, p domain.Precondition) error
+	GetByUID(ctx context.Context, uid string) (*domain.Order, error)
+	GetByUIDs(ctx context.Context, uids []string) (map[string]*domain.Order, error)
+	GetByTrackNumber(ctx context.Context, track string) (*domain.Order, error)
+	CustomerOrderIDs(ctx context.Context, customerID string, limit int) ([]string, error)
+	RecentOrderIDs(ctx context.Context, limit int) ([]string, error)
+	FrequentOrderIDs(ctx context.Context, limit int) ([]string, error)
+	AddReads(ctx context.Context, reads map[string]int64) error
+	Versions(ctx context.Context, uids []string) (map[string]int64, error)
+	StreamOrders(ctx context.Context, f domain.OrderFilter, fn func(*domain.Order) error) error
+}
+
+// Run runs the suite. newRepo must return an empty store for every call.
+func Run(t *testing.T, newRepo func(t *testing.T) Repo) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, r Repo)
+	}{
+		{"GetByUIDNotFound", testGetByUIDNotFound},
+		{"UpsertAndGet", testUpsertAndGet},
+		{"UpsertReplaces", testUpsertReplaces},
+		{"UpsertMovesDate", testUpsertMovesDate},
+		{"ReturnedOrderIsACopy", testReturnedOrderIsACopy},
+		{"GetByUIDs", testGetByUIDs},
+		{"GetByTrackNumber", testGetByTrackNumber},
+		{"CustomerOrderIDs", testCustomerOrderIDs},
+		{"RecentOrderIDs", testRecentOrderIDs},
+		{"FrequentOrderIDs", testFrequentOrderIDs},
+		{"UpsertBatch", testUpsertBatch},
+		{"UpsertIf", testUpsertIf},
+		{"StreamOrders", testStreamOrders},
+		{"Versions", testVersions},
+		{"ConcurrentUpserts", testConcurrentUpserts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepo(t))
+		})
+	}
+}
+
+var base = time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+// NewOrder builds a complete order created offset after a fixed base time.
+func NewOrder(uid string, offset time.Duration) *domain.Order {
+	return &domain.Order{
+		OrderUID:    uid,
+		TrackNumber: "TRACK-" + uid,
+		Entry:       "WBIL",
+		Delivery: domain.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: domain.Payment{
+			Transaction: "tx-" + uid, Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []domain.Item{
+			{ChrtID: 1, TrackNumber: "TRACK-" + uid, Price: 453, RID: "rid-1-" + uid, Name: "Mascaras",
+				Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202},
+			{ChrtID: 2, TrackNumber: "TRACK-" + uid, Price: 100, RID: "rid-2-" + uid, Name: "Lipstick",
+				Size: "1", TotalPrice: 100, NmID: 1, Brand: "Brand", Status: 202},
+		},
+		Locale:          "en",
+		CustomerID:      "customer-" + uid,
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     base.Add(offset),
+		OofShard:        "1",
+	}
+}
+
+// requireOrder compares orders ignoring the time zone of DateCreated and the
+// version and update time, which storage assigns.
+func requireOrder(t *testing.T, want, got *domain.Order) {
+	t.Helper()
+	require.NotNil(t, got)
+	require.True(t, want.DateCreated.Equal(got.DateCreated), "date_created: want %v, got %v", want.DateCreated, got.DateCreated)
+	w, g := *want, *got
+	w.DateCreated, g.DateCreated = time.Time{}, time.Time{}
+	w.Version, g.Version = 0, 0
+	w.UpdatedAt, g.UpdatedAt = nil, nil
+	require.Equal(t, w, g)
+}
+
+func testGetByUIDNotFound(t *testing.T, r Repo) {
+	_, err := r.GetByUID(context.Background(), "missing")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testUpsertAndGet(t *testing.T, r Repo) {
+	ctx := context.Background()
+	o := NewOrder("a", 0)
+	require.NoError(t, r.Upsert(ctx, o))
+
+	got, err := r.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	requireOrder(t, o, got)
+}
+
+func testUpsertReplaces(t *testing.T, r Repo) {
+	ctx := context.Background()
+	require.NoError(t, r.Upsert(ctx, NewOrder("a", 0)))
+
+	o := NewOrder("a", 0)
+	o.TrackNumber = "NEW"
+	o.Delivery.City = "Moscow"
+	o.Payment.Amount = 1
+	o.Items = o.Items[:1]
+	require.NoError(t, r.Upsert(ctx, o))
+
+	got, err := r.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	requireOrder(t, o, got)
+}
+
+func testUpsertMovesDate(t *testing.T, r Repo) {
+	ctx := context.Background()
+	require.NoError(t, r.Upsert(ctx, NewOrder("a", 0)))
+	require.NoError(t, r.Upsert(ctx, NewOrder("b", time.Hour)))
+
+	// Moving "a" to a later month must not leave a copy behind at the old date.
+	moved := NewOrder("a", 60*24*time.Hour)
+	require.NoError(t, r.Upsert(ctx, moved))
+
+	got, err := r.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	requireOrder(t, moved, got)
+
+	ids, err := r.RecentOrderIDs(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, ids)
+}
+
+func testReturnedOrderIsACopy(t *testing.T, r Repo) {
+	ctx := context.Background()
+	o := NewOrder("a", 0)
+	require.NoError(t, r.Upsert(ctx, o))
+	o.Items[0].Name = "changed after upsert"
+
+	got, err := r.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	got.Items[0].Price = -1
+
+	again, err := r.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	requireOrder(t, NewOrder("a", 0), again)
+}
+
+func testRecentOrderIDs(t *testing.T, r Repo) {
+	ctx := context.Background()
+
+	ids, err := r.RecentOrderIDs(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	for i, off := range []time.Duration{3, 1, 4, 2} {
+		require.NoError(t, r.Upsert(ctx, NewOrder(fmt.Sprintf("o%d", i), off*time.Hour)))
+	}
+
+	ids, err = r.RecentOrderIDs(ctx, 3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"o2", "o0", "o3"}, ids)
+
+	ids, err = r.RecentOrderIDs(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"o2", "o0", "o3", "o1"}, ids)
+}
+
+func testGetByTrackNumber(t *testing.T, r Repo) {
+	ctx := context.Background()
+	_, err := r.GetByTrackNumber(ctx, "TRACK-a")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	a := NewOrder("a", 0)
+	require.NoError(t, r.UpsertBatch(ctx, []*domain.Order{a, NewOrder("b", 0)}))
+	got, err := r.GetByTrackNumber(ctx, "TRACK-a")
+	require.NoError(t, err)
+	requireOrder(t, a, got)
+
+	// A reused track number resolves to the newest order.
+	newer := NewOrder("c", time.Hour)
+	newer.TrackNumber = "TRACK-a"
+	require.NoError(t, r.Upsert(ctx, newer))
+	got, err = r.GetByTrackNumber(ctx, "TRACK-a")
+	require.NoError(t, err)
+	require.Equal(t, "c", got.OrderUID)
+}
+
+func testCustomerOrderIDs(t *testing.T, r Repo) {
+	ctx := context.Background()
+	for i, off := range []time.Duration{3, 1, 4, 2} {
+		o := NewOrder(fmt.Sprintf("o%d", i), off*time.Hour)
+		if i != 3 {
+			o.CustomerID = "alice"
+		}
+		require.NoError(t, r.Upsert(ctx, o))
+	}
+
+	ids, err := r.CustomerOrderIDs(ctx, "alice", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"o2", "o0", "o1"}, ids)
+
+	ids, err = r.CustomerOrderIDs(ctx, "alice", 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"o2", "o0"}, ids)
+
+	ids, err = r.CustomerOrderIDs(ctx, "bob", 10)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+}
+
+func testGetByUIDs(t *testing.T, r Repo) {
+	ctx := context.Background()
+	a, b := NewOrder("a", 0), NewOrder("b", time.Hour)
+	require.NoError(t, r.UpsertBatch(ctx, []*domain.Order{a, b, NewOrder("c", 0)}))
+
+	got, err := r.GetByUIDs(ctx, []string{"b", "missing", "a"})
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	requireOrder(t, a, got["a"])
+	requireOrder(t, b, got["b"])
+	require.Equal(t, a.Version, got["a"].Version)
+
+	got, err = r.GetByUIDs(ctx, nil)
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func testFrequentOrderIDs(t *testing.T, r Repo) {
+	ctx := context.Background()
+	for _, uid := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, r.Upsert(ctx, NewOrder(uid, 0)))
+	}
+
+	ids, err := r.FrequentOrderIDs(ctx, 10)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	require.NoError(t, r.AddReads(ctx, map[string]int64{"a": 1, "b": 5, "c": 2}))
+	require.NoError(t, r.AddReads(ctx, map[string]int64{"a": 5, "missing": 100}))
+
+	// Reads accumulate; UIDs without an order are never returned.
+	ids, err = r.FrequentOrderIDs(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, ids)
+
+	ids, err = r.FrequentOrderIDs(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, ids)
+}
+
+func testUpsertBatch(t *testing.T, r Repo) {
+	ctx := context.Background()
+	orders := []*domain.Order{NewOrder("a", 0), NewOrder("b", time.Hour), NewOrder("c", 2*time.Hour)}
+	require.NoError(t, r.UpsertBatch(ctx, orders))
+	require.NotSame(t, orders[0].UpdatedAt, orders[1].UpdatedAt, "orders of a batch share no state")
+
+	for _, o := range orders {
+		got, err := r.GetByUID(ctx, o.OrderUID)
+		require.NoError(t, err)
+		requireOrder(t, o, got)
+	}
+}
+
+func testUpsertIf(t *testing.T, r Repo) {
+	ctx := context.Background()
+	a := NewOrder("a", 0)
+	require.ErrorIs(t, r.UpsertIf(ctx, a, domain.Precondition{Any: true}), domain.ErrVersionMismatch,
+		"an order that is not stored never passes")
+	require.NoError(t, r.Upsert(ctx, a))
+	read := a.Version
+
+	first, second := NewOrder("a", 0), NewOrder("a", 0)
+	first.Entry, second.Entry = "first", "second"
+	require.NoError(t, r.UpsertIf(ctx, first, domain.Precondition{Versions: []int64{read}}))
+	require.ErrorIs(t, r.UpsertIf(ctx, second, domain.Precondition{Versions: []int64{read}}), domain.ErrVersionMismatch,
+		"the second writer of the same version loses")
+	got, err := r.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, "first", got.Entry)
+	require.Equal(t, first.Version, got.Version)
+
+	require.NoError(t, r.UpsertIf(ctx, second, domain.Precondition{Any: true}))
+	require.Greater(t, second.Version, first.Version)
+}
+
+func testStreamOrders(t *testing.T, r Repo) {
+	ctx := context.Background()
+	a, b, c := NewOrder("a", 0), NewOrder("b", time.Hour), NewOrder("c", 2*time.Hour)
+	c.CustomerID = a.CustomerID
+	require.NoError(t, r.UpsertBatch(ctx, []*domain.Order{c, b, a}))
+
+	collect := func(f domain.OrderFilter) []string {
+		var ids []string
+		require.NoError(t, r.StreamOrders(ctx, f, func(o *domain.Order) error {
+			ids = append(ids, o.OrderUID)
+			return nil
+		}))
+		return ids
+	}
+
+	require.Equal(t, []string{"a", "b", "c"}, collect(domain.OrderFilter{}))
+	require.Equal(t, []string{"b"}, collect(domain.OrderFilter{From: b.DateCreated, To: c.DateCreated}))
+	require.Equal(t, []string{"a", "c"}, collect(domain.OrderFilter{CustomerID: a.CustomerID}))
+
+	var got *domain.Order
+	require.NoError(t, r.StreamOrders(ctx, domain.OrderFilter{To: b.DateCreated}, func(o *domain.Order) error {
+		got = o
+		return nil
+	}))
+	requireOrder(t, a, got)
+}
+
+func testVersions(t *testing.T, r Repo) {
+	ctx := context.Background()
+
+	a := NewOrder("a", 0)
+	a.Version = 42 // incoming versions are ignored
+	require.NoError(t, r.Upsert(ctx, a))
+	require.Equal(t, int64(1), a.Version)
+	require.NoError(t, r.Upsert(ctx, NewOrder("b", 0)))
+
+	a = NewOrder("a", 0)
+	before := time.Now().Add(-time.Second)
+	require.NoError(t, r.Upsert(ctx, a))
+	require.Equal(t, int64(2), a.Version)
+	require.NotNil(t, a.UpdatedAt)
+	require.True(t, a.UpdatedAt.After(before), "updated_at %v is before the upsert", a.UpdatedAt)
+	updated := *a.UpdatedAt
+
+	// Moving to another date keeps counting.
+	a = NewOrder("a", 40*24*time.Hour)
+	require.NoError(t, r.Upsert(ctx, a))
+	require.Equal(t, int64(3), a.Version)
+
+	got, err := r.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), got.Version)
+	require.NotNil(t, got.UpdatedAt)
+	require.True(t, got.UpdatedAt.Equal(*a.UpdatedAt), "stored updated_at %v, returned %v", got.UpdatedAt, a.UpdatedAt)
+	require.False(t, got.UpdatedAt.Before(updated))
+
+	versions, err := r.Versions(ctx, []string{"a", "b", "missing"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"a": 3, "b": 1}, versions)
+}
+
+func testConcurrentUpserts(t *testing.T, r Repo) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				o := NewOrder(fmt.Sprintf("w%d-%d", i, j), time.Duration(i*10+j)*time.Minute)
+				assert.NoError(t, r.Upsert(ctx, o))
+				_, err := r.GetByUID(ctx, o.OrderUID)
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	ids, err := r.RecentOrderIDs(ctx, 1000)
+	require.NoError(t, err)
+	require.Len(t, ids, 80)
+	require.Equal(t, "w7-9", ids[0])
+}
@@ -0,0 +1,661 @@
+package storage
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/observability"
+	"go.uber.org/zap"
+)
+
+const walSuffix = ".wal"
+
+// deadLetterName is the file in the log directory that orders the Repo
+// rejects are moved to, one JSON deadLetter per line.
+const deadLetterName = "dead-letter.ndjson"
+
+// finalFlushTimeout bounds the flush when Run is stopped.
+const finalFlushTimeout = 10 * time.Second
+
+// WriteBehind wraps a Repo for write-behind ingestion. Upsert and UpsertBatch
+// return as soon as the orders are appended to a local write-ahead log and
+// fsynced; Run writes them to the Repo in batches. The log is kept in numbered
+// segment files that are deleted once everything in them is stored, and
+// whatever is left is replayed when the store is opened again. UpsertIf, which
+// has to check the stored version, writes to the Repo directly.
+//
+// Until they are flushed, pending orders take precedence over the Repo in
+// GetByUID, GetByUIDs, GetByTrackNumber and CustomerOrderIDs, so reads agree
+// with the cache. Other reads (warm-up selection, versions, exports) see only
+// the Repo. Unlike the other stores, versions are assigned when an order is
+// flushed, not written back into the caller's order.
+type WriteBehind struct {
+	Repo
+
+	cfg     config.WriteBehind
+	logger  *zap.Logger
+	metrics observability.Metrics
+	now     func() time.Time
+	kick    chan struct{}
+	flushMu sync.Mutex // one flush at a time
+
+	mu      sync.Mutex
+	seg     *os.File // segment being appended to; nil until the next write
+	segSeq  uint64   // number of seg, or of the next segment
+	pending []pendingOrder
+	byUID   map[string]*domain.Order // newest pending copy of each order
+	space   chan struct{}            // closed and replaced after every flush
+	closed  bool
+
+	stats observability.WriteBehindStats
+}
+
+type pendingOrder struct {
+	order    *domain.Order
+	appended time.Time
+}
+
+// deadLetter is a record of the dead-letter file.
+type deadLetter struct {
+	Order    *domain.Order `json:"order"`
+	Error    string        `json:"error"`
+	FailedAt time.Time     `json:"failed_at"`
+}
+
+// OpenWriteBehind wraps repo, creating cfg.Dir if needed and loading the orders
+// left in its log as pending. A torn record at the end of a segment, left by a
+// crash mid-write, is discarded; a corrupt record followed by others is an
+// error.
+func OpenWriteBehind(repo Repo, cfg config.WriteBehind, logger *zap.Logger, metrics observability.Metrics) (*WriteBehind, error) {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 200 * time.Millisecond
+	}
+	if metrics == nil {
+		metrics = observability.Noop{}
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	w := &WriteBehind{
+		Repo:    repo,
+		cfg:     cfg,
+		logger:  logger,
+		metrics: metrics,
+		now:     time.Now,
+		kick:    make(chan struct{}, 1),
+		segSeq:  1,
+		byUID:   make(map[string]*domain.Order),
+		space:   make(chan struct{}),
+	}
+	segs, err := w.segments()
+	if err != nil {
+		return nil, err
+	}
+	for _, seq := range segs {
+		if err := w.replay(seq); err != nil {
+			return nil, fmt.Errorf("replay %s: %w", w.segPath(seq), err)
+		}
+		w.segSeq = seq + 1
+	}
+	if len(w.pending) > 0 {
+		logger.Info("write-behind log replayed",
+			zap.String("dir", cfg.Dir),
+			zap.Int("segments", len(segs)),
+			zap.Int("orders", len(w.pending)),
+		)
+	}
+	return w, nil
+}
+
+func (w *WriteBehind) segPath(seq uint64) string {
+	return filepath.Join(w.cfg.Dir, fmt.Sprintf("%020d%s", seq, walSuffix))
+}
+
+// segments lists the numbers of the segment files, oldest first.
+func (w *WriteBehind) segments() ([]uint64, error) {
+	entries, err := os.ReadDir(w.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []uint64
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), walSuffix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if seq, err := strconv.ParseUint(name, 10, 64); err == nil {
+			segs = append(segs, seq)
+		}
+	}
+	slices.Sort(segs)
+	return segs, nil
+}
+
+// replay loads the orders of segment seq as pending, dated by the segment's
+// modification time.
+func (w *WriteBehind) replay(seq uint64) error {
+	f, err := os.Open(w.segPath(seq))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				w.logger.Warn("discarding torn write-behind record", zap.String("segment", f.Name()))
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var o domain.Order
+		if err := json.Unmarshal(line, &o); err != nil {
+			// Records after a corrupt one were acknowledged; dropping them
+			// would lose them.
+			if _, perr := r.Peek(1); perr == nil {
+				return fmt.Errorf("corrupt record followed by others: %w", err)
+			}
+			w.logger.Warn("discarding corrupt write-behind record", zap.String("segment", f.Name()), zap.Error(err))
+			return nil
+		}
+		w.add(&o, info.ModTime())
+	}
+}
+
+// add makes o pending. The caller holds w.mu or has w to itself.
+func (w *WriteBehind) add(o *domain.Order, appended time.Time) {
+	w.pending = append(w.pending, pendingOrder{order: o, appended: appended})
+	w.byUID[o.OrderUID] = o
+}
+
+func (w *WriteBehind) Upsert(ctx context.Context, o *domain.Order) error {
+	return w.UpsertBatch(ctx, []*domain.Order{o})
+}
+
+// UpsertBatch appends the orders to the log and returns once it is fsynced.
+// While cfg.MaxPending orders are waiting to be flushed it blocks until a flush
+// makes room or ctx is done.
+func (w *WriteBehind) UpsertBatch(ctx context.Context, orders []*domain.Order) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		w.mu.Lock()
+		if w.closed {
+			w.mu.Unlock()
+			return os.ErrClosed
+		}
+		if w.cfg.MaxPending <= 0 || len(w.pending) < w.cfg.MaxPending {
+			break
+		}
+		space := w.space
+		w.mu.Unlock()
+		w.flushSoon()
+		select {
+		case <-space:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	defer w.mu.Unlock()
+
+	if err := w.append(orders); err != nil {
+		return err
+	}
+	now := w.now()
+	for _, o := range orders {
+		w.add(cloneOrder(o), now)
+	}
+	if len(w.pending) >= w.cfg.BatchSize {
+		w.flushSoon()
+	}
+	return nil
+}
+
+// UpsertIf writes o straight to the Repo if the stored order passes p, as
+// pending orders have no version to check. Pending copies of o are flushed
+// first, and no flush runs until the write is done, so an older pending copy
+// cannot overwrite it later.
+func (w *WriteBehind) UpsertIf(ctx context.Context, o *domain.Order, p domain.Precondition) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	if _, ok := w.pendingCopy(o.OrderUID); ok {
+		if err := w.flushLocked(ctx); err != nil {
+			return err
+		}
+	}
+	return w.Repo.UpsertIf(ctx, o, p)
+}
+
+// append writes the orders to the current segment, one JSON line each, and
+// fsyncs it. The caller holds w.mu.
+func (w *WriteBehind) append(orders []*domain.Order) error {
+	if w.seg == nil {
+		f, err := os.OpenFile(w.segPath(w.segSeq), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		w.seg = f
+	}
+	var buf []byte
+	for _, o := range orders {
+		line, err := json.Marshal(o)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	info, err := w.seg.Stat()
+	if err != nil {
+		return err
+	}
+	_, err = w.seg.Write(buf)
+	if err == nil {
+		err = w.seg.Sync()
+	}
+	if err != nil {
+		// Later appends must not follow a torn record: cut it off, or failing
+		// that leave it at the end of the segment, where replay discards it.
+		if terr := w.seg.Truncate(info.Size()); terr != nil {
+			w.logger.Warn("failed to truncate write-behind segment, starting a new one",
+				zap.String("segment", w.seg.Name()), zap.Error(terr))
+			if _, serr := w.seal(); serr != nil {
+				w.logger.Warn("failed to close write-behind segment", zap.Error(serr))
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// seal closes the current segment so that later writes start a new one, and
+// returns the number of the first segment not covered by the pending orders.
+// The caller holds w.mu.
+func (w *WriteBehind) seal() (uint64, error) {
+	if w.seg == nil {
+		return w.segSeq, nil
+	}
+	err := w.seg.Close()
+	w.seg = nil
+	w.segSeq++
+	return w.segSeq, err
+}
+
+func (w *WriteBehind) flushSoon() {
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Flush writes the orders pending now to the Repo, cfg.BatchSize per UpsertBatch,
+// and deletes the log segments holding them. A batch the Repo fails is written
+// again order by order; an order that fails on its own while others are
+// written is rejected, and is moved to the dead-letter file so that it does not
+// hold up the rest. If no order can be written, the Repo is taken to be down:
+// the orders stay pending and the next flush retries them.
+func (w *WriteBehind) Flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	return w.flushLocked(ctx)
+}
+
+// flushLocked is Flush for a caller holding w.flushMu.
+func (w *WriteBehind) flushLocked(ctx context.Context) error {
+	w.mu.Lock()
+	n := len(w.pending)
+	if n == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	batch := latest(w.pending[:n])
+	upTo, err := w.seal()
+	w.mu.Unlock()
+	if err != nil {
+		w.logger.Warn("failed to close write-behind segment", zap.Error(err))
+	}
+
+	start := w.now()
+	var rejected []deadLetter
+	var lastErr error
+	written := 0
+	for chunk := range slices.Chunk(batch, w.cfg.BatchSize) {
+		err := w.Repo.UpsertBatch(ctx, chunk)
+		if err == nil {
+			written += len(chunk)
+			continue
+		}
+		if ctx.Err() != nil {
+			return w.flushFailed(err)
+		}
+		for _, o := range chunk {
+			if err := w.Repo.UpsertBatch(ctx, []*domain.Order{o}); err != nil {
+				if ctx.Err() != nil {
+					return w.flushFailed(err)
+				}
+				rejected = append(rejected, deadLetter{Order: o, Error: err.Error(), FailedAt: w.now()})
+				lastErr = err
+			} else {
+				written++
+			}
+		}
+	}
+	if len(rejected) > 0 {
+		if written == 0 {
+			return w.flushFailed(lastErr)
+		}
+		if err := w.deadLetter(rejected); err != nil {
+			return w.flushFailed(fmt.Errorf("write dead letters: %w", err))
+		}
+		for _, d := range rejected {
+			w.logger.Error("write-behind order rejected, moved to the dead-letter file",
+				zap.String("order_uid", d.Order.OrderUID), zap.String("error", d.Error))
+		}
+	}
+
+	w.mu.Lock()
+	for _, p := range w.pending[:n] {
+		if w.byUID[p.order.OrderUID] == p.order {
+			delete(w.byUID, p.order.OrderUID)
+		}
+	}
+	w.pending = slices.Clone(w.pending[n:])
+	w.stats.Flushed += int64(n - len(rejected))
+	w.stats.DeadLettered += int64(len(rejected))
+	w.stats.Flushes++
+	w.stats.LastFlushMs = float64(w.now().Sub(start).Microseconds()) / 1000
+	w.stats.LastError = ""
+	close(w.space)
+	w.space = make(chan struct{})
+	w.mu.Unlock()
+
+	return w.removeSegments(upTo)
+}
+
+// flushFailed counts a failed flush and returns err.
+func (w *WriteBehind) flushFailed(err error) error {
+	w.mu.Lock()
+	w.stats.FlushErrors++
+	w.stats.LastError = err.Error()
+	w.mu.Unlock()
+	return err
+}
+
+// deadLetter appends the rejected orders to the dead-letter file and fsyncs it.
+func (w *WriteBehind) deadLetter(rejected []deadLetter) error {
+	var buf []byte
+	for _, d := range rejected {
+		line, err := json.Marshal(d)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	f, err := os.OpenFile(filepath.Join(w.cfg.Dir, deadLetterName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return syncDir(w.cfg.Dir)
+}
+
+// latest returns copies of the newest pending copy of each order, in the order
+// of their last write, as UpsertBatch takes every order_uid once.
+func latest(pending []pendingOrder) []*domain.Order {
+	seen := make(map[string]struct{}, len(pending))
+	orders := make([]*domain.Order, 0, len(pending))
+	for i := len(pending) - 1; i >= 0; i-- {
+		o := pending[i].order
+		if _, ok := seen[o.OrderUID]; ok {
+			continue
+		}
+		seen[o.OrderUID] = struct{}{}
+		orders = append(orders, cloneOrder(o))
+	}
+	slices.Reverse(orders)
+	return orders
+}
+
+// removeSegments deletes the segments numbered below upTo.
+func (w *WriteBehind) removeSegments(upTo uint64) error {
+	segs, err := w.segments()
+	if err != nil {
+		return err
+	}
+	for _, seq := range segs {
+		if seq >= upTo {
+			break
+		}
+		if err := os.Remove(w.segPath(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run flushes every cfg.FlushInterval, and as soon as a batch is full, until
+// ctx is done; then it flushes once more and closes the log. Orders that fail to
+// flush on the way out stay in the log for the next start.
+func (w *WriteBehind) Run(ctx context.Context) {
+	t := time.NewTicker(w.cfg.FlushInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+			w.flush(flushCtx)
+			cancel()
+			w.close()
+			return
+		case <-t.C:
+		case <-w.kick:
+		}
+		w.flush(ctx)
+	}
+}
+
+func (w *WriteBehind) flush(ctx context.Context) {
+	if err := w.Flush(ctx); err != nil && ctx.Err() == nil {
+		w.logger.Error("write-behind flush failed", zap.Int("pending", w.Stats().Pending), zap.Error(err))
+	}
+	w.metrics.ObserveWriteBehind(w.Stats())
+}
+
+func (w *WriteBehind) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if _, err := w.seal(); err != nil {
+		w.logger.Warn("failed to close write-behind segment", zap.Error(err))
+	}
+	if len(w.pending) > 0 {
+		w.logger.Warn("write-behind orders left in the log for the next start", zap.Int("orders", len(w.pending)))
+	}
+}
+
+// Stats returns the pending orders, the flush lag and the flush counters.
+func (w *WriteBehind) Stats() observability.WriteBehindStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st := w.stats
+	st.Pending = len(w.pending)
+	if len(w.pending) > 0 {
+		st.LagMs = float64(w.now().Sub(w.pending[0].appended).Milliseconds())
+	}
+	return st
+}
+
+// Readiness reports the stats; the service is not ready while the flush lag
+// exceeds cfg.MaxLag, if set.
+func (w *WriteBehind) Readiness() (any, bool) {
+	st := w.Stats()
+	return st, w.cfg.MaxLag <= 0 || st.LagMs <= float64(w.cfg.MaxLag.Milliseconds())
+}
+
+// pendingCopy returns a copy of the pending order uid.
+func (w *WriteBehind) pendingCopy(uid string) (*domain.Order, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	o, ok := w.byUID[uid]
+	if !ok {
+		return nil, false
+	}
+	return cloneOrder(o), true
+}
+
+func (w *WriteBehind) GetByUID(ctx context.Context, uid string) (*domain.Order, error) {
+	if o, ok := w.pendingCopy(uid); ok {
+		return o, nil
+	}
+	return w.Repo.GetByUID(ctx, uid)
+}
+
+func (w *WriteBehind) GetByUIDs(ctx context.Context, uids []string) (map[string]*domain.Order, error) {
+	found := make(map[string]*domain.Order, len(uids))
+	missing := make([]string, 0, len(uids))
+	for _, uid := range uids {
+		if o, ok := w.pendingCopy(uid); ok {
+			found[uid] = o
+		} else {
+			missing = append(missing, uid)
+		}
+	}
+	if len(missing) == 0 {
+		return found, nil
+	}
+	stored, err := w.Repo.GetByUIDs(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for uid, o := range stored {
+		found[uid] = o
+	}
+	return found, nil
+}
+
+// GetByTrackNumber prefers the newest pending order with the track number over
+// the stored one, and ignores a stored order whose pending copy has another.
+func (w *WriteBehind) GetByTrackNumber(ctx context.Context, track string) (*domain.Order, error) {
+	pending := w.pendingMatching(func(o *domain.Order) bool { return o.TrackNumber == track })
+
+	stored, err := w.Repo.GetByTrackNumber(ctx, track)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		return nil, err
+	}
+	if stored != nil {
+		if _, ok := w.pendingCopy(stored.OrderUID); !ok {
+			pending = append(pending, stored)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return slices.MaxFunc(pending, func(a, b *domain.Order) int {
+		return a.DateCreated.Compare(b.DateCreated)
+	}), nil
+}
+
+// CustomerOrderIDs merges the customer's pending orders with the stored ones,
+// newest first like the Repo. Stored orders with a pending copy are left out,
+// so storage is asked for as many more IDs as may be left out.
+func (w *WriteBehind) CustomerOrderIDs(ctx context.Context, customerID string, limit int) ([]string, error) {
+	pending := w.pendingMatching(func(o *domain.Order) bool { return o.CustomerID == customerID })
+
+	n := limit
+	if limit > 0 {
+		n += len(pending)
+	}
+	var ids []string
+	for {
+		all, err := w.Repo.CustomerOrderIDs(ctx, customerID, n)
+		if err != nil {
+			return nil, err
+		}
+		// A pending copy is among pending, or has moved to another customer.
+		ids = slices.DeleteFunc(all, func(uid string) bool {
+			_, ok := w.pendingCopy(uid)
+			return ok
+		})
+		if limit <= 0 || len(ids) >= limit || len(all) < n {
+			break
+		}
+		n = limit + n - len(ids)
+	}
+	if len(pending) == 0 {
+		return ids, nil
+	}
+
+	// The dates to merge by.
+	stored, err := w.Repo.GetByUIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	orders := pending
+	for _, uid := range ids {
+		if o, ok := stored[uid]; ok {
+			orders = append(orders, o)
+		}
+	}
+	slices.SortFunc(orders, func(a, b *domain.Order) int {
+		if c := b.DateCreated.Compare(a.DateCreated); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.OrderUID, b.OrderUID)
+	})
+	if limit > 0 && len(orders) > limit {
+		orders = orders[:limit]
+	}
+	ids = make([]string, len(orders))
+	for i, o := range orders {
+		ids[i] = o.OrderUID
+	}
+	return ids, nil
+}
+
+// pendingMatching returns copies of the newest pending orders that match.
+func (w *WriteBehind) pendingMatching(match func(*domain.Order) bool) []*domain.Order {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var orders []*domain.Order
+	for _, o := range w.byUID {
+		if match(o) {
+			orders = append(orders, cloneOrder(o))
+		}
+	}
+	return orders
+}
@@ -0,0 +1,304 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/TemirB/wb-tech-L0/internal/storage/storagetest"
+)
+
+// failingRepo fails UpsertBatch while err is set.
+type failingRepo struct {
+	*Memory
+	err error
+}
+
+func (r *failingRepo) UpsertBatch(ctx context.Context, orders []*domain.Order) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.Memory.UpsertBatch(ctx, orders)
+}
+
+func openTestWriteBehind(t *testing.T, repo Repo, cfg config.WriteBehind) *WriteBehind {
+	t.Helper()
+	if cfg.Dir == "" {
+		cfg.Dir = t.TempDir()
+	}
+	w, err := OpenWriteBehind(repo, cfg, zap.NewNop(), nil)
+	require.NoError(t, err)
+	return w
+}
+
+func TestWriteBehindFlush(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	w := openTestWriteBehind(t, mem, config.WriteBehind{BatchSize: 2})
+
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("a", 0)))
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("b", time.Hour)))
+	updated := storagetest.NewOrder("a", 2*time.Hour)
+	require.NoError(t, w.Upsert(ctx, updated))
+
+	_, err := mem.GetByUID(ctx, "a")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+	got, err := w.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, updated.DateCreated.Equal(got.DateCreated))
+	require.Equal(t, 3, w.Stats().Pending)
+
+	require.NoError(t, w.Flush(ctx))
+	got, err = mem.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, updated.DateCreated.Equal(got.DateCreated))
+	require.Equal(t, int64(1), got.Version)
+
+	st := w.Stats()
+	require.Zero(t, st.Pending)
+	require.Equal(t, int64(3), st.Flushed)
+	segs, err := w.segments()
+	require.NoError(t, err)
+	require.Empty(t, segs)
+}
+
+func TestWriteBehindReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := config.WriteBehind{Dir: dir, BatchSize: 10}
+	w := openTestWriteBehind(t, NewMemory(), cfg)
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("a", 0)))
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("b", time.Hour)))
+
+	// Crash: nothing flushed, the segment left open, a record torn mid-write.
+	f, err := os.OpenFile(w.segPath(1), os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"order_uid":"torn","track`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	mem := NewMemory()
+	w = openTestWriteBehind(t, mem, cfg)
+	require.Equal(t, 2, w.Stats().Pending)
+	_, err = w.GetByUID(ctx, "torn")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("c", 2*time.Hour)))
+	require.NoError(t, w.Flush(ctx))
+	ids, err := mem.RecentOrderIDs(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"c", "b", "a"}, ids)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestWriteBehindReplayRefusesCorruptRecord(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.WriteBehind{Dir: t.TempDir(), BatchSize: 10}
+	w := openTestWriteBehind(t, NewMemory(), cfg)
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("a", 0)))
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("b", time.Hour)))
+
+	data, err := os.ReadFile(w.segPath(1))
+	require.NoError(t, err)
+	corrupt := strings.Replace(string(data), `"order_uid":"a"`, `"order_uid":"a`, 1)
+	require.NoError(t, os.WriteFile(w.segPath(1), []byte(corrupt), 0o644))
+
+	// b was acknowledged, so it must not be dropped silently.
+	_, err = OpenWriteBehind(NewMemory(), cfg, zap.NewNop(), nil)
+	require.ErrorContains(t, err, "corrupt record followed by others")
+}
+
+func TestWriteBehindFlushErrorKeepsPending(t *testing.T) {
+	ctx := context.Background()
+	repo := &failingRepo{Memory: NewMemory(), err: errors.New("db down")}
+	w := openTestWriteBehind(t, repo, config.WriteBehind{BatchSize: 10, MaxLag: time.Minute})
+	now := time.Now()
+	w.now = func() time.Time { return now }
+
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("a", 0)))
+	require.Error(t, w.Flush(ctx))
+	st := w.Stats()
+	require.Equal(t, 1, st.Pending)
+	require.Equal(t, int64(1), st.FlushErrors)
+	require.Equal(t, "db down", st.LastError)
+
+	now = now.Add(2 * time.Minute)
+	_, ready := w.Readiness()
+	require.False(t, ready)
+
+	repo.err = nil
+	require.NoError(t, w.Flush(ctx))
+	_, ready = w.Readiness()
+	require.True(t, ready)
+	_, err := repo.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	segs, err := w.segments()
+	require.NoError(t, err)
+	require.Empty(t, segs)
+}
+
+func TestWriteBehindPendingReads(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	stored := storagetest.NewOrder("a", 0)
+	require.NoError(t, mem.Upsert(ctx, stored))
+	require.NoError(t, mem.Upsert(ctx, storagetest.NewOrder("b", time.Hour)))
+	w := openTestWriteBehind(t, mem, config.WriteBehind{BatchSize: 10})
+
+	moved := storagetest.NewOrder("a", 2*time.Hour)
+	moved.TrackNumber = "TRACK-moved"
+	moved.CustomerID = "customer-b"
+	require.NoError(t, w.Upsert(ctx, moved))
+
+	_, err := w.GetByTrackNumber(ctx, "TRACK-a")
+	require.ErrorIs(t, err, domain.ErrNotFound)
+	got, err := w.GetByTrackNumber(ctx, "TRACK-moved")
+	require.NoError(t, err)
+	require.Equal(t, "a", got.OrderUID)
+
+	ids, err := w.CustomerOrderIDs(ctx, "customer-a", 10)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+	ids, err = w.CustomerOrderIDs(ctx, "customer-b", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, ids)
+
+	found, err := w.GetByUIDs(ctx, []string{"a", "b", "c"})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	require.Equal(t, "TRACK-moved", found["a"].TrackNumber)
+}
+
+func TestWriteBehindMaxPending(t *testing.T) {
+	ctx := context.Background()
+	w := openTestWriteBehind(t, NewMemory(), config.WriteBehind{
+		Dir:        filepath.Join(t.TempDir(), "wal"),
+		BatchSize:  10,
+		MaxPending: 1,
+	})
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("a", 0)))
+
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, w.Upsert(short, storagetest.NewOrder("b", 0)), context.DeadlineExceeded)
+
+	runCtx, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.Run(runCtx)
+	}()
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("b", 0)))
+	stop()
+	<-done
+
+	require.ErrorIs(t, w.Upsert(ctx, storagetest.NewOrder("c", 0)), os.ErrClosed)
+	require.Zero(t, w.Stats().Pending)
+}
+
+func TestWriteBehindUpsertIf(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	w := openTestWriteBehind(t, mem, config.WriteBehind{BatchSize: 10})
+	require.NoError(t, mem.Upsert(ctx, storagetest.NewOrder("a", 0)))
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("a", time.Hour)))
+
+	// The pending copy is flushed first, so it is the version checked.
+	cond := storagetest.NewOrder("a", 2*time.Hour)
+	require.ErrorIs(t, w.UpsertIf(ctx, cond, domain.Precondition{Versions: []int64{1}}), domain.ErrVersionMismatch)
+	require.Zero(t, w.Stats().Pending)
+	require.NoError(t, w.UpsertIf(ctx, cond, domain.Precondition{Versions: []int64{2}}))
+
+	got, err := w.GetByUID(ctx, "a")
+	require.NoError(t, err)
+	require.True(t, cond.DateCreated.Equal(got.DateCreated))
+	require.Equal(t, int64(3), got.Version)
+}
+
+func TestWriteBehindCustomerOrderIDsMerges(t *testing.T) {
+	ctx := context.Background()
+	mem := NewMemory()
+	for i, uid := range []string{"s1", "s2", "s3"} {
+		o := storagetest.NewOrder(uid, time.Duration(i*2)*time.Hour)
+		o.CustomerID = "c"
+		require.NoError(t, mem.Upsert(ctx, o))
+	}
+	w := openTestWriteBehind(t, mem, config.WriteBehind{BatchSize: 10})
+
+	// Pending: one between the stored orders, and a newer copy of the newest one.
+	p := storagetest.NewOrder("p", 3*time.Hour)
+	p.CustomerID = "c"
+	require.NoError(t, w.Upsert(ctx, p))
+	s3 := storagetest.NewOrder("s3", 5*time.Hour)
+	s3.CustomerID = "c"
+	require.NoError(t, w.Upsert(ctx, s3))
+
+	ids, err := w.CustomerOrderIDs(ctx, "c", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"s3", "p", "s2", "s1"}, ids)
+
+	// The pending copy of s3 does not cut the stored orders short.
+	ids, err = w.CustomerOrderIDs(ctx, "c", 3)
+	require.NoError(t, err)
+	require.Equal(t, []string{"s3", "p", "s2"}, ids)
+}
+
+// rejectingRepo rejects the orders in reject, on their own or in a batch.
+type rejectingRepo struct {
+	*Memory
+	reject map[string]bool
+}
+
+func (r *rejectingRepo) UpsertBatch(ctx context.Context, orders []*domain.Order) error {
+	for _, o := range orders {
+		if r.reject[o.OrderUID] {
+			return errors.New("invalid order " + o.OrderUID)
+		}
+	}
+	return r.Memory.UpsertBatch(ctx, orders)
+}
+
+func TestWriteBehindDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo := &rejectingRepo{Memory: NewMemory(), reject: map[string]bool{"bad": true}}
+	w := openTestWriteBehind(t, repo, config.WriteBehind{Dir: dir, BatchSize: 10})
+
+	for _, uid := range []string{"a", "bad", "b"} {
+		require.NoError(t, w.Upsert(ctx, storagetest.NewOrder(uid, 0)))
+	}
+	require.NoError(t, w.Flush(ctx))
+
+	st := w.Stats()
+	require.Zero(t, st.Pending)
+	require.Equal(t, int64(2), st.Flushed)
+	require.Equal(t, int64(1), st.DeadLettered)
+	ids, err := repo.RecentOrderIDs(ctx, 10)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a", "b"}, ids)
+
+	data, err := os.ReadFile(filepath.Join(dir, deadLetterName))
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"order_uid":"bad"`)
+	require.Contains(t, string(data), `"error":"invalid order bad"`)
+	segs, err := w.segments()
+	require.NoError(t, err)
+	require.Empty(t, segs)
+
+	// Alone, a rejected order cannot be told from storage being down.
+	require.NoError(t, w.Upsert(ctx, storagetest.NewOrder("bad", 0)))
+	require.Error(t, w.Flush(ctx))
+	require.Equal(t, 1, w.Stats().Pending)
+}
@@ -0,0 +1,207 @@
+// Package stream fans upserted orders out to subscribers, such as the clients
+// of GET /api/v1/orders/stream, without ever blocking the publisher.
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+var (
+	// ErrTooManySubscribers is returned by Subscribe at the subscriber limit.
+	ErrTooManySubscribers = errors.New("stream: too many subscribers")
+	// ErrBadEventID is returned by Subscribe for a Last-Event-ID this package
+	// did not issue.
+	ErrBadEventID = errors.New("stream: malformed event id")
+)
+
+// Event is an upserted order and its position in the stream. IDs have the form
+// <run>-<seq>: run tells the hub's lifetimes apart, so an ID from before a
+// restart is not mistaken for a recent one.
+type Event struct {
+	ID    string
+	Order *domain.Order
+
+	seq uint64
+}
+
+// Filter selects the orders a subscriber wants; empty fields match anything.
+type Filter struct {
+	CustomerID      string
+	DeliveryService string
+	Entry           string
+}
+
+// Match reports whether o passes every set field of f.
+func (f Filter) Match(o *domain.Order) bool {
+	return (f.CustomerID == "" || o.CustomerID == f.CustomerID) &&
+		(f.DeliveryService == "" || o.DeliveryService == f.DeliveryService) &&
+		(f.Entry == "" || o.Entry == f.Entry)
+}
+
+// Hub keeps the latest events in a ring buffer and passes new ones to its
+// subscribers. A subscriber whose queue is full when an event arrives is
+// dropped: its channel is closed and it has to subscribe again, resuming from
+// the buffer.
+type Hub struct {
+	mu      sync.Mutex
+	run     string
+	seq     uint64
+	ring    []Event // the last len(ring) events, the oldest at head once full
+	head    int
+	full    bool
+	subs    map[*Subscription]struct{}
+	queue   int
+	maxSubs int
+}
+
+// New returns a hub for cfg.
+func New(cfg config.Stream) *Hub {
+	return &Hub{
+		run:     strconv.FormatInt(time.Now().UnixNano(), 36),
+		ring:    make([]Event, max(cfg.Buffer, 0)),
+		subs:    make(map[*Subscription]struct{}),
+		queue:   max(cfg.Queue, 1),
+		maxSubs: cfg.MaxSubscribers,
+	}
+}
+
+// Publish adds an event for o and offers it to the matching subscribers.
+func (h *Hub) Publish(o *domain.Order) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	ev := Event{ID: h.run + "-" + strconv.FormatUint(h.seq, 10), Order: o, seq: h.seq}
+	if len(h.ring) > 0 {
+		h.ring[h.head] = ev
+		h.head = (h.head + 1) % len(h.ring)
+		h.full = h.full || h.head == 0
+	}
+	for sub := range h.subs {
+		if !sub.filter.Match(o) {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			h.drop(sub)
+			sub.dropped = true
+		}
+	}
+}
+
+// Subscribe registers a subscriber for the events matching f. With a
+// lastEventID, the buffered events after it come first in Replay; Missed tells
+// that some fell out of the buffer, or that it is from before a restart.
+func (h *Hub) Subscribe(f Filter, lastEventID string) (*Subscription, error) {
+	var run string
+	var after uint64
+	if lastEventID != "" {
+		var err error
+		if run, after, err = parseID(lastEventID); err != nil {
+			return nil, err
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maxSubs > 0 && len(h.subs) >= h.maxSubs {
+		return nil, ErrTooManySubscribers
+	}
+	sub := &Subscription{h: h, filter: f, ch: make(chan Event, h.queue)}
+	if lastEventID != "" {
+		buffered := h.buffered()
+		oldest := h.seq + 1 - uint64(len(buffered))
+		switch {
+		case run != h.run || after > h.seq:
+			// From before a restart: everything this run kept is new.
+			after, sub.Missed = 0, true
+		case after+1 < oldest:
+			sub.Missed = true
+		}
+		for _, ev := range buffered {
+			if ev.seq > after && f.Match(ev.Order) {
+				sub.Replay = append(sub.Replay, ev)
+			}
+		}
+	}
+	h.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Subscribers returns how many subscribers there are.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// buffered returns the buffered events, oldest first.
+func (h *Hub) buffered() []Event {
+	if !h.full {
+		return h.ring[:h.head]
+	}
+	return append(h.ring[h.head:len(h.ring):len(h.ring)], h.ring[:h.head]...)
+}
+
+func (h *Hub) drop(sub *Subscription) {
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+func parseID(id string) (string, uint64, error) {
+	run, seq, ok := strings.Cut(id, "-")
+	if !ok || run == "" {
+		return "", 0, fmt.Errorf("%w: %q", ErrBadEventID, id)
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %q", ErrBadEventID, id)
+	}
+	return run, n, nil
+}
+
+// Subscription receives the events of a Hub matching its filter.
+type Subscription struct {
+	// Replay holds the buffered events after the Last-Event-ID, to send before
+	// those from Events.
+	Replay []Event
+	// Missed tells that events after the Last-Event-ID are no longer buffered.
+	Missed bool
+
+	h       *Hub
+	filter  Filter
+	ch      chan Event
+	dropped bool // guarded by h.mu
+}
+
+// Events delivers new events. It is closed when the subscriber falls behind
+// by more than the queue (see Dropped) or is closed.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped reports whether Events was closed because the subscriber fell
+// behind.
+func (s *Subscription) Dropped() bool {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	return s.dropped
+}
+
+// Close unsubscribes.
+func (s *Subscription) Close() {
+	s.h.mu.Lock()
+	defer s.h.mu.Unlock()
+	s.h.drop(s)
+}
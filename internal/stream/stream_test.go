@@ -0,0 +1,116 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/TemirB/wb-tech-L0/internal/config"
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func uids(events []Event) []string {
+	var out []string
+	for _, ev := range events {
+		out = append(out, ev.Order.OrderUID)
+	}
+	return out
+}
+
+func receive(t *testing.T, sub *Subscription, n int) []Event {
+	t.Helper()
+	var out []Event
+	for range n {
+		select {
+		case ev, ok := <-sub.Events():
+			require.True(t, ok, "events closed")
+			out = append(out, ev)
+		default:
+			t.Fatalf("got %d of %d events", len(out), n)
+		}
+	}
+	return out
+}
+
+func TestHub_PublishFilters(t *testing.T) {
+	h := New(config.Stream{Buffer: 10, Queue: 10})
+	all, err := h.Subscribe(Filter{}, "")
+	require.NoError(t, err)
+	alice, err := h.Subscribe(Filter{CustomerID: "alice", Entry: "WBIL"}, "")
+	require.NoError(t, err)
+
+	h.Publish(&domain.Order{OrderUID: "a", CustomerID: "alice", Entry: "WBIL"})
+	h.Publish(&domain.Order{OrderUID: "b", CustomerID: "bob", Entry: "WBIL"})
+	h.Publish(&domain.Order{OrderUID: "c", CustomerID: "alice", Entry: "OTHER"})
+
+	events := receive(t, all, 3)
+	require.Equal(t, []string{"a", "b", "c"}, uids(events))
+	require.NotEqual(t, events[0].ID, events[1].ID)
+	require.Equal(t, []string{"a"}, uids(receive(t, alice, 1)))
+	require.Empty(t, alice.Events())
+
+	alice.Close()
+	alice.Close()
+	require.Equal(t, 1, h.Subscribers())
+}
+
+func TestHub_Resume(t *testing.T) {
+	h := New(config.Stream{Buffer: 3, Queue: 10})
+	for _, uid := range []string{"a", "b", "c", "d", "e"} {
+		h.Publish(&domain.Order{OrderUID: uid, Entry: uid})
+	}
+	first, err := h.Subscribe(Filter{}, "")
+	require.NoError(t, err)
+	require.Empty(t, first.Replay)
+	h.Publish(&domain.Order{OrderUID: "f"})
+	last := receive(t, first, 1)[0].ID
+
+	tests := []struct {
+		name   string
+		id     string
+		filter Filter
+		replay []string
+		missed bool
+	}{
+		{name: "within the buffer", id: h.run + "-4", replay: []string{"e", "f"}},
+		{name: "filtered", id: h.run + "-4", filter: Filter{Entry: "e"}, replay: []string{"e"}},
+		{name: "up to date", id: last},
+		{name: "older than the buffer", id: h.run + "-1", replay: []string{"d", "e", "f"}, missed: true},
+		{name: "previous run", id: "x-5", replay: []string{"d", "e", "f"}, missed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := h.Subscribe(tt.filter, tt.id)
+			require.NoError(t, err)
+			defer sub.Close()
+			require.Equal(t, tt.replay, uids(sub.Replay))
+			require.Equal(t, tt.missed, sub.Missed)
+		})
+	}
+
+	_, err = h.Subscribe(Filter{}, "nonsense")
+	require.ErrorIs(t, err, ErrBadEventID)
+}
+
+func TestHub_DropsSlowSubscribers(t *testing.T) {
+	h := New(config.Stream{Buffer: 10, Queue: 2, MaxSubscribers: 2})
+	slow, err := h.Subscribe(Filter{}, "")
+	require.NoError(t, err)
+	other, err := h.Subscribe(Filter{CustomerID: "alice"}, "")
+	require.NoError(t, err)
+	_, err = h.Subscribe(Filter{}, "")
+	require.ErrorIs(t, err, ErrTooManySubscribers)
+
+	for _, uid := range []string{"a", "b", "c"} {
+		h.Publish(&domain.Order{OrderUID: uid})
+	}
+
+	require.Equal(t, []string{"a", "b"}, uids(receive(t, slow, 2)))
+	_, ok := <-slow.Events()
+	require.False(t, ok)
+	require.True(t, slow.Dropped())
+	slow.Close()
+
+	require.False(t, other.Dropped())
+	require.Equal(t, 1, h.Subscribers())
+}
@@ -0,0 +1,136 @@
+package transfer
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"go.uber.org/zap"
+)
+
+type ExportStats struct {
+	Orders int
+	Rows   int
+}
+
+type Exporter struct {
+	source Source
+	logger *zap.Logger
+}
+
+func NewExporter(source Source, logger *zap.Logger) *Exporter {
+	return &Exporter{
+		source: source,
+		logger: logger,
+	}
+}
+
+// Export streams orders matching f to w: NDJSON writes one order per line,
+// CSV writes one row per item (or a single row for an order without items).
+func (e *Exporter) Export(ctx context.Context, w io.Writer, format Format, f domain.OrderFilter) (ExportStats, error) {
+	var (
+		st    ExportStats
+		write func(*domain.Order) (int, error)
+		flush func() error
+	)
+
+	switch format {
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		write = func(o *domain.Order) (int, error) { return 1, enc.Encode(o) }
+		flush = func() error { return nil }
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return st, err
+		}
+		write = func(o *domain.Order) (int, error) {
+			rows := csvRows(o)
+			return len(rows), cw.WriteAll(rows)
+		}
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	default:
+		return st, fmt.Errorf("unknown format %q", format)
+	}
+
+	start, last := time.Now(), time.Now()
+	err := e.source.StreamOrders(ctx, f, func(o *domain.Order) error {
+		n, err := write(o)
+		if err != nil {
+			return err
+		}
+		st.Orders++
+		st.Rows += n
+		if time.Since(last) >= progressEvery {
+			last = time.Now()
+			e.logger.Info("export progress",
+				zap.Int("orders", st.Orders),
+				zap.Int("rows", st.Rows),
+				zap.Duration("elapsed", time.Since(start)),
+			)
+		}
+		return nil
+	})
+	if err != nil {
+		return st, err
+	}
+	if err := flush(); err != nil {
+		return st, err
+	}
+
+	e.logger.Info("export finished",
+		zap.String("format", string(format)),
+		zap.Int("orders", st.Orders),
+		zap.Int("rows", st.Rows),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	return st, nil
+}
+
+var csvHeader = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id",
+	"delivery_service", "shardkey", "sm_id", "date_created", "oof_shard",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address",
+	"delivery_region", "delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider",
+	"payment_amount", "payment_dt", "payment_bank", "payment_delivery_cost",
+	"payment_goods_total", "payment_custom_fee",
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name", "item_sale",
+	"item_size", "item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+func csvRows(o *domain.Order) [][]string {
+	head := []string{
+		o.OrderUID, o.TrackNumber, o.Entry, o.Locale, o.InternalSignature, o.CustomerID,
+		o.DeliveryService, o.ShardKey, strconv.Itoa(o.SmID), o.DateCreated.Format(time.RFC3339Nano), o.OofShard,
+		o.Delivery.Name, o.Delivery.Phone, o.Delivery.Zip, o.Delivery.City, o.Delivery.Address,
+		o.Delivery.Region, o.Delivery.Email,
+		o.Payment.Transaction, o.Payment.RequestID, o.Payment.Currency, o.Payment.Provider,
+		strconv.Itoa(o.Payment.Amount), strconv.FormatInt(o.Payment.PaymentDT, 10), o.Payment.Bank,
+		strconv.Itoa(o.Payment.DeliveryCost), strconv.Itoa(o.Payment.GoodsTotal), strconv.Itoa(o.Payment.CustomFee),
+	}
+
+	if len(o.Items) == 0 {
+		row := append(append([]string(nil), head...), make([]string, len(csvHeader)-len(head))...)
+		return [][]string{row}
+	}
+
+	rows := make([][]string, 0, len(o.Items))
+	for _, it := range o.Items {
+		row := append(append(make([]string, 0, len(csvHeader)), head...),
+			strconv.Itoa(it.ChrtID), it.TrackNumber, strconv.Itoa(it.Price), it.RID, it.Name,
+			strconv.Itoa(it.Sale), it.Size, strconv.Itoa(it.TotalPrice), strconv.Itoa(it.NmID),
+			it.Brand, strconv.Itoa(it.Status),
+		)
+		rows = append(rows, row)
+	}
+	return rows
+}
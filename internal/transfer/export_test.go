@@ -0,0 +1,102 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func streamOf(orders ...*domain.Order) func(context.Context, domain.OrderFilter, func(*domain.Order) error) error {
+	return func(_ context.Context, _ domain.OrderFilter, fn func(*domain.Order) error) error {
+		for _, o := range orders {
+			if err := fn(o); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	filter := domain.OrderFilter{CustomerID: "c1"}
+	orders := []*domain.Order{{OrderUID: "1", CustomerID: "c1"}, {OrderUID: "2", CustomerID: "c1"}}
+
+	source := NewMockSource(ctrl)
+	source.EXPECT().StreamOrders(gomock.Any(), filter, gomock.Any()).DoAndReturn(streamOf(orders...))
+
+	var buf bytes.Buffer
+	st, err := NewExporter(source, zap.NewNop()).Export(context.Background(), &buf, FormatNDJSON, filter)
+	require.NoError(t, err)
+	require.Equal(t, ExportStats{Orders: 2, Rows: 2}, st)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	for i, line := range lines {
+		var o domain.Order
+		require.NoError(t, json.Unmarshal([]byte(line), &o))
+		require.Equal(t, orders[i].OrderUID, o.OrderUID)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	withItems := &domain.Order{
+		OrderUID:    "1",
+		DateCreated: time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Items:       []domain.Item{{ChrtID: 10, Name: "Mascaras"}, {ChrtID: 11, Name: "Lipstick"}},
+	}
+	withoutItems := &domain.Order{OrderUID: "2"}
+
+	source := NewMockSource(ctrl)
+	source.EXPECT().StreamOrders(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(streamOf(withItems, withoutItems))
+
+	var buf bytes.Buffer
+	st, err := NewExporter(source, zap.NewNop()).Export(context.Background(), &buf, FormatCSV, domain.OrderFilter{})
+	require.NoError(t, err)
+	require.Equal(t, ExportStats{Orders: 2, Rows: 3}, st)
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	require.Equal(t, csvHeader, records[0])
+
+	col := func(name string) int {
+		for i, h := range csvHeader {
+			if h == name {
+				return i
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return -1
+	}
+	require.Equal(t, "1", records[1][col("order_uid")])
+	require.Equal(t, "2021-11-26T06:22:19Z", records[1][col("date_created")])
+	require.Equal(t, "Mascaras", records[1][col("item_name")])
+	require.Equal(t, "11", records[2][col("item_chrt_id")])
+	require.Equal(t, "2", records[3][col("order_uid")])
+	require.Equal(t, "", records[3][col("item_chrt_id")])
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat(" CSV ")
+	require.NoError(t, err)
+	require.Equal(t, FormatCSV, f)
+
+	_, err = ParseFormat("xml")
+	require.Error(t, err)
+}
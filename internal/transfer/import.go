@@ -0,0 +1,242 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"net"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+const defaultBatchSize = 500
+
+type ImportStats struct {
+	Lines    int
+	Imported int
+	Rejected int
+}
+
+// reject is a line of the rejects file: the original input plus the reason it was refused.
+type reject struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+	Raw   string `json:"raw"`
+}
+
+type Importer struct {
+	sink      Sink
+	logger    *zap.Logger
+	batchSize int
+}
+
+func NewImporter(sink Sink, batchSize int, logger *zap.Logger) *Importer {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	return &Importer{
+		sink:      sink,
+		logger:    logger,
+		batchSize: batchSize,
+	}
+}
+
+// pending is an order waiting in the current batch together with its source line.
+type pending struct {
+	line  int
+	raw   []byte
+	order *domain.Order
+}
+
+// Import reads NDJSON orders from r (gzip is detected automatically), validates them
+// the same way the HTTP and Kafka paths do and upserts them in batches. Lines that
+// cannot be decoded, fail validation or are refused by the database are written to
+// rejects; Import only returns an error when the input or the database is unusable.
+func (i *Importer) Import(ctx context.Context, r io.Reader, rejects io.Writer) (ImportStats, error) {
+	var st ImportStats
+
+	br := bufio.NewReaderSize(r, 1<<20)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return st, err
+		}
+		defer zr.Close()
+		br = bufio.NewReaderSize(zr, 1<<20)
+	}
+
+	rej := json.NewEncoder(rejects)
+	writeReject := func(line int, raw []byte, reason error) error {
+		st.Rejected++
+		return rej.Encode(reject{Line: line, Error: reason.Error(), Raw: string(raw)})
+	}
+
+	batch := make([]pending, 0, i.batchSize)
+	index := make(map[string]int, i.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := i.flush(ctx, batch, writeReject)
+		st.Imported += n
+		batch = batch[:0]
+		clear(index)
+		return err
+	}
+
+	start, last := time.Now(), time.Now()
+	for {
+		raw, readErr := br.ReadBytes('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return st, readErr
+		}
+
+		if line := bytes.TrimSpace(raw); len(line) > 0 {
+			st.Lines++
+			o, err := decodeOrder(line)
+			if err != nil {
+				if err := writeReject(st.Lines, line, err); err != nil {
+					return st, err
+				}
+			} else {
+				p := pending{line: st.Lines, raw: line, order: o}
+				// The same order twice in a batch would duplicate its items: the later line wins.
+				if j, ok := index[o.OrderUID]; ok {
+					batch[j] = p
+				} else {
+					index[o.OrderUID] = len(batch)
+					batch = append(batch, p)
+				}
+			}
+		} else if len(raw) > 0 {
+			st.Lines++
+		}
+
+		if len(batch) >= i.batchSize {
+			if err := flush(); err != nil {
+				return st, err
+			}
+		}
+		if time.Since(last) >= progressEvery {
+			last = time.Now()
+			i.logger.Info("import progress",
+				zap.Int("lines", st.Lines),
+				zap.Int("imported", st.Imported),
+				zap.Int("rejected", st.Rejected),
+				zap.Duration("elapsed", time.Since(start)),
+			)
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+	}
+	if err := flush(); err != nil {
+		return st, err
+	}
+
+	i.logger.Info("import finished",
+		zap.Int("lines", st.Lines),
+		zap.Int("imported", st.Imported),
+		zap.Int("rejected", st.Rejected),
+		zap.Duration("elapsed", time.Since(start)),
+	)
+	return st, nil
+}
+
+// flush upserts the batch at once. If the batch is refused, orders are retried one by
+// one so a single bad order only rejects itself. An error saying storage is
+// unavailable rejects no order: it is returned, ending the import.
+func (i *Importer) flush(ctx context.Context, batch []pending, writeReject func(int, []byte, error) error) (int, error) {
+	orders := make([]*domain.Order, len(batch))
+	for j, p := range batch {
+		orders[j] = p.order
+	}
+	batchErr := i.sink.UpsertBatch(ctx, orders)
+	if batchErr == nil {
+		return len(batch), nil
+	}
+	if ctx.Err() != nil {
+		return 0, ctx.Err()
+	}
+	if unavailable(batchErr) {
+		return 0, batchErr
+	}
+	i.logger.Warn("batch upsert failed, retrying orders one by one",
+		zap.Int("from_line", batch[0].line),
+		zap.Int("to_line", batch[len(batch)-1].line),
+		zap.Error(batchErr),
+	)
+
+	imported := 0
+	failed := make([]error, len(batch))
+	for j, p := range batch {
+		if failed[j] = i.sink.UpsertBatch(ctx, []*domain.Order{p.order}); failed[j] != nil {
+			if ctx.Err() != nil {
+				return imported, ctx.Err()
+			}
+			if unavailable(failed[j]) {
+				return imported, failed[j]
+			}
+			continue
+		}
+		imported++
+	}
+	for j, p := range batch {
+		if failed[j] == nil {
+			continue
+		}
+		if err := writeReject(p.line, p.raw, failed[j]); err != nil {
+			return imported, err
+		}
+	}
+	return imported, nil
+}
+
+// unavailable reports errors that are about storage rather than the order
+// written: the database cannot be reached, is shutting down or out of
+// resources, or the file it is kept in cannot be written.
+func unavailable(err error) bool {
+	if pgconn.Timeout(err) {
+		return true
+	}
+	var connErr *pgconn.ConnectError
+	var netErr net.Error
+	var pathErr *fs.PathError
+	if errors.As(err, &connErr) || errors.As(err, &netErr) || errors.As(err, &pathErr) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	switch pgErr.Code[:min(len(pgErr.Code), 2)] {
+	case "08", // connection_exception
+		"53", // insufficient_resources
+		"57", // operator_intervention: shutdown, cannot_connect_now, query_canceled
+		"58": // system_error
+		return true
+	}
+	return pgErr.Code == "25006" // read_only_sql_transaction: connected to a demoted primary
+}
+
+func decodeOrder(line []byte) (*domain.Order, error) {
+	var o domain.Order
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&o); err != nil {
+		return nil, err
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
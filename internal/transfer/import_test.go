@@ -0,0 +1,193 @@
+package transfer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+func uids(orders []*domain.Order) []string {
+	out := make([]string, len(orders))
+	for i, o := range orders {
+		out[i] = o.OrderUID
+	}
+	return out
+}
+
+func readRejects(t *testing.T, buf *bytes.Buffer) []reject {
+	t.Helper()
+	var out []reject
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r reject
+		require.NoError(t, dec.Decode(&r))
+		out = append(out, r)
+	}
+	return out
+}
+
+func TestImport(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	input := strings.Join([]string{
+		`{"order_uid":"1","customer_id":"a"}`,
+		`{"order_uid":"2"}`,
+		``,
+		`{"order_uid":"1","customer_id":"b"}`,
+		`not json`,
+		`{"track_number":"no uid"}`,
+		`{"order_uid":"3","unknown":1}`,
+		`{"order_uid":"4"}`,
+	}, "\n")
+
+	var got [][]*domain.Order
+	sink := NewMockSink(ctrl)
+	sink.EXPECT().UpsertBatch(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, orders []*domain.Order) error {
+			got = append(got, orders)
+			return nil
+		})
+
+	var rejects bytes.Buffer
+	st, err := NewImporter(sink, 3, zap.NewNop()).Import(context.Background(), strings.NewReader(input), &rejects)
+	require.NoError(t, err)
+	require.Equal(t, ImportStats{Lines: 8, Imported: 3, Rejected: 3}, st)
+
+	require.Len(t, got, 1)
+	require.Equal(t, []string{"1", "2", "4"}, uids(got[0]))
+	require.Equal(t, "b", got[0][0].CustomerID, "later line of the same order wins")
+
+	rs := readRejects(t, &rejects)
+	require.Len(t, rs, 3)
+	require.Equal(t, 5, rs[0].Line)
+	require.Equal(t, "not json", rs[0].Raw)
+	require.Equal(t, 6, rs[1].Line)
+	require.Equal(t, "order_uid is required", rs[1].Error)
+	require.Equal(t, 7, rs[2].Line)
+}
+
+func TestImportGzip(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var in bytes.Buffer
+	zw := gzip.NewWriter(&in)
+	_, _ = zw.Write([]byte("{\"order_uid\":\"1\"}\n{\"order_uid\":\"2\"}\n"))
+	require.NoError(t, zw.Close())
+
+	sink := NewMockSink(ctrl)
+	sink.EXPECT().UpsertBatch(gomock.Any(), gomock.Len(2)).Return(nil)
+
+	st, err := NewImporter(sink, 10, zap.NewNop()).Import(context.Background(), &in, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.Equal(t, 2, st.Imported)
+}
+
+func TestImportIsolatesBadOrder(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	input := "{\"order_uid\":\"ok\"}\n{\"order_uid\":\"bad\"}\n"
+	dbErr := errors.New("value out of range")
+
+	sink := NewMockSink(ctrl)
+	sink.EXPECT().UpsertBatch(gomock.Any(), gomock.Len(2)).Return(dbErr)
+	sink.EXPECT().UpsertBatch(gomock.Any(), gomock.Len(1)).DoAndReturn(
+		func(_ context.Context, orders []*domain.Order) error {
+			if orders[0].OrderUID == "bad" {
+				return dbErr
+			}
+			return nil
+		}).Times(2)
+
+	var rejects bytes.Buffer
+	st, err := NewImporter(sink, 10, zap.NewNop()).Import(context.Background(), strings.NewReader(input), &rejects)
+	require.NoError(t, err)
+	require.Equal(t, ImportStats{Lines: 2, Imported: 1, Rejected: 1}, st)
+
+	rs := readRejects(t, &rejects)
+	require.Len(t, rs, 1)
+	require.Equal(t, 2, rs[0].Line)
+	require.Equal(t, dbErr.Error(), rs[0].Error)
+}
+
+func TestImportDatabaseDown(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	input := "{\"order_uid\":\"1\"}\n{\"order_uid\":\"2\"}\n"
+	dbErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	for _, batch := range []int{1, 10} {
+		t.Run(fmt.Sprintf("batch %d", batch), func(t *testing.T) {
+			sink := NewMockSink(ctrl)
+			sink.EXPECT().UpsertBatch(gomock.Any(), gomock.Any()).Return(dbErr)
+
+			var rejects bytes.Buffer
+			_, err := NewImporter(sink, batch, zap.NewNop()).Import(context.Background(), strings.NewReader(input), &rejects)
+			require.ErrorIs(t, err, dbErr)
+			require.Zero(t, rejects.Len(), "no order is rejected while the database is down")
+		})
+	}
+}
+
+func TestImportDatabaseDownMidBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	input := "{\"order_uid\":\"ok\"}\n{\"order_uid\":\"bad\"}\n{\"order_uid\":\"late\"}\n"
+	badErr := &pgconn.PgError{Code: "23514", Message: "check constraint violated"}
+	downErr := &pgconn.PgError{Code: "57P01", Message: "terminating connection due to administrator command"}
+
+	sink := NewMockSink(ctrl)
+	sink.EXPECT().UpsertBatch(gomock.Any(), gomock.Len(3)).Return(badErr)
+	gomock.InOrder(
+		sink.EXPECT().UpsertBatch(gomock.Any(), gomock.Len(1)).Return(nil),
+		sink.EXPECT().UpsertBatch(gomock.Any(), gomock.Len(1)).Return(badErr),
+		sink.EXPECT().UpsertBatch(gomock.Any(), gomock.Len(1)).Return(downErr),
+	)
+
+	var rejects bytes.Buffer
+	_, err := NewImporter(sink, 10, zap.NewNop()).Import(context.Background(), strings.NewReader(input), &rejects)
+	require.ErrorIs(t, err, downErr)
+	require.Zero(t, rejects.Len(), "the batch is not rejected in part")
+}
+
+func TestUnavailable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", fmt.Errorf("upsert: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), true},
+		{"timeout", context.DeadlineExceeded, true},
+		{"server shutting down", &pgconn.PgError{Code: "57P01"}, true},
+		{"too many connections", &pgconn.PgError{Code: "53300"}, true},
+		{"read-only replica", &pgconn.PgError{Code: "25006"}, true},
+		{"file not writable", &fs.PathError{Op: "write", Path: "orders.ndjson", Err: syscall.ENOSPC}, true},
+		{"constraint violated", &pgconn.PgError{Code: "23505"}, false},
+		{"value out of range", &pgconn.PgError{Code: "22003"}, false},
+		{"other", errors.New("value out of range"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, unavailable(tt.err))
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TemirB/wb-tech-L0/internal/domain"
+)
+
+//go:generate mockgen -source internal/transfer/transfer.go -destination=internal/transfer/transfer_mock_test.go -package=transfer
+
+type Source interface {
+	StreamOrders(ctx context.Context, f domain.OrderFilter, fn func(*domain.Order) error) error
+}
+
+type Sink interface {
+	UpsertBatch(ctx context.Context, orders []*domain.Order) error
+}
+
+type Format string
+
+const (
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case FormatNDJSON, FormatCSV:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format %q (want ndjson or csv)", s)
+	}
+}
+
+// progressEvery is how often long-running exports and imports log their progress.
+const progressEvery = 5 * time.Second
@@ -0,0 +1,145 @@
+-- Monthly range partitioning of orders and items by date_created.
+--
+-- Partitioned tables require the partition key in every unique constraint, so the
+-- order primary key becomes (order_uid, date_created) and every child table carries
+-- date_created to reference it. Uniqueness of order_uid alone is enforced by the
+-- repository (advisory lock per order_uid inside the upsert transaction).
+--
+-- Future partitions are created and expired ones dropped by the application
+-- (database.Partitioner); this migration only converts the existing tables and
+-- creates partitions covering the data already stored plus the current month.
+
+BEGIN;
+
+ALTER TABLE orders."order" RENAME TO order_legacy;
+ALTER TABLE orders.delivery RENAME TO delivery_legacy;
+ALTER TABLE orders.payment RENAME TO payment_legacy;
+ALTER TABLE orders.item RENAME TO item_legacy;
+
+ALTER INDEX IF EXISTS orders.idx_order_date RENAME TO idx_order_legacy_date;
+ALTER INDEX IF EXISTS orders.idx_order_track RENAME TO idx_order_legacy_track;
+ALTER INDEX IF EXISTS orders.idx_item_order RENAME TO idx_item_legacy_order;
+
+CREATE TABLE orders."order" (
+  order_uid TEXT NOT NULL,
+  track_number TEXT NOT NULL,
+  entry TEXT,
+  locale TEXT,
+  internal_signature TEXT,
+  customer_id TEXT,
+  delivery_service TEXT,
+  shardkey TEXT,
+  sm_id INT,
+  date_created TIMESTAMPTZ NOT NULL,
+  oof_shard TEXT,
+  PRIMARY KEY (order_uid, date_created)
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE orders.item (
+  order_uid TEXT NOT NULL,
+  date_created TIMESTAMPTZ NOT NULL,
+  chrt_id INT,
+  track_number TEXT,
+  price INT,
+  rid TEXT,
+  name TEXT,
+  sale INT,
+  size TEXT,
+  total_price INT,
+  nm_id INT,
+  brand TEXT,
+  status INT,
+  FOREIGN KEY (order_uid, date_created) REFERENCES orders."order"(order_uid, date_created) ON DELETE CASCADE
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE orders.delivery (
+  order_uid TEXT PRIMARY KEY,
+  date_created TIMESTAMPTZ NOT NULL,
+  name TEXT,
+  phone TEXT,
+  zip TEXT,
+  city TEXT,
+  address TEXT,
+  region TEXT,
+  email TEXT,
+  FOREIGN KEY (order_uid, date_created) REFERENCES orders."order"(order_uid, date_created) ON DELETE CASCADE
+);
+
+CREATE TABLE orders.payment (
+  transaction TEXT PRIMARY KEY,
+  order_uid TEXT NOT NULL,
+  date_created TIMESTAMPTZ NOT NULL,
+  request_id TEXT,
+  currency TEXT,
+  provider TEXT,
+  amount INT,
+  payment_dt BIGINT,
+  bank TEXT,
+  delivery_cost INT,
+  goods_total INT,
+  custom_fee INT,
+  FOREIGN KEY (order_uid, date_created) REFERENCES orders."order"(order_uid, date_created) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_order_date ON orders."order"(date_created DESC);
+CREATE INDEX idx_order_track ON orders."order"(track_number);
+CREATE INDEX idx_item_order ON orders.item(order_uid, date_created);
+CREATE INDEX idx_delivery_date ON orders.delivery(date_created);
+CREATE INDEX idx_payment_order ON orders.payment(order_uid);
+CREATE INDEX idx_payment_date ON orders.payment(date_created);
+
+-- Rows outside every monthly range (very old or far-future dates) land here.
+CREATE TABLE orders.order_default PARTITION OF orders."order" DEFAULT;
+CREATE TABLE orders.item_default PARTITION OF orders.item DEFAULT;
+
+DO $$
+DECLARE
+  m_from DATE;
+  m_to   DATE;
+  m      DATE;
+BEGIN
+  SELECT date_trunc('month', LEAST(COALESCE(MIN(date_created), now()), now()) AT TIME ZONE 'UTC')::date,
+         date_trunc('month', GREATEST(COALESCE(MAX(date_created), now()), now()) AT TIME ZONE 'UTC')::date
+    INTO m_from, m_to
+    FROM orders.order_legacy;
+
+  m := m_from;
+  WHILE m <= m_to LOOP
+    EXECUTE format(
+      'CREATE TABLE orders.%I PARTITION OF orders."order" FOR VALUES FROM (%L) TO (%L)',
+      'order_p' || to_char(m, 'YYYY_MM'), m::timestamp AT TIME ZONE 'UTC', (m + interval '1 month')::timestamp AT TIME ZONE 'UTC');
+    EXECUTE format(
+      'CREATE TABLE orders.%I PARTITION OF orders.item FOR VALUES FROM (%L) TO (%L)',
+      'item_p' || to_char(m, 'YYYY_MM'), m::timestamp AT TIME ZONE 'UTC', (m + interval '1 month')::timestamp AT TIME ZONE 'UTC');
+    m := m + interval '1 month';
+  END LOOP;
+END $$;
+
+INSERT INTO orders."order" (order_uid, track_number, entry, locale, internal_signature,
+  customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard)
+SELECT order_uid, track_number, entry, locale, internal_signature,
+  customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
+FROM orders.order_legacy;
+
+INSERT INTO orders.delivery (order_uid, date_created, name, phone, zip, city, address, region, email)
+SELECT d.order_uid, o.date_created, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email
+FROM orders.delivery_legacy d JOIN orders.order_legacy o USING (order_uid);
+
+INSERT INTO orders.payment (transaction, order_uid, date_created, request_id, currency, provider,
+  amount, payment_dt, bank, delivery_cost, goods_total, custom_fee)
+SELECT p.transaction, p.order_uid, o.date_created, p.request_id, p.currency, p.provider,
+  p.amount, p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+FROM orders.payment_legacy p JOIN orders.order_legacy o USING (order_uid);
+
+INSERT INTO orders.item (order_uid, date_created, chrt_id, track_number, price, rid, name,
+  sale, size, total_price, nm_id, brand, status)
+SELECT i.order_uid, o.date_created, i.chrt_id, i.track_number, i.price, i.rid, i.name,
+  i.sale, i.size, i.total_price, i.nm_id, i.brand, i.status
+FROM orders.item_legacy i JOIN orders.order_legacy o USING (order_uid);
+
+DROP TABLE orders.item_legacy;
+DROP TABLE orders.payment_legacy;
+DROP TABLE orders.delivery_legacy;
+DROP TABLE orders.order_legacy;
+
+COMMIT;
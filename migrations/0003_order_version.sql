@@ -0,0 +1,3 @@
+-- Every upsert bumps the order version; it is published with NOTIFY so other
+-- instances can tell whether their cached copy is stale.
+ALTER TABLE orders."order" ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,9 @@
+-- Read counters for the "frequent" cache warm-up strategy. Instances add their
+-- counts periodically; rows are not tied to a partition and outlive their order.
+CREATE TABLE IF NOT EXISTS orders.order_reads (
+  order_uid TEXT PRIMARY KEY,
+  reads BIGINT NOT NULL DEFAULT 0,
+  last_read TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS order_reads_reads_idx ON orders.order_reads (reads DESC, order_uid);
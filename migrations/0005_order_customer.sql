@@ -0,0 +1,3 @@
+-- Lookups by customer list their orders newest first; track number lookups use
+-- idx_order_track from 0002_partitioning.sql.
+CREATE INDEX IF NOT EXISTS idx_order_customer ON orders."order"(customer_id, date_created DESC);
@@ -0,0 +1,3 @@
+-- Every upsert records when it happened; the HTTP API sends it as Last-Modified.
+-- Orders stored before this migration count as updated when it ran.
+ALTER TABLE orders."order" ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();